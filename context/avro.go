@@ -0,0 +1,537 @@
+package context
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SchemaRegistryURLEnvName sets the url of the schema registry resolving the schemas of the Avro payloads,
+	// the Avro payloads are not decoded when unset. The credentials can be set in the userinfo of the url.
+	SchemaRegistryURLEnvName = "SCHEMA_REGISTRY_URL"
+	// AvroContentType is the content type of the Avro payloads prefixed with the id of their schema,
+	// as in the wire format of the Confluent schema registry.
+	AvroContentType = "application/avro"
+
+	avroMagicByte              = 0
+	avroWireHeaderSize         = 5
+	schemaRegistryTimeout      = 10 * time.Second
+	schemaRegistryContentTypes = "application/vnd.schemaregistry.v1+json, application/json"
+)
+
+var (
+	avroTypesMu sync.RWMutex
+	avroTypes   = map[string]func() interface{}{}
+)
+
+// RegisterAvroType registers the type the Avro records of the full name, such as `com.example.Order`,
+// are decoded into by AvroCodec.Decode. The fields of the records are mapped by the json tags of the type.
+func RegisterAvroType(name string, newValue func() interface{}) {
+	avroTypesMu.Lock()
+	defer avroTypesMu.Unlock()
+
+	avroTypes[name] = newValue
+}
+
+func getAvroType(name string) func() interface{} {
+	avroTypesMu.RLock()
+	defer avroTypesMu.RUnlock()
+
+	return avroTypes[name]
+}
+
+// AvroCodec decodes the Avro payloads with the schemas resolved from the schema registry by the id
+// embedded in the payloads, the schemas are cached once resolved.
+type AvroCodec struct {
+	registryURL string
+	client      *http.Client
+
+	mu      sync.RWMutex
+	schemas map[int32]*avroSchema
+}
+
+var _ Decoder = &AvroCodec{}
+
+func NewAvroCodec(registryURL string) *AvroCodec {
+	return &AvroCodec{
+		registryURL: strings.TrimSuffix(registryURL, "/"),
+		client:      &http.Client{Timeout: schemaRegistryTimeout},
+		schemas:     map[int32]*avroSchema{},
+	}
+}
+
+// NewAvroCodecFromEnv returns the Avro codec of the schema registry configured by the env, or nil if it is not set.
+func NewAvroCodecFromEnv() *AvroCodec {
+	url := os.Getenv(SchemaRegistryURLEnvName)
+	if url == "" {
+		return nil
+	}
+	return NewAvroCodec(url)
+}
+
+func (c *AvroCodec) ContentType() string {
+	return AvroContentType
+}
+
+// Unmarshal decodes the Avro payload into v. A *interface{} receives the value returned by Decode,
+// a *map[string]interface{} receives the generic record, and the other types are filled by the json tags
+// of their fields.
+func (c *AvroCodec) Unmarshal(data []byte, v interface{}) error {
+	schema, value, err := c.decode(data)
+	if err != nil {
+		return err
+	}
+
+	switch p := v.(type) {
+	case *interface{}:
+		*p, err = newAvroValue(schema, value)
+		return err
+	case *map[string]interface{}:
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the avro %s value is not a record", schema.typ)
+		}
+		*p = record
+		return nil
+	}
+	return convertAvroValue(value, v)
+}
+
+// Decode decodes the Avro payload into a new value of the type registered for the record with RegisterAvroType,
+// or into its generic representation: a map[string]interface{} for the records and the maps, a []interface{}
+// for the arrays, a string for the enums and the Go values of the primitive types.
+func (c *AvroCodec) Decode(data []byte) (interface{}, error) {
+	schema, value, err := c.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return newAvroValue(schema, value)
+}
+
+func newAvroValue(schema *avroSchema, value interface{}) (interface{}, error) {
+	newValue := getAvroType(schema.name)
+	if schema.typ != "record" || newValue == nil {
+		return value, nil
+	}
+	v := newValue()
+	if err := convertAvroValue(value, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// convertAvroValue fills v with the generic value through its json representation.
+func convertAvroValue(value interface{}, v interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to convert the avro value into %T: %v", v, err)
+	}
+	return nil
+}
+
+func (c *AvroCodec) decode(data []byte) (*avroSchema, interface{}, error) {
+	if len(data) < avroWireHeaderSize || data[0] != avroMagicByte {
+		return nil, nil, errors.New("the avro payload is not prefixed with the id of its schema")
+	}
+	id := int32(binary.BigEndian.Uint32(data[1:avroWireHeaderSize]))
+	schema, err := c.getSchema(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &avroReader{data: data[avroWireHeaderSize:]}
+	value, err := r.decode(schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode the avro payload of the schema %d: %v", id, err)
+	}
+	if r.pos != len(r.data) {
+		return nil, nil, fmt.Errorf("failed to decode the avro payload of the schema %d: %d trailing bytes", id, len(r.data)-r.pos)
+	}
+	return schema, value, nil
+}
+
+// getSchema returns the schema of the id, it is resolved from the schema registry once.
+func (c *AvroCodec) getSchema(id int32) (*avroSchema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := c.fetchSchema(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the avro schema %d: %v", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+func (c *AvroCodec) fetchSchema(id int32) (*avroSchema, error) {
+	req, err := http.NewRequest(http.MethodGet, c.registryURL+"/schemas/ids/"+strconv.Itoa(int(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", schemaRegistryContentTypes)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the schema registry responded %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	// the schema type is omitted for the Avro schemas
+	if result.SchemaType != "" && result.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("the schema type is %s, not AVRO", result.SchemaType)
+	}
+	return parseAvroSchema(result.Schema)
+}
+
+// avroSchema is the parsed Avro schema the payloads are decoded with.
+type avroSchema struct {
+	typ     string
+	name    string
+	fields  []*avroField
+	symbols []string
+	items   *avroSchema
+	values  *avroSchema
+	union   []*avroSchema
+	size    int
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+var avroPrimitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	p := &avroSchemaParser{names: map[string]*avroSchema{}}
+	return p.parse(v, "")
+}
+
+// avroSchemaParser resolves the references to the named types, which are defined before they are referenced.
+type avroSchemaParser struct {
+	names map[string]*avroSchema
+}
+
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func (p *avroSchemaParser) parse(v interface{}, namespace string) (*avroSchema, error) {
+	switch s := v.(type) {
+	case string:
+		if avroPrimitives[s] {
+			return &avroSchema{typ: s}, nil
+		}
+		if named, ok := p.names[avroFullName(s, namespace)]; ok {
+			return named, nil
+		}
+		if named, ok := p.names[s]; ok {
+			return named, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", s)
+	case []interface{}:
+		schema := &avroSchema{typ: "union"}
+		for _, branch := range s {
+			b, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			schema.union = append(schema.union, b)
+		}
+		return schema, nil
+	case map[string]interface{}:
+		return p.parseComplex(s, namespace)
+	}
+	return nil, fmt.Errorf("invalid avro schema %v", v)
+}
+
+func (p *avroSchemaParser) parseComplex(m map[string]interface{}, namespace string) (*avroSchema, error) {
+	typ, ok := m["type"].(string)
+	if !ok {
+		// the type is a nested schema
+		return p.parse(m["type"], namespace)
+	}
+
+	schema := &avroSchema{typ: typ}
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("the avro %s has no name", typ)
+		}
+		if ns, ok := m["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		schema.name = avroFullName(name, namespace)
+		if i := strings.LastIndex(schema.name, "."); i >= 0 {
+			namespace = schema.name[:i]
+		}
+		// the record can reference itself in its fields
+		p.names[schema.name] = schema
+	}
+
+	switch typ {
+	case "record", "error":
+		schema.typ = "record"
+		fields, _ := m["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("a field of the avro record %s has no name", schema.name)
+			}
+			fs, err := p.parse(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field %s of the avro record %s: %v", name, schema.name, err)
+			}
+			schema.fields = append(schema.fields, &avroField{name: name, schema: fs})
+		}
+	case "enum":
+		symbols, _ := m["symbols"].([]interface{})
+		for _, s := range symbols {
+			symbol, _ := s.(string)
+			schema.symbols = append(schema.symbols, symbol)
+		}
+	case "fixed":
+		size, ok := m["size"].(float64)
+		if !ok || size < 0 {
+			return nil, fmt.Errorf("invalid size of the avro fixed %s", schema.name)
+		}
+		schema.size = int(size)
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		schema.items = items
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		schema.values = values
+	default:
+		// the primitive types annotated with a logical type are decoded as the primitive types
+		return p.parse(typ, namespace)
+	}
+	return schema, nil
+}
+
+// avroReader decodes the values of the Avro binary encoding.
+type avroReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *avroReader) read(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, errors.New("unexpected end of the payload")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// readLong reads the zigzag-encoded variable-length integers the int and long values are encoded with.
+func (r *avroReader) readLong() (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.read(1)
+		if err != nil {
+			return 0, err
+		}
+		u |= uint64(b[0]&0x7f) << shift
+		if b[0]&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, errors.New("invalid variable-length integer")
+}
+
+func (r *avroReader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(len(r.data)-r.pos) {
+		return nil, errors.New("unexpected end of the payload")
+	}
+	return r.read(int(n))
+}
+
+// readBlocks reads the blocks of items the arrays and the maps are encoded with.
+func (r *avroReader) readBlocks(readItem func() error) error {
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// the negative count is followed by the size of the block in bytes
+			count = -count
+			if _, err := r.readLong(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := readItem(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *avroReader) decode(s *avroSchema) (interface{}, error) {
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int":
+		n, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("int %d out of range", n)
+		}
+		return int32(n), nil
+	case "long":
+		return r.readLong()
+	case "float":
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		b, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case "string":
+		b, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "fixed":
+		b, err := r.read(s.size)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case "enum":
+		i, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return nil, fmt.Errorf("invalid symbol %d of the enum %s", i, s.name)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.union)) {
+			return nil, fmt.Errorf("invalid branch %d of the union", i)
+		}
+		return r.decode(s.union[i])
+	case "array":
+		items := []interface{}{}
+		err := r.readBlocks(func() error {
+			item, err := r.decode(s.items)
+			items = append(items, item)
+			return err
+		})
+		return items, err
+	case "map":
+		values := map[string]interface{}{}
+		err := r.readBlocks(func() error {
+			key, err := r.readBytes()
+			if err != nil {
+				return err
+			}
+			values[string(key)], err = r.decode(s.values)
+			return err
+		})
+		return values, err
+	case "record":
+		record := make(map[string]interface{}, len(s.fields))
+		for _, f := range s.fields {
+			value, err := r.decode(f.schema)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", f.name, err)
+			}
+			record[f.name] = value
+		}
+		return record, nil
+	}
+	return nil, fmt.Errorf("unsupported avro type %s", s.typ)
+}
@@ -0,0 +1,193 @@
+package context
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+const orderAvroSchema = `{
+  "type": "record",
+  "name": "Order",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "quantity", "type": "int"},
+    {"name": "price", "type": "double"},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "attributes", "type": {"type": "map", "values": "long"}},
+    {"name": "note", "type": ["null", "string"]},
+    {"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+  ]
+}`
+
+type avroOrder struct {
+	ID         string           `json:"id"`
+	Quantity   int              `json:"quantity"`
+	Price      float64          `json:"price"`
+	Status     string           `json:"status"`
+	Tags       []string         `json:"tags"`
+	Attributes map[string]int64 `json:"attributes"`
+	Note       *string          `json:"note"`
+	Created    int64            `json:"created"`
+}
+
+type avroEncoder []byte
+
+func (e avroEncoder) long(n int64) avroEncoder {
+	var buf [binary.MaxVarintLen64]byte
+	return append(e, buf[:binary.PutVarint(buf[:], n)]...)
+}
+
+func (e avroEncoder) string(s string) avroEncoder {
+	return append(e.long(int64(len(s))), s...)
+}
+
+func (e avroEncoder) double(f float64) avroEncoder {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	return append(e, buf[:]...)
+}
+
+func newAvroOrderMessage(schemaID uint32) []byte {
+	e := avroEncoder{avroMagicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(e[1:], schemaID)
+	e = e.string("o-1").long(3).double(9.5)
+	// status PAID
+	e = e.long(1)
+	// tags in a block of 2 items
+	e = e.long(2).string("fragile").string("gift").long(0)
+	// attributes in a block with its size in bytes
+	e = e.long(-1).long(7).string("weight").long(-40).long(0)
+	// note of the string branch
+	e = e.long(1).string("leave at the door")
+	return e.long(1650000000000)
+}
+
+func newSchemaRegistry(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			json.NewEncoder(w).Encode(map[string]string{"schema": orderAvroSchema})
+		case "/schemas/ids/2":
+			json.NewEncoder(w).Encode(map[string]string{"schema": `syntax = "proto3";`, "schemaType": "PROTOBUF"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+}
+
+func TestAvroCodec(t *testing.T) {
+	var requests int32
+	registry := newSchemaRegistry(&requests)
+	defer registry.Close()
+	codec := NewAvroCodec(registry.URL + "/")
+
+	var record map[string]interface{}
+	if err := codec.Unmarshal(newAvroOrderMessage(1), &record); err != nil {
+		t.Fatalf("Error decode avro payload: %v", err)
+	}
+	want := map[string]interface{}{
+		"id":         "o-1",
+		"quantity":   int32(3),
+		"price":      9.5,
+		"status":     "PAID",
+		"tags":       []interface{}{"fragile", "gift"},
+		"attributes": map[string]interface{}{"weight": int64(-40)},
+		"note":       "leave at the door",
+		"created":    int64(1650000000000),
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Fatalf("Error decode avro payload: got %v, want %v", record, want)
+	}
+
+	var order avroOrder
+	if err := codec.Unmarshal(newAvroOrderMessage(1), &order); err != nil {
+		t.Fatalf("Error decode avro payload: %v", err)
+	}
+	if order.ID != "o-1" || order.Quantity != 3 || order.Status != "PAID" || len(order.Tags) != 2 ||
+		order.Attributes["weight"] != -40 || order.Note == nil || *order.Note != "leave at the door" {
+		t.Fatalf("Error decode avro payload: got %+v", order)
+	}
+
+	// the schema is resolved once
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("Error resolve avro schema: %d requests", n)
+	}
+
+	for name, data := range map[string][]byte{
+		"without schema id": []byte("o-1"),
+		"unknown schema":    newAvroOrderMessage(3),
+		"protobuf schema":   newAvroOrderMessage(2),
+		"truncated":         newAvroOrderMessage(1)[:12],
+		"trailing bytes":    append(newAvroOrderMessage(1), 0),
+	} {
+		if err := codec.Unmarshal(data, &record); err == nil {
+			t.Fatalf("Error decode avro payload %s", name)
+		}
+	}
+}
+
+func TestAvroCodecRegisteredType(t *testing.T) {
+	var requests int32
+	registry := newSchemaRegistry(&requests)
+	defer registry.Close()
+	codec := NewAvroCodec(registry.URL)
+
+	v, err := codec.Decode(newAvroOrderMessage(1))
+	if err != nil {
+		t.Fatalf("Error decode avro payload: %v", err)
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		t.Fatalf("Error decode avro payload into generic map: got %T", v)
+	}
+
+	RegisterAvroType("com.example.Order", func() interface{} { return &avroOrder{} })
+	defer RegisterAvroType("com.example.Order", nil)
+
+	if v, err = codec.Decode(newAvroOrderMessage(1)); err != nil {
+		t.Fatalf("Error decode avro payload: %v", err)
+	}
+	order, ok := v.(*avroOrder)
+	if !ok || order.ID != "o-1" || order.Price != 9.5 {
+		t.Fatalf("Error decode avro payload into registered type: got %#v", v)
+	}
+}
+
+func TestAvroDecoderSelection(t *testing.T) {
+	var requests int32
+	registry := newSchemaRegistry(&requests)
+	defer registry.Close()
+
+	RegisterDecoder(NewAvroCodec(registry.URL))
+
+	var order avroOrder
+	if err := UnmarshalData(AvroContentType, newAvroOrderMessage(1), &order); err != nil || order.ID != "o-1" {
+		t.Fatalf("Error unmarshal avro data: %v", err)
+	}
+	if err := UnmarshalData("text/csv", []byte("o-1,3"), &order); err == nil {
+		t.Fatal("Error unmarshal data without decoder")
+	}
+
+	ce := cloudevents.NewEvent()
+	ce.SetID("order-1")
+	ce.SetType("order.created")
+	ce.SetSource("test")
+	if err := ce.SetData(AvroContentType, newAvroOrderMessage(1)); err != nil {
+		t.Fatalf("Error set cloudevent data: %v", err)
+	}
+	order = avroOrder{}
+	if err := UnmarshalCloudEventData(ce, &order); err != nil || order.Quantity != 3 {
+		t.Fatalf("Error unmarshal avro cloudevent data: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+)
+
+// bindingOperations lists the operations supported by the output bindings of dapr, by component type.
+var bindingOperations = map[string][]string{
+	"bindings.aws.dynamodb":           {"create"},
+	"bindings.aws.s3":                 {"create", "get", "delete", "list"},
+	"bindings.aws.sns":                {"create"},
+	"bindings.aws.sqs":                {"create"},
+	"bindings.azure.blobstorage":      {"create", "get", "delete", "list"},
+	"bindings.azure.eventhubs":        {"create"},
+	"bindings.azure.servicebusqueues": {"create"},
+	"bindings.azure.storagequeues":    {"create"},
+	"bindings.cron":                   {"delete"},
+	"bindings.gcp.bucket":             {"create", "get", "delete", "list"},
+	"bindings.gcp.pubsub":             {"create"},
+	"bindings.http":                   {"create", "get", "head", "post", "put", "patch", "delete", "options", "trace"},
+	"bindings.kafka":                  {"create"},
+	"bindings.localstorage":           {"create", "get", "delete", "list"},
+	"bindings.mqtt":                   {"create"},
+	"bindings.mysql":                  {"exec", "query", "close"},
+	"bindings.postgres":               {"exec", "query", "close"},
+	"bindings.rabbitmq":               {"create"},
+	"bindings.redis":                  {"create"},
+	"bindings.smtp":                   {"create"},
+	"bindings.twilio.sms":             {"create"},
+}
+
+// ListBindingOperations returns the operations supported by the binding component,
+// the component type is discovered from the components registered in the dapr metadata.
+func (ctx *FunctionContext) ListBindingOperations(component string) ([]string, error) {
+	if testMode := os.Getenv(TestModeEnvName); testMode == TestModeOn {
+		return nil, nil
+	}
+
+	client, err := ctx.getDaprGRPCClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetMetadata(withDaprAPIToken(context.Background()), &empty.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dapr metadata: %w", err)
+	}
+
+	for _, c := range resp.GetRegisteredComponents() {
+		if c.GetName() != component {
+			continue
+		}
+		if !strings.HasPrefix(c.GetType(), string(OpenFuncBinding)+".") {
+			return nil, fmt.Errorf("component %s is not a binding: %s", component, c.GetType())
+		}
+		operations, ok := bindingOperations[c.GetType()]
+		if !ok {
+			return nil, fmt.Errorf("unknown operations of binding type %s", c.GetType())
+		}
+		return append([]string(nil), operations...), nil
+	}
+	return nil, fmt.Errorf("binding component %s not found", component)
+}
@@ -0,0 +1,46 @@
+package context
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// fakeComponentRegistry reports the registered components in the dapr metadata
+type fakeComponentRegistry struct {
+	daprGRPCClient
+	components []*pb.RegisteredComponents
+}
+
+func (r *fakeComponentRegistry) GetMetadata(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*pb.GetMetadataResponse, error) {
+	return &pb.GetMetadataResponse{RegisteredComponents: r.components}, nil
+}
+
+func TestListBindingOperations(t *testing.T) {
+	registry := &fakeComponentRegistry{
+		components: []*pb.RegisteredComponents{
+			{Name: "storage", Type: "bindings.aws.s3", Version: "v1"},
+			{Name: "custom", Type: "bindings.custom", Version: "v1"},
+			{Name: "messages", Type: "pubsub.kafka", Version: "v1"},
+		},
+	}
+	ctx := &FunctionContext{grpcClient: registry}
+
+	operations, err := ctx.ListBindingOperations("storage")
+	if err != nil {
+		t.Fatalf("Error list binding operations: %v", err)
+	}
+	if !reflect.DeepEqual(operations, []string{"create", "get", "delete", "list"}) {
+		t.Fatalf("Error list binding operations: got %v", operations)
+	}
+
+	for _, component := range []string{"custom", "messages", "missing"} {
+		if _, err := ctx.ListBindingOperations(component); err == nil {
+			t.Fatalf("Error list operations of component %s", component)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultBufferedSenderMaxSize       = 100
+	defaultBufferedSenderFlushInterval = time.Second
+)
+
+type BufferedSenderOptions struct {
+	// MaxSize is the number of buffered messages that triggers a flush.
+	MaxSize int
+	// FlushInterval is the maximum time a message stays in the buffer before being flushed.
+	FlushInterval time.Duration
+}
+
+// BufferedSender accumulates messages for a topic output and publishes them in batches,
+// either when the buffer reaches MaxSize or when FlushInterval has elapsed since the first buffered message.
+// Buffered messages are also flushed when the function invocation completes, and when the function stops serving.
+// The dapr client has no bulk publish, the messages of a batch are published one by one.
+type BufferedSender struct {
+	mu         sync.Mutex
+	ctx        *FunctionContext
+	outputName string
+	opts       BufferedSenderOptions
+	buffer     [][]byte
+	timer      *time.Timer
+}
+
+// NewBufferedSender creates a BufferedSender for the topic output with the given name.
+func NewBufferedSender(ctx Context, outputName string, opts *BufferedSenderOptions) (*BufferedSender, error) {
+	rc, ok := ctx.(RuntimeContext)
+	if !ok {
+		return nil, errors.New("buffered sender requires a FunctionContext")
+	}
+	fc := rc.GetContext()
+
+	if !fc.HasOutput(outputName) {
+		return nil, fmt.Errorf("output %s not found", outputName)
+	}
+
+	if t := fc.GetOutputs()[outputName].GetType(); t != OpenFuncTopic {
+		return nil, fmt.Errorf("output %s is of type %s, only %s outputs can be buffered", outputName, t, OpenFuncTopic)
+	}
+
+	s := &BufferedSender{
+		ctx:        fc,
+		outputName: outputName,
+		opts: BufferedSenderOptions{
+			MaxSize:       defaultBufferedSenderMaxSize,
+			FlushInterval: defaultBufferedSenderFlushInterval,
+		},
+	}
+	if opts != nil {
+		if opts.MaxSize > 0 {
+			s.opts.MaxSize = opts.MaxSize
+		}
+		if opts.FlushInterval > 0 {
+			s.opts.FlushInterval = opts.FlushInterval
+		}
+	}
+
+	return s, nil
+}
+
+// Send appends data to the buffer and flushes the buffer if it is full.
+func (s *BufferedSender) Send(data []byte) error {
+	// Register the sender with the context so that it is flushed when the invocation completes
+	s.ctx.registerBufferedSender(s)
+
+	s.mu.Lock()
+	s.buffer = append(s.buffer, data)
+	full := len(s.buffer) >= s.opts.MaxSize
+	if !full && s.timer == nil {
+		s.timer = time.AfterFunc(s.opts.FlushInterval, func() {
+			// the timer fires outside of the invocation, whose context may be done by then,
+			// so the buffer is flushed within the base context of the function
+			if err := s.flush(s.ctx.GetBaseContext()); err != nil {
+				klog.Errorf("failed to flush buffered sender for output %s: %v", s.outputName, err)
+			}
+		})
+	}
+	s.mu.Unlock()
+
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush publishes all buffered messages to the output within the base context of the function,
+// so that the messages are not lost once the invocation sending them is cancelled.
+func (s *BufferedSender) Flush() error {
+	return s.flush(s.ctx.GetBaseContext())
+}
+
+// flush publishes all buffered messages to the output within the context c,
+// the messages left once c is done are kept until the function stops serving.
+func (s *BufferedSender) flush(c context.Context) error {
+	s.mu.Lock()
+	buffer := s.buffer
+	s.buffer = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+
+	var err error
+	for i, data := range buffer {
+		if e := c.Err(); e != nil {
+			klog.Warningf("kept %d buffered messages of output %s until shutdown: %v", len(buffer)-i, s.outputName, e)
+			s.keep(buffer[i:])
+			return e
+		}
+		if _, e := s.ctx.sendWithContext(c, s.outputName, data, nil); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// keep puts the messages back in front of the buffer, and the sender back in the live senders of the function.
+func (s *BufferedSender) keep(messages [][]byte) {
+	s.mu.Lock()
+	s.buffer = append(append([][]byte(nil), messages...), s.buffer...)
+	s.mu.Unlock()
+
+	s.ctx.registerBufferedSender(s)
+}
+
+// Len returns the number of buffered messages.
+func (s *BufferedSender) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buffer)
+}
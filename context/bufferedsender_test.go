@@ -0,0 +1,214 @@
+package context
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+var funcCtxWithTopicOutput = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "outputs": {
+    "topic": {
+      "uri": "sample",
+      "componentName": "kafka-server",
+      "componentType": "pubsub.kafka"
+    },
+    "binding": {
+      "uri": "echo",
+      "componentName": "echo",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+
+func newBufferedSenderTestContext(t *testing.T) (*FunctionContext, *fakeDaprClient) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithTopicOutput); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	client := newFakeDaprClient()
+	ctx := rtCtx.GetContext()
+	ctx.daprClient = client
+	return ctx, client
+}
+
+func (c *fakeDaprClient) publishedCount(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.published[key]
+}
+
+// TestBufferedSenderSizeFlush tests and verifies that the buffer is flushed when it is full
+func TestBufferedSenderSizeFlush(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	ctx, client := newBufferedSenderTestContext(t)
+
+	if _, err := NewBufferedSender(ctx, "binding", nil); err == nil {
+		t.Fatal("Error create buffered sender for a binding output")
+	}
+
+	if _, err := NewBufferedSender(ctx, "absent", nil); err == nil {
+		t.Fatal("Error create buffered sender for an absent output")
+	}
+
+	sender, err := NewBufferedSender(ctx, "topic", &BufferedSenderOptions{MaxSize: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Error create buffered sender: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := sender.Send([]byte("hello")); err != nil {
+			t.Fatalf("Error send data: %v", err)
+		}
+	}
+	if n := client.publishedCount("kafka-server/sample"); n != 0 {
+		t.Fatalf("Error buffer data: %d messages published before the buffer is full", n)
+	}
+
+	if err := sender.Send([]byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	if n := client.publishedCount("kafka-server/sample"); n != 3 {
+		t.Fatalf("Error flush full buffer: %d messages published", n)
+	}
+
+	// Messages left in the buffer are flushed on function completion
+	if err := sender.Send([]byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	ctx.FlushBufferedSenders()
+	if n := client.publishedCount("kafka-server/sample"); n != 4 || sender.Len() != 0 {
+		t.Fatalf("Error flush buffer on completion: %d messages published", n)
+	}
+}
+
+// TestBufferedSenderTimeFlush tests and verifies that the buffer is flushed after the flush interval
+func TestBufferedSenderTimeFlush(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	ctx, client := newBufferedSenderTestContext(t)
+
+	sender, err := NewBufferedSender(ctx, "topic", &BufferedSenderOptions{MaxSize: 100, FlushInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error create buffered sender: %v", err)
+	}
+
+	if err := sender.Send([]byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	if n := client.publishedCount("kafka-server/sample"); n != 0 {
+		t.Fatalf("Error buffer data: %d messages published before the flush interval", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for client.publishedCount("kafka-server/sample") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Error flush buffer after the flush interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestBufferedSenderTimeFlushAfterInvocation tests and verifies that the timed flush does not depend on the context of the invocation
+func TestBufferedSenderTimeFlushAfterInvocation(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	ctx, client := newBufferedSenderTestContext(t)
+
+	sender, err := NewBufferedSender(ctx, "topic", &BufferedSenderOptions{MaxSize: 100, FlushInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error create buffered sender: %v", err)
+	}
+
+	invocation, cancel := context.WithCancel(context.Background())
+	ctx.SetNativeContext(invocation)
+	if err := sender.Send([]byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	// the invocation is done before the flush interval elapses
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for client.publishedCount("kafka-server/sample") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Error flush buffer after the invocation is done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestBufferedSenderCancelledInvocation tests and verifies that the messages buffered by a cancelled invocation are delivered
+func TestBufferedSenderCancelledInvocation(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	fc, client := newBufferedSenderTestContext(t)
+
+	ctx := NewInvocationContext(fc)
+	invocation, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx.SetNativeContext(invocation)
+
+	sender, err := NewBufferedSender(ctx.(Context), "topic", &BufferedSenderOptions{MaxSize: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Error create buffered sender: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := sender.Send([]byte("hello")); err != nil {
+			t.Fatalf("Error send data: %v", err)
+		}
+		if i == 1 {
+			cancel()
+		}
+	}
+
+	// the invocation completes after it is cancelled
+	ctx.FlushBufferedSenders()
+	if n := client.publishedCount("kafka-server/sample"); n != 5 || sender.Len() != 0 {
+		t.Fatalf("Error deliver the messages of the cancelled invocation: %d messages published", n)
+	}
+}
+
+// TestBufferedSenderShutdown tests and verifies that the messages kept while the function stops are delivered on shutdown
+func TestBufferedSenderShutdown(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	ctx, client := newBufferedSenderTestContext(t)
+	base, cancel := context.WithCancel(context.Background())
+	ctx.baseCtx, ctx.baseCancel = base, cancel
+
+	sender, err := NewBufferedSender(ctx, "topic", &BufferedSenderOptions{MaxSize: 100, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Error create buffered sender: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sender.Send([]byte("hello")); err != nil {
+			t.Fatalf("Error send data: %v", err)
+		}
+	}
+
+	// the last invocation completes while the function stops
+	cancel()
+	ctx.FlushBufferedSenders()
+	if n := client.publishedCount("kafka-server/sample"); n != 0 || sender.Len() != 2 {
+		t.Fatalf("Error keep the messages while the function stops: %d messages published", n)
+	}
+
+	ctx.CloseBufferedSenders()
+	if n := client.publishedCount("kafka-server/sample"); n != 2 || sender.Len() != 0 {
+		t.Fatalf("Error deliver the kept messages on shutdown: %d messages published", n)
+	}
+}
@@ -0,0 +1,81 @@
+package context
+
+// Builder builds the function context in code in place of the env FUNC_CONTEXT,
+// the context built is given to the framework with WithFunctionContext.
+type Builder struct {
+	name    string
+	version string
+	runtime Runtime
+	port    string
+	inputs  map[string]*Input
+	outputs map[string]*Output
+}
+
+// NewBuilder returns the builder of the function context of the function name and version.
+func NewBuilder(name, version string) *Builder {
+	return &Builder{
+		name:    name,
+		version: version,
+		inputs:  map[string]*Input{},
+		outputs: map[string]*Output{},
+	}
+}
+
+// WithRuntime sets the runtime of the function, Knative or Async.
+func (b *Builder) WithRuntime(runtime Runtime) *Builder {
+	b.runtime = runtime
+	return b
+}
+
+// WithPort sets the port the function is served on, 8080 by default.
+func (b *Builder) WithPort(port string) *Builder {
+	b.port = port
+	return b
+}
+
+// WithInput adds the input of the name, replacing the input added with the same name.
+func (b *Builder) WithInput(name string, input *Input) *Builder {
+	b.inputs[name] = input
+	return b
+}
+
+// WithOutput adds the output of the name, replacing the output added with the same name.
+func (b *Builder) WithOutput(name string, output *Output) *Builder {
+	b.outputs[name] = output
+	return b
+}
+
+// Build validates the configuration and returns a new function context, the same way as FUNC_CONTEXT is parsed.
+func (b *Builder) Build() (*FunctionContext, error) {
+	ctx := &FunctionContext{
+		Name:    b.name,
+		Version: b.version,
+		Runtime: b.runtime,
+		Port:    b.port,
+		Inputs:  make(map[string]*Input, len(b.inputs)),
+		Outputs: make(map[string]*Output, len(b.outputs)),
+	}
+	// the inputs and the outputs are copied so that the contexts built do not share them
+	for name, input := range b.inputs {
+		if input != nil {
+			in := *input
+			input = &in
+		}
+		ctx.Inputs[name] = input
+	}
+	for name, output := range b.outputs {
+		if output != nil {
+			out := *output
+			output = &out
+		}
+		ctx.Outputs[name] = output
+	}
+
+	if err := ctx.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.prepare(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
@@ -0,0 +1,71 @@
+package context
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	os.Setenv(ModeEnvName, SelfHostMode)
+	defer os.Unsetenv(ModeEnvName)
+
+	input := &Input{Uri: "orders", ComponentName: "msg", ComponentType: "pubsub.kafka"}
+	b := NewBuilder("function-test", "v1.0.0").
+		WithRuntime(Async).
+		WithPort("50099").
+		WithInput("sub", input).
+		WithOutput("out", &Output{ComponentName: "out", ComponentType: "bindings.kafka", Operation: "create"})
+
+	ctx, err := b.Build()
+	if err != nil {
+		t.Fatalf("Error build function context: %v", err)
+	}
+	if ctx.GetName() != "function-test" || ctx.GetVersion() != "v1.0.0" || ctx.GetRuntime() != Async || ctx.GetPort() != "50099" {
+		t.Fatalf("Error build function context: got %+v", ctx)
+	}
+	if ctx.GetInputs()["sub"].Uri != "orders" || !ctx.HasOutput("out") {
+		t.Fatal("Error build function context: failed to add inputs and outputs")
+	}
+	// the built context does not share the inputs of the builder
+	input.Uri = "payments"
+	if ctx.GetInputs()["sub"].Uri != "orders" {
+		t.Fatal("Error build function context: input shared with the builder")
+	}
+
+	// the built context is parsed in place of FUNC_CONTEXT
+	os.Unsetenv(FunctionContextEnvName)
+	rtCtx, err := GetRuntimeContext(WithFunctionContext(ctx), WithOutputSender("bindings.kafka", nil))
+	if err != nil {
+		t.Fatalf("Error parse built function context: %v", err)
+	}
+	if rtCtx.GetName() != "function-test" || rtCtx.GetPort() != "50099" || !rtCtx.HasOutput("out") {
+		t.Fatal("Error parse built function context")
+	}
+	if _, ok := rtCtx.GetContext().outputSenders["bindings.kafka"]; !ok {
+		t.Fatal("Error parse built function context: options not applied")
+	}
+
+	// the built context is applied as is rather than through its json encoding
+	type state struct{ count int }
+	ctx.State = &state{count: 1}
+	rtCtx, err = GetRuntimeContext(WithFunctionContext(ctx))
+	if err != nil {
+		t.Fatalf("Error parse built function context: %v", err)
+	}
+	if s, ok := rtCtx.GetContext().State.(*state); !ok || s.count != 1 {
+		t.Fatalf("Error parse built function context: got state %#v", rtCtx.GetContext().State)
+	}
+	if rtCtx.GetInputs()["sub"] == ctx.GetInputs()["sub"] {
+		t.Fatal("Error parse built function context: input shared with the built context")
+	}
+
+	if _, err := NewBuilder("function-test", "v1.0.0").Build(); err == nil {
+		t.Fatal("Error build function context without runtime")
+	}
+	if _, err := NewBuilder("function-test", "v1.0.0").WithRuntime(Knative).WithPort("http").Build(); err == nil {
+		t.Fatal("Error build function context with invalid port")
+	}
+	if _, err := NewBuilder("function-test", "v1.0.0").WithRuntime(Async).WithInput("sub", nil).Build(); err == nil {
+		t.Fatal("Error build function context with nil input")
+	}
+}
@@ -0,0 +1,158 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CircuitBreakerState string
+
+const (
+	CircuitClosed   CircuitBreakerState = "closed"
+	CircuitOpen     CircuitBreakerState = "open"
+	CircuitHalfOpen CircuitBreakerState = "half-open"
+
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned when sending to an output whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerConfig configures the circuit breakers of the outputs.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit, 5 by default.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// Cooldown is how long the circuit stays open before a trial request is let through, 30s by default.
+	Cooldown string `json:"cooldown,omitempty"`
+	cooldown time.Duration
+}
+
+func (c *CircuitBreakerConfig) parse() error {
+	if c.FailureThreshold < 0 {
+		return errors.New("failureThreshold must not be negative")
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultCircuitFailureThreshold
+	}
+
+	c.cooldown = defaultCircuitCooldown
+	if c.Cooldown != "" {
+		d, err := time.ParseDuration(c.Cooldown)
+		if err != nil {
+			return fmt.Errorf("failed to parse cooldown: %v", err)
+		}
+		c.cooldown = d
+	}
+	return nil
+}
+
+// circuitBreaker opens after the consecutive failures reach the threshold,
+// and half-opens after the cooldown to let a single trial request through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     CircuitBreakerState
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(config *CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: config.FailureThreshold,
+		cooldown:  config.cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// allow returns ErrCircuitOpen if the request must not be sent.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		// only a single trial request at a time
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the result of the request.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	halfOpen := b.currentState() == CircuitHalfOpen
+	b.trial = false
+
+	if err == nil {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if halfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release lets another trial request through without recording the result of the request.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *circuitBreaker) currentState() CircuitBreakerState {
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// getCircuitBreaker returns the circuit breaker of the output, nil if the circuit breakers are not enabled.
+func (ctx *FunctionContext) getCircuitBreaker(outputName string) *circuitBreaker {
+	if ctx.CircuitBreaker == nil {
+		return nil
+	}
+
+	ctx.breakersMu.Lock()
+	defer ctx.breakersMu.Unlock()
+
+	if ctx.breakers == nil {
+		ctx.breakers = map[string]*circuitBreaker{}
+	}
+	b, ok := ctx.breakers[outputName]
+	if !ok {
+		b = newCircuitBreaker(ctx.CircuitBreaker)
+		ctx.breakers[outputName] = b
+	}
+	return b
+}
+
+// GetCircuitBreakerState returns the state of the circuit breaker of the output,
+// the circuit is always closed if the circuit breakers are not enabled.
+func (ctx *FunctionContext) GetCircuitBreakerState(outputName string) CircuitBreakerState {
+	if b := ctx.getCircuitBreaker(outputName); b != nil {
+		return b.State()
+	}
+	return CircuitClosed
+}
@@ -0,0 +1,189 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+var funcCtxWithCircuitBreaker = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "circuitBreaker": {
+    "failureThreshold": 2,
+    "cooldown": "50ms"
+  },
+  "outputs": {
+    "binding": {
+      "uri": "echo",
+      "componentName": "echo",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+
+// failingDaprClient fails the binding invocations while fail is set
+type failingDaprClient struct {
+	*fakeDaprClient
+	fail bool
+}
+
+func (c *failingDaprClient) InvokeBinding(ctx context.Context, in *dapr.InvokeBindingRequest) (*dapr.BindingEvent, error) {
+	if c.fail {
+		return nil, errors.New("binding unavailable")
+	}
+	return c.fakeDaprClient.InvokeBinding(ctx, in)
+}
+
+// TestCircuitBreaker tests and verifies the transitions of the circuit breaker of an output
+func TestCircuitBreaker(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithCircuitBreaker); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	client := &failingDaprClient{fakeDaprClient: newFakeDaprClient(), fail: true}
+	ctx.daprClient = client
+
+	// closed -> open once the failures reach the threshold
+	for i := 0; i < 2; i++ {
+		if state := ctx.GetCircuitBreakerState("binding"); state != CircuitClosed {
+			t.Fatalf("Error circuit state before failure %d: %s", i, state)
+		}
+		if _, err := ctx.Send("binding", []byte("hello")); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Error send to the failing output: %v", err)
+		}
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitOpen {
+		t.Fatalf("Error open the circuit: %s", state)
+	}
+
+	// the open circuit rejects the requests without calling the output
+	client.fail = false
+	if _, err := ctx.Send("binding", []byte("hello")); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Error reject the request while open: %v", err)
+	}
+	if client.bindings["echo"] != 0 {
+		t.Fatal("Error call the output while open")
+	}
+
+	// open -> half-open after the cooldown, a failed trial opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitHalfOpen {
+		t.Fatalf("Error half-open the circuit: %s", state)
+	}
+	client.fail = true
+	if _, err := ctx.Send("binding", []byte("hello")); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Error let the trial request through: %v", err)
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitOpen {
+		t.Fatalf("Error reopen the circuit after the failed trial: %s", state)
+	}
+
+	// half-open -> closed after a successful trial
+	time.Sleep(60 * time.Millisecond)
+	client.fail = false
+	if _, err := ctx.Send("binding", []byte("hello")); err != nil {
+		t.Fatalf("Error send the trial request: %v", err)
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitClosed {
+		t.Fatalf("Error close the circuit: %s", state)
+	}
+	if client.bindings["echo"] != 1 {
+		t.Fatalf("Error call the output: got %d calls", client.bindings["echo"])
+	}
+}
+
+// TestCircuitBreakerCancelledSend tests and verifies that the sends abandoned by a cancelled invocation are not recorded
+func TestCircuitBreakerCancelledSend(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithCircuitBreaker); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	client := &failingDaprClient{fakeDaprClient: newFakeDaprClient(), fail: true}
+	ctx.daprClient = client
+
+	if _, err := ctx.Send("binding", []byte("hello")); err == nil {
+		t.Fatal("Error send to the failing output")
+	}
+
+	// the cancelled send neither resets nor adds to the failures of the output
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetNativeContext(cancelled)
+	if _, err := ctx.Send("binding", []byte("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error send in the cancelled invocation: %v", err)
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitClosed {
+		t.Fatalf("Error keep the circuit closed after the cancelled send: %s", state)
+	}
+	if client.bindings["echo"] != 0 {
+		t.Fatal("Error call the output in the cancelled invocation")
+	}
+
+	ctx.SetNativeContext(context.Background())
+	if _, err := ctx.Send("binding", []byte("hello")); err == nil {
+		t.Fatal("Error send to the failing output")
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitOpen {
+		t.Fatalf("Error open the circuit: %s", state)
+	}
+
+	// the cancelled trial request does not hold the half-open circuit
+	time.Sleep(60 * time.Millisecond)
+	ctx.SetNativeContext(cancelled)
+	if _, err := ctx.Send("binding", []byte("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error send the trial request in the cancelled invocation: %v", err)
+	}
+	ctx.SetNativeContext(context.Background())
+	client.fail = false
+	if _, err := ctx.Send("binding", []byte("hello")); err != nil {
+		t.Fatalf("Error send the trial request: %v", err)
+	}
+	if state := ctx.GetCircuitBreakerState("binding"); state != CircuitClosed {
+		t.Fatalf("Error close the circuit: %s", state)
+	}
+}
+
+// TestCircuitBreakerConfig tests and verifies the parsing of the circuit breaker configuration
+func TestCircuitBreakerConfig(t *testing.T) {
+	c := &CircuitBreakerConfig{}
+	if err := c.parse(); err != nil {
+		t.Fatalf("Error parse the default config: %v", err)
+	}
+	if c.FailureThreshold != defaultCircuitFailureThreshold || c.cooldown != defaultCircuitCooldown {
+		t.Fatalf("Error apply the defaults: %+v", c)
+	}
+
+	for _, c := range []*CircuitBreakerConfig{{FailureThreshold: -1}, {Cooldown: "soon"}} {
+		if err := c.parse(); err == nil {
+			t.Fatalf("Error accept the invalid config: %+v", c)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// UnmarshalCloudEventData decodes the data of the cloudevent into v according to its data content type,
+// v must be a non-nil pointer. The data is decoded with the decoder registered for its content type if any.
+// Protobuf-encoded data is decoded with UnmarshalProtoData instead.
+func UnmarshalCloudEventData(ce cloudevents.Event, v interface{}) error {
+	if len(ce.Data()) == 0 {
+		return fmt.Errorf("the cloudevent %s has no data", ce.ID())
+	}
+	if decoder := SelectDecoder(ce.DataContentType()); decoder != nil {
+		if err := decoder.Unmarshal(ce.Data(), v); err != nil {
+			return fmt.Errorf("failed to decode the %q data of the cloudevent %s into %T: %w", ce.DataContentType(), ce.ID(), v, err)
+		}
+		return nil
+	}
+	if err := ce.DataAs(v); err != nil {
+		return fmt.Errorf("failed to decode the %q data of the cloudevent %s into %T: %w", ce.DataContentType(), ce.ID(), v, err)
+	}
+	return nil
+}
+
+// UnmarshalContextCloudEventData decodes the data of the cloudevent received by the function into v.
+func UnmarshalContextCloudEventData(ctx Context, v interface{}) error {
+	ce := ctx.GetCloudEvent()
+	if ce == nil {
+		return errors.New("the function is not invoked with a cloudevent")
+	}
+	return UnmarshalCloudEventData(*ce, v)
+}
@@ -0,0 +1,60 @@
+package context
+
+import (
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+type order struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+func newOrderEvent(t *testing.T, data string) cloudevents.Event {
+	ce := cloudevents.NewEvent()
+	ce.SetID("order-1")
+	ce.SetType("order.created")
+	ce.SetSource("test")
+	if err := ce.SetData(cloudevents.ApplicationJSON, []byte(data)); err != nil {
+		t.Fatalf("Error set cloudevent data: %v", err)
+	}
+	return ce
+}
+
+func TestUnmarshalCloudEventData(t *testing.T) {
+	ce := newOrderEvent(t, `{"id":"o-1","quantity":3}`)
+
+	var o order
+	if err := UnmarshalCloudEventData(ce, &o); err != nil {
+		t.Fatalf("Error unmarshal cloudevent data: %v", err)
+	}
+	if o.ID != "o-1" || o.Quantity != 3 {
+		t.Fatalf("Error unmarshal cloudevent data: got %+v", o)
+	}
+
+	if err := UnmarshalCloudEventData(newOrderEvent(t, `{"id":`), &o); err == nil {
+		t.Fatal("Error unmarshal malformed cloudevent data")
+	}
+	if err := UnmarshalCloudEventData(cloudevents.NewEvent(), &o); err == nil {
+		t.Fatal("Error unmarshal cloudevent without data")
+	}
+}
+
+func TestUnmarshalContextCloudEventData(t *testing.T) {
+	ctx := &FunctionContext{Event: &EventRequest{}}
+
+	var o order
+	if err := UnmarshalContextCloudEventData(ctx, &o); err == nil {
+		t.Fatal("Error unmarshal data without a cloudevent")
+	}
+
+	ce := newOrderEvent(t, `{"id":"o-2","quantity":1}`)
+	ctx.SetEvent("", &ce)
+	if err := UnmarshalContextCloudEventData(ctx, &o); err != nil {
+		t.Fatalf("Error unmarshal cloudevent data: %v", err)
+	}
+	if o.ID != "o-2" || o.Quantity != 1 {
+		t.Fatalf("Error unmarshal cloudevent data: got %+v", o)
+	}
+}
@@ -0,0 +1,166 @@
+package context
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes the structured result of a function into the given content type.
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+}
+
+// Decoder decodes the payload of the given content type received by a function.
+type Decoder interface {
+	ContentType() string
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+var (
+	codecsMu sync.RWMutex
+	// the first codec is the default one
+	codecs   = []Codec{jsonCodec{}, xmlCodec{}}
+	decoders = []Decoder{jsonCodec{}, xmlCodec{}}
+)
+
+// RegisterCodec registers the codec, replacing the codec registered for the same content type.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for i, codec := range codecs {
+		if codec.ContentType() == c.ContentType() {
+			codecs[i] = c
+			return
+		}
+	}
+	codecs = append(codecs, c)
+}
+
+// RegisterDecoder registers the decoder, replacing the decoder registered for the same content type.
+func RegisterDecoder(d Decoder) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for i, decoder := range decoders {
+		if decoder.ContentType() == d.ContentType() {
+			decoders[i] = d
+			return
+		}
+	}
+	decoders = append(decoders, d)
+}
+
+// SelectDecoder selects the decoder registered for the content type, ignoring its parameters,
+// nil if there is none.
+func SelectDecoder(contentType string) Decoder {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	for _, decoder := range decoders {
+		if decoder.ContentType() == mediaType {
+			return decoder
+		}
+	}
+	return nil
+}
+
+// UnmarshalData decodes the payload into v with the decoder selected for its content type.
+func UnmarshalData(contentType string, data []byte, v interface{}) error {
+	decoder := SelectDecoder(contentType)
+	if decoder == nil {
+		return fmt.Errorf("no decoder for the content type %q", contentType)
+	}
+	return decoder.Unmarshal(data, v)
+}
+
+// NegotiateCodec selects the codec matching the Accept header with the highest quality,
+// falling back to the JSON codec if none of the accepted content types is supported.
+func NegotiateCodec(accept string) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, r := range ranges {
+		for _, codec := range codecs {
+			if matchMediaType(r.mediaType, codec.ContentType()) {
+				return codec
+			}
+		}
+	}
+	return codecs[0]
+}
+
+func matchMediaType(mediaRange string, contentType string) bool {
+	if mediaRange == "*/*" || mediaRange == contentType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
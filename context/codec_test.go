@@ -0,0 +1,23 @@
+package context
+
+import (
+	"testing"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                 "application/json",
+		"application/json": "application/json",
+		"application/xml":  "application/xml",
+		"text/csv":         "application/json",
+		"*/*":              "application/json",
+		"application/*":    "application/json",
+		"application/json;q=0.5, application/xml": "application/xml",
+		"application/xml;q=0, application/json":   "application/json",
+		"text/csv, application/xml;q=0.8":         "application/xml",
+	} {
+		if got := NegotiateCodec(accept).ContentType(); got != want {
+			t.Fatalf("Error negotiate codec for %q: got %s, want %s", accept, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"k8s.io/klog/v2"
+)
+
+// GetConfiguration returns the values of the given keys from the configuration store,
+// all the items of the store are returned if no keys are given.
+func (ctx *FunctionContext) GetConfiguration(storeName string, keys []string) (map[string]string, error) {
+	client, err := ctx.getDaprGRPCClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.GetConfigurationRequest{
+		StoreName: storeName,
+		Keys:      keys,
+	}
+	resp, err := client.GetConfigurationAlpha1(withDaprAPIToken(context.Background()), req)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[string]string{}
+	for _, item := range resp.GetItems() {
+		items[item.GetKey()] = item.GetValue()
+	}
+	return items, nil
+}
+
+// SubscribeConfiguration subscribes to the updates of the given keys in the configuration store,
+// the handler is called with the updated items until the dapr client is destroyed or the base context is done.
+func (ctx *FunctionContext) SubscribeConfiguration(storeName string, keys []string, handler func(map[string]string)) error {
+	if handler == nil {
+		return errors.New("configuration handler required")
+	}
+
+	client, err := ctx.getDaprGRPCClient()
+	if err != nil {
+		return err
+	}
+
+	subCtx, cancel := context.WithCancel(withDaprAPIToken(ctx.GetBaseContext()))
+	req := &pb.SubscribeConfigurationRequest{
+		StoreName: storeName,
+		Keys:      keys,
+	}
+	stream, err := client.SubscribeConfigurationAlpha1(subCtx, req)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	ctx.mu.Lock()
+	ctx.configCancels = append(ctx.configCancels, cancel)
+	ctx.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF && subCtx.Err() == nil {
+					klog.Errorf("failed to receive configuration updates from store %s: %v", storeName, err)
+				}
+				return
+			}
+
+			items := map[string]string{}
+			for _, item := range resp.GetItems() {
+				items[item.GetKey()] = item.GetValue()
+			}
+			handler(items)
+		}
+	}()
+	return nil
+}
+
+// cancelConfigurationSubscriptions stops receiving the updates of all the configuration subscriptions.
+func (ctx *FunctionContext) cancelConfigurationSubscriptions() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	for _, cancel := range ctx.configCancels {
+		cancel()
+	}
+	ctx.configCancels = nil
+}
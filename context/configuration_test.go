@@ -0,0 +1,126 @@
+package context
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	commonv1 "github.com/dapr/dapr/pkg/proto/common/v1"
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// fakeConfigurationStore serves the configuration items from memory
+type fakeConfigurationStore struct {
+	items   map[string]string
+	updates chan map[string]string
+	closed  chan struct{}
+}
+
+type fakeConfigurationStream struct {
+	grpc.ClientStream
+	ctx   context.Context
+	store *fakeConfigurationStore
+}
+
+func (s *fakeConfigurationStore) GetConfigurationAlpha1(ctx context.Context, in *pb.GetConfigurationRequest, opts ...grpc.CallOption) (*pb.GetConfigurationResponse, error) {
+	return &pb.GetConfigurationResponse{Items: s.toItems(s.items, in.Keys)}, nil
+}
+
+func (s *fakeConfigurationStore) SubscribeConfigurationAlpha1(ctx context.Context, in *pb.SubscribeConfigurationRequest, opts ...grpc.CallOption) (pb.Dapr_SubscribeConfigurationAlpha1Client, error) {
+	return &fakeConfigurationStream{ctx: ctx, store: s}, nil
+}
+
+func (s *fakeConfigurationStore) GetMetadata(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*pb.GetMetadataResponse, error) {
+	return &pb.GetMetadataResponse{}, nil
+}
+
+func (s *fakeConfigurationStream) Recv() (*pb.SubscribeConfigurationResponse, error) {
+	select {
+	case items := <-s.store.updates:
+		return &pb.SubscribeConfigurationResponse{Items: s.store.toItems(items, nil)}, nil
+	case <-s.ctx.Done():
+		close(s.store.closed)
+		return nil, io.EOF
+	}
+}
+
+func (s *fakeConfigurationStore) toItems(items map[string]string, keys []string) []*commonv1.ConfigurationItem {
+	var result []*commonv1.ConfigurationItem
+	for k, v := range items {
+		if len(keys) > 0 && !hasPlugin(keys, k) {
+			continue
+		}
+		result = append(result, &commonv1.ConfigurationItem{Key: k, Value: v})
+	}
+	return result
+}
+
+// TestConfiguration tests and verifies the helpers of the dapr configuration building block
+func TestConfiguration(t *testing.T) {
+	store := &fakeConfigurationStore{
+		items: map[string]string{
+			"k1": "v1",
+			"k2": "v2",
+		},
+		updates: make(chan map[string]string),
+		closed:  make(chan struct{}),
+	}
+	ctx := &FunctionContext{grpcClient: store}
+
+	items, err := ctx.GetConfiguration("store", []string{"k1"})
+	if err != nil {
+		t.Fatalf("Error get configuration: %v", err)
+	}
+	if len(items) != 1 || items["k1"] != "v1" {
+		t.Fatalf("Error get configuration: got %v", items)
+	}
+
+	received := make(chan map[string]string, 1)
+	if err := ctx.SubscribeConfiguration("store", []string{"k2"}, func(items map[string]string) {
+		received <- items
+	}); err != nil {
+		t.Fatalf("Error subscribe configuration: %v", err)
+	}
+
+	store.updates <- map[string]string{"k2": "v3"}
+	select {
+	case items := <-received:
+		if items["k2"] != "v3" {
+			t.Fatalf("Error receive configuration update: got %v", items)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Error receive configuration update: timeout")
+	}
+
+	// The subscriptions are torn down along with the dapr client
+	ctx.DestroyDaprClient()
+	select {
+	case <-store.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Error tear down configuration subscription")
+	}
+}
+
+// TestConfigurationSubscriptionShutdown tests and verifies that the subscriptions end along with the base context
+func TestConfigurationSubscriptionShutdown(t *testing.T) {
+	store := &fakeConfigurationStore{
+		updates: make(chan map[string]string),
+		closed:  make(chan struct{}),
+	}
+	base, cancel := context.WithCancel(context.Background())
+	ctx := &FunctionContext{grpcClient: store, baseCtx: base, baseCancel: cancel}
+
+	if err := ctx.SubscribeConfiguration("store", []string{"k1"}, func(items map[string]string) {}); err != nil {
+		t.Fatalf("Error subscribe configuration: %v", err)
+	}
+
+	cancel()
+	select {
+	case <-store.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Error end configuration subscription on shutdown")
+	}
+}
@@ -1,26 +1,33 @@
 package context
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/dapr/go-sdk/service/common"
+	"github.com/xeipuuv/gojsonschema"
 	"k8s.io/klog/v2"
 
 	dapr "github.com/dapr/go-sdk/client"
 )
 
 var (
-	clientGRPCPort         string
+	newDaprClient          = dapr.NewClientWithPort
 	bindingQueueComponents = map[string]bool{
 		"bindings.kafka":                  true,
 		"bindings.rabbitmq":               true,
@@ -35,25 +42,37 @@ var (
 )
 
 const (
-	TestModeEnvName                           = "TEST_MODE"
-	FunctionContextEnvName                    = "FUNC_CONTEXT"
-	PodNameEnvName                            = "POD_NAME"
-	PodNamespaceEnvName                       = "POD_NAMESPACE"
-	ModeEnvName                               = "CONTEXT_MODE"
-	Async                        Runtime      = "Async"
-	Knative                      Runtime      = "Knative"
-	OpenFuncBinding              ResourceType = "bindings"
-	OpenFuncTopic                ResourceType = "pubsub"
-	Success                                   = 200
-	InternalError                             = 500
-	defaultPort                               = "8080"
-	daprSidecarGRPCPort                       = "50001"
-	TracingProviderSkywalking                 = "skywalking"
-	TracingProviderOpentelemetry              = "opentelemetry"
-	KubernetesMode                            = "kubernetes"
-	SelfHostMode                              = "self-host"
-	TestModeOn                                = "on"
-	innerEventTypePrefix                      = "io.openfunction.function"
+	TestModeEnvName                                  = "TEST_MODE"
+	FunctionContextEnvName                           = "FUNC_CONTEXT"
+	PodNameEnvName                                   = "POD_NAME"
+	PodNamespaceEnvName                              = "POD_NAMESPACE"
+	InstanceNameEnvName                              = "INSTANCE_NAME"
+	InstanceNamespaceEnvName                         = "INSTANCE_NAMESPACE"
+	TracingFailOpenEnvName                           = "TRACING_FAIL_OPEN"
+	DisabledPluginsEnvName                           = "DISABLED_PLUGINS"
+	LogLevelEnvName                                  = "LOG_LEVEL"
+	LogFormatEnvName                                 = "LOG_FORMAT"
+	ModeEnvName                                      = "CONTEXT_MODE"
+	Async                               Runtime      = "Async"
+	Knative                             Runtime      = "Knative"
+	OpenFuncBinding                     ResourceType = "bindings"
+	OpenFuncTopic                       ResourceType = "pubsub"
+	OpenFuncService                     ResourceType = "service"
+	Success                                          = 200
+	InternalError                                    = 500
+	defaultPort                                      = "8080"
+	daprSidecarGRPCPort                              = "50001"
+	TracingProviderSkywalking                        = "skywalking"
+	TracingProviderOpentelemetry                     = "opentelemetry"
+	KubernetesMode                                   = "kubernetes"
+	SelfHostMode                                     = "self-host"
+	TestModeOn                                       = "on"
+	RoundRobinStrategy                               = "round-robin"
+	BroadcastStrategy                                = "broadcast"
+	innerEventTypePrefix                             = "io.openfunction.function"
+	contentTypeMetadataKey                           = "Content-Type"
+	defaultServiceInvocationVerb                     = "post"
+	defaultServiceInvocationContentType              = "application/json"
 )
 
 type Runtime string
@@ -73,9 +92,15 @@ type RuntimeContext interface {
 	// GetName returns the function's name.
 	GetName() string
 
+	// GetVersion returns the function's version.
+	GetVersion() string
+
 	// GetMode returns the operating environment mode of the function.
 	GetMode() string
 
+	// GetBaseContext returns the root context from which the native context of every invocation is derived.
+	GetBaseContext() context.Context
+
 	// GetContext returns the pointer of raw OpenFunction FunctionContext object.
 	GetContext() *FunctionContext
 
@@ -88,19 +113,51 @@ type RuntimeContext interface {
 	// HasOutputs detects if the function has any output targets.
 	HasOutputs() bool
 
+	// HasInput detects if the function has an input source with the given name.
+	HasInput(name string) bool
+
+	// HasOutput detects if the function has an output target with the given name.
+	HasOutput(name string) bool
+
 	// InitDaprClientIfNil detects whether the dapr client in the current FunctionContext has been initialized,
-	// and initializes it if it has not been initialized.
-	InitDaprClientIfNil()
+	// and initializes it if it has not been initialized, an error is returned if the client cannot be created.
+	InitDaprClientIfNil() error
 
 	// DestroyDaprClient destroys the dapr client when the function is executed with an exception.
 	DestroyDaprClient()
 
+	// CheckDaprHealth detects if the dapr sidecar is reachable and reconnects the dapr client if it is not.
+	CheckDaprHealth() error
+
+	// FlushBufferedSenders flushes the messages held by the buffered senders of the function.
+	FlushBufferedSenders()
+
+	// CloseBufferedSenders flushes the messages still held by the buffered senders once the function stops serving.
+	CloseBufferedSenders()
+
 	// GetPrePlugins returns a list of plugin names for the previous phase of function execution.
 	GetPrePlugins() []string
 
 	// GetPostPlugins returns a list of plugin names for the post phase of function execution.
 	GetPostPlugins() []string
 
+	// GetPluginConfig returns the raw configuration of the plugin, nil if there is none.
+	GetPluginConfig(name string) json.RawMessage
+
+	// GetPluginsHookTimeout returns the maximum duration of a single plugin hook, zero means no limit.
+	GetPluginsHookTimeout() time.Duration
+
+	// GetPluginHookTimeout returns the maximum duration of the hooks of the plugin,
+	// its own timeout if it has one, or else the plugins hook timeout.
+	GetPluginHookTimeout(name string) time.Duration
+
+	// GetHookContext returns the context of the running plugin hook, it is done once the hook exceeds its timeout
+	// or the invocation is cancelled. It is the native context if no hook is running.
+	GetHookContext() context.Context
+
+	// SetHookContext sets the context of the running plugin hook, nil once the hook returns.
+	SetHookContext(c context.Context)
+
 	// GetRuntime returns the Runtime.
 	GetRuntime() Runtime
 
@@ -113,12 +170,71 @@ type RuntimeContext interface {
 	// GetHttpPattern returns the path of the server listening in Knative runtime mode.
 	GetHttpPattern() string
 
+	// HasHttpSchema detects if a JSON schema is set for the body of http requests.
+	HasHttpSchema() bool
+
+	// ValidateHttpPayload validates the body of http requests against the http JSON schema, if any.
+	ValidateHttpPayload(data []byte) error
+
+	// GetHttpMethods returns the HTTP methods allowed in Knative runtime mode, empty means all methods are allowed.
+	GetHttpMethods() []string
+
+	// GetHttpCacheTTL returns how long the responses of the http function are cached, 0 means they are not cached.
+	GetHttpCacheTTL() time.Duration
+
 	// SetSyncRequest sets the native http.ResponseWriter and *http.Request when an http request is received.
 	SetSyncRequest(w http.ResponseWriter, r *http.Request)
 
+	// GetIdempotencyKey returns the idempotency key of the current event,
+	// empty if the idempotency is not enabled for its input or the event carries no key.
+	GetIdempotencyKey() string
+
+	// IsProcessed detects if the event with the idempotency key has already been processed by the current input.
+	IsProcessed(key string) (bool, error)
+
+	// MarkProcessed records the idempotency key of the processed event of the current input until its TTL expires.
+	MarkProcessed(key string) error
+
+	// SetRequestID sets the correlation id of the request being processed.
+	SetRequestID(id string)
+
+	// GetRequestID returns the correlation id of the request being processed.
+	GetRequestID() string
+
+	// GetErrorResponseFormatter returns the formatter of the error responses generated by the runtime.
+	GetErrorResponseFormatter() ErrorResponseFormatter
+
+	// SetSendTracer sets the tracer of the sends to the outputs.
+	SetSendTracer(t SendTracer)
+
+	// GetCircuitBreakerState returns the state of the circuit breaker of the output,
+	// the circuit is always closed if the circuit breakers are not enabled.
+	GetCircuitBreakerState(outputName string) CircuitBreakerState
+
+	// RecordPluginTiming records the duration of the hook of the plugin in the phase.
+	RecordPluginTiming(pluginName string, phase string, d time.Duration)
+
+	// GetPluginTimings returns the durations of the plugin hooks run in the current invocation so far.
+	GetPluginTimings() []PluginTiming
+
+	// ResetPluginTimings clears the durations of the plugin hooks at the start of an invocation.
+	ResetPluginTimings()
+
+	// Set stores the value under the key for the rest of the invocation.
+	Set(key string, v interface{})
+
+	// Value returns the value stored under the key in the current invocation.
+	Value(key string) (interface{}, bool)
+
+	// ResetValues clears the values stored in the context at the start of an invocation.
+	ResetValues()
+
 	// SetEvent sets the name of the input source and the native event when an event request is received.
 	SetEvent(inputName string, event interface{})
 
+	// SetTopicEventMetadata sets the metadata of the current topic event.
+	SetTopicEventMetadata(metadata map[string]string)
+
 	// GetInputs returns the mapping relationship of *Input.
 	GetInputs() map[string]*Input
 
@@ -128,40 +244,189 @@ type RuntimeContext interface {
 	// GetSyncRequest returns the pointer of SyncRequest.
 	GetSyncRequest() *SyncRequest
 
+	// GetPathParam returns the value of the path parameter captured from the http pattern.
+	GetPathParam(name string) string
+
+	// GetRequestPath returns the full path of the http request, empty if not in an http invocation.
+	GetRequestPath() string
+
+	// GetRequestHeader returns the value of the header of the http request, empty if not in an http invocation.
+	GetRequestHeader(name string) string
+
+	// GetRequestHeaders returns the headers of the http request, empty if not in an http invocation.
+	GetRequestHeaders() http.Header
+
+	// SetResponseHeader sets the header of the http response, it is a no-op if not in an http invocation.
+	SetResponseHeader(name, value string)
+
+	// ParseForm parses the url query and the url-encoded form of the http request.
+	ParseForm() (url.Values, error)
+
+	// ParseMultipart parses the multipart form of the http request, storing up to maxMemory bytes of the files in memory.
+	ParseMultipart(maxMemory int64) (*multipart.Form, error)
+
 	// GetBindingEvent returns the pointer of common.BindingEvent.
 	GetBindingEvent() *common.BindingEvent
 
 	// GetTopicEvent returns the pointer of common.TopicEvent.
 	GetTopicEvent() *common.TopicEvent
 
+	// GetInvocationEvent returns the pointer of common.InvocationEvent.
+	GetInvocationEvent() *common.InvocationEvent
+
+	// GetCronTrigger returns the tick of the current event, nil if the event does not come from a cron input.
+	GetCronTrigger() *CronTrigger
+
 	// GetCloudEvent returns the pointer of v2.Event.
 	GetCloudEvent() *cloudevents.Event
 
+	// GetCloudEventResponse returns the pointer of v2.Event returned by the function as the response.
+	GetCloudEventResponse() *cloudevents.Event
+
 	// GetInnerEvent returns the InnerEvent.
 	GetInnerEvent() InnerEvent
 
+	// GetInputName returns the name of the input the current event comes from.
+	GetInputName() string
+
+	// GetEventType returns the source of the current event, based on which event is set.
+	GetEventType() EventType
+
+	// GetRawData returns the payload of the current event as received, whatever its source.
+	GetRawData() []byte
+
 	// WithOut adds the FunctionOut object to the RuntimeContext.
 	WithOut(out *FunctionOut) RuntimeContext
 
 	// WithError adds the error state to the RuntimeContext.
 	WithError(err error) RuntimeContext
 
-	// GetPodName returns the name of the pod the function is running on.
+	// WithCloudEventResponse adds the response cloudevent to the RuntimeContext.
+	WithCloudEventResponse(ce *cloudevents.Event) RuntimeContext
+
+	// GetPodName returns the name of the pod the function is running on,
+	// or the optional instance name in self-host mode.
 	GetPodName() string
 
-	// GetPodNamespace returns the namespace of the pod the function is running on.
+	// GetPodNamespace returns the namespace of the pod the function is running on,
+	// or the optional instance namespace in self-host mode.
 	GetPodNamespace() string
 
 	// GetPluginsTracingCfg returns the TracingConfig interface.
 	GetPluginsTracingCfg() TracingConfig
+
+	// PublishToTopic publishes the data to the topic of the pubsub component of the input.
+	PublishToTopic(input *Input, topic string, data []byte) error
+
+	// DeliverReply hands the topic event over to the SendAndWait call awaiting it, if it is a reply.
+	DeliverReply() bool
+
+	// IsRequeued detects if the current event has been requeued by the function, so that it is acked.
+	IsRequeued() bool
 }
 
 type Context interface {
 	NativeContext
 
+	// GetName returns the function's name.
+	GetName() string
+
+	// GetVersion returns the function's version.
+	GetVersion() string
+
+	// Deadline returns the deadline of the native context, ok is false when no deadline is set.
+	Deadline() (deadline time.Time, ok bool)
+
+	// RemainingTime returns the time left until the deadline of the native context,
+	// it is zero when no deadline is set or the deadline has passed.
+	RemainingTime() time.Duration
+
 	// Send provides the ability to allow the user to send data to a specified output target.
 	Send(outputName string, data []byte) ([]byte, error)
 
+	// SendWithResponse sends data to a specified output target and returns both the data and metadata of the response.
+	SendWithResponse(outputName string, data []byte) (*BindingResult, error)
+
+	// SendToGroup distributes data across the output targets of the specified output group.
+	SendToGroup(groupName string, data []byte) ([]byte, error)
+
+	// Set stores the value under the key for the rest of the invocation, to share data with the plugins.
+	Set(key string, v interface{})
+
+	// Value returns the value stored under the key in the current invocation.
+	Value(key string) (interface{}, bool)
+
+	// SendMatching sends data to every output matching the predicate, and returns the result of each of them.
+	SendMatching(data []byte, match func(name string, o *Output) bool) (map[string]error, error)
+
+	// Publish publishes the data to the topic of the pubsub component through dapr, without an output declared for it.
+	Publish(component, topic string, data []byte, metadata map[string]string) error
+
+	// RequeueWithDelay republishes the payload of the current topic event to its topic, to be delivered after the delay,
+	// and acks the original event whatever the function returns.
+	RequeueWithDelay(delay time.Duration) error
+
+	// Counter returns the application counter of the name and the tags, served on /metrics.
+	Counter(name string, tags map[string]string) Counter
+
+	// Gauge returns the application gauge of the name and the tags, served on /metrics.
+	Gauge(name string, tags map[string]string) Gauge
+
+	// Summary returns the application summary of the name and the tags, served on /metrics.
+	Summary(name string, tags map[string]string) Observer
+
+	// SendAndWait publishes data to the specified pubsub output and waits for the correlated reply on the reply topic.
+	SendAndWait(outputName string, data []byte, replyTopic string, timeout time.Duration) ([]byte, error)
+
+	// GetConfiguration returns the items of the specified keys from the dapr configuration store.
+	GetConfiguration(storeName string, keys []string) (map[string]string, error)
+
+	// SubscribeConfiguration watches the specified keys of the dapr configuration store for updates.
+	SubscribeConfiguration(storeName string, keys []string, handler func(map[string]string)) error
+
+	// ListBindingOperations returns the operations supported by the specified dapr binding component.
+	ListBindingOperations(component string) ([]string, error)
+
+	// HasInput detects if the function has an input source with the given name.
+	HasInput(name string) bool
+
+	// HasOutput detects if the function has an output target with the given name.
+	HasOutput(name string) bool
+
+	// AddOutput registers the output at runtime, it is validated like the outputs of the function context.
+	AddOutput(name string, out *Output) error
+
+	// RemoveOutput unregisters the output, it is a no-op if the output does not exist.
+	RemoveOutput(name string)
+
+	// GetPathParam returns the value of the path parameter captured from the http pattern.
+	GetPathParam(name string) string
+
+	// GetRequestPath returns the full path of the http request, empty if not in an http invocation.
+	GetRequestPath() string
+
+	// GetRequestHeader returns the value of the header of the http request, empty if not in an http invocation.
+	GetRequestHeader(name string) string
+
+	// GetRequestHeaders returns the headers of the http request, empty if not in an http invocation.
+	GetRequestHeaders() http.Header
+
+	// SetResponseHeader sets the header of the http response, it is a no-op if not in an http invocation.
+	SetResponseHeader(name, value string)
+
+	// ParseForm parses the url query and the url-encoded form of the http request.
+	ParseForm() (url.Values, error)
+
+	// ParseMultipart parses the multipart form of the http request, storing up to maxMemory bytes of the files in memory.
+	ParseMultipart(maxMemory int64) (*multipart.Form, error)
+
+	// GetRequestID returns the correlation id of the request being processed.
+	GetRequestID() string
+
+	// GetCircuitBreakerState returns the state of the circuit breaker of the output,
+	// the circuit is always closed if the circuit breakers are not enabled.
+	GetCircuitBreakerState(outputName string) CircuitBreakerState
+
 	// ReturnOnSuccess returns the Out with a success state.
 	ReturnOnSuccess() Out
 
@@ -177,11 +442,35 @@ type Context interface {
 	// GetTopicEvent returns the pointer of common.TopicEvent.
 	GetTopicEvent() *common.TopicEvent
 
+	// GetTopicEventMetadata returns the metadata of the current topic event, nil if it carries none.
+	GetTopicEventMetadata() map[string]string
+
+	// GetTopicPartition returns the partition of the current topic event, false if it carries no valid partition.
+	GetTopicPartition() (int32, bool)
+
+	// GetTopicOffset returns the offset of the current topic event, false if it carries no valid offset.
+	GetTopicOffset() (int64, bool)
+
+	// GetInvocationEvent returns the pointer of common.InvocationEvent.
+	GetInvocationEvent() *common.InvocationEvent
+
+	// GetCronTrigger returns the tick of the current event, nil if the event does not come from a cron input.
+	GetCronTrigger() *CronTrigger
+
 	// GetCloudEvent returns the pointer of v2.Event.
 	GetCloudEvent() *cloudevents.Event
 
 	// GetInnerEvent returns the InnerEvent.
 	GetInnerEvent() InnerEvent
+
+	// GetInputName returns the name of the input the current event comes from.
+	GetInputName() string
+
+	// GetEventType returns the source of the current event, based on which event is set.
+	GetEventType() EventType
+
+	// GetRawData returns the payload of the current event as received, whatever its source.
+	GetRawData() []byte
 }
 
 type Out interface {
@@ -203,6 +492,23 @@ type Out interface {
 
 	// WithData sets the FunctionOut with new return data.
 	WithData(data []byte) *FunctionOut
+
+	// GetReader returns the reader of the return data in FunctionOut.
+	GetReader() io.Reader
+
+	// WithReader sets the FunctionOut with a reader of the return data, which is streamed to
+	// the http response rather than buffered, it is ignored if the return data is set.
+	WithReader(r io.Reader) *FunctionOut
+
+	// WithMetadata sets the FunctionOut with new metadata.
+	WithMetadata(metadata map[string]string) *FunctionOut
+
+	// GetResult returns the structured result in FunctionOut.
+	GetResult() interface{}
+
+	// WithResult sets the FunctionOut with a structured result,
+	// which is encoded according to the Accept header of http requests.
+	WithResult(result interface{}) *FunctionOut
 }
 
 type TracingConfig interface {
@@ -221,39 +527,82 @@ type TracingConfig interface {
 
 	// GetBaggage returns the baggage of the tracing configuration.
 	GetBaggage() map[string]string
+
+	// GetSamplingRate returns the rate of the sampled traces, from 0 to 1, all the traces are sampled by default.
+	GetSamplingRate() float64
 }
 
 type FunctionContext struct {
-	mu             sync.Mutex
-	Name           string             `json:"name"`
-	Version        string             `json:"version"`
-	RequestID      string             `json:"requestID,omitempty"`
-	Ctx            context.Context    `json:"ctx,omitempty"`
-	Inputs         map[string]*Input  `json:"inputs,omitempty"`
-	Outputs        map[string]*Output `json:"outputs,omitempty"`
-	Runtime        Runtime            `json:"runtime"`
-	Port           string             `json:"port,omitempty"`
-	State          interface{}        `json:"state,omitempty"`
-	Event          *EventRequest      `json:"event,omitempty"`
-	SyncRequest    *SyncRequest       `json:"syncRequest,omitempty"`
-	PrePlugins     []string           `json:"prePlugins,omitempty"`
-	PostPlugins    []string           `json:"postPlugins,omitempty"`
-	PluginsTracing *PluginsTracing    `json:"pluginsTracing,omitempty"`
-	Out            Out                `json:"out,omitempty"`
-	Error          error              `json:"error,omitempty"`
-	HttpPattern    string             `json:"httpPattern,omitempty"`
-	podName        string
-	podNamespace   string
-	daprClient     dapr.Client
-	mode           string
+	mu                 sync.Mutex
+	Name               string                     `json:"name"`
+	Version            string                     `json:"version"`
+	RequestID          string                     `json:"requestID,omitempty"`
+	Ctx                context.Context            `json:"ctx,omitempty"`
+	Inputs             map[string]*Input          `json:"inputs,omitempty"`
+	Outputs            map[string]*Output         `json:"outputs,omitempty"`
+	OutputGroups       map[string]*OutputGroup    `json:"outputGroups,omitempty"`
+	Runtime            Runtime                    `json:"runtime"`
+	Port               string                     `json:"port,omitempty"`
+	State              interface{}                `json:"state,omitempty"`
+	Event              *EventRequest              `json:"event,omitempty"`
+	SyncRequest        *SyncRequest               `json:"syncRequest,omitempty"`
+	PrePlugins         []string                   `json:"prePlugins,omitempty"`
+	PostPlugins        []string                   `json:"postPlugins,omitempty"`
+	ReversePostPlugins bool                       `json:"reversePostPlugins,omitempty"`
+	PluginsHookTimeout string                     `json:"pluginsHookTimeout,omitempty"`
+	PluginHookTimeouts map[string]string          `json:"pluginHookTimeouts,omitempty"`
+	PluginsTracing     *PluginsTracing            `json:"pluginsTracing,omitempty"`
+	PluginsConfig      map[string]json.RawMessage `json:"pluginsConfig,omitempty"`
+	CircuitBreaker     *CircuitBreakerConfig      `json:"circuitBreaker,omitempty"`
+	Out                Out                        `json:"out,omitempty"`
+	Error              error                      `json:"error,omitempty"`
+	HttpPattern        string                     `json:"httpPattern,omitempty"`
+	HttpMethods        []string                   `json:"httpMethods,omitempty"`
+	HttpSchema         string                     `json:"httpSchema,omitempty"`
+	HttpCacheTTL       string                     `json:"httpCacheTTL,omitempty"`
+	podName            string
+	podNamespace       string
+	daprClient         dapr.Client
+	daprGRPCPort       string
+	baseCtx            context.Context
+	baseCancel         context.CancelFunc
+	httpSchema         *gojsonschema.Schema
+	httpCacheTTL       time.Duration
+	senders            []*BufferedSender
+	grpcClient         daprGRPCClient
+	grpcConn           io.Closer
+	configCancels      []context.CancelFunc
+	healthStop         chan struct{}
+	hookTimeout        time.Duration
+	hookTimeouts       map[string]time.Duration
+	hookCtx            context.Context
+	breakers           map[string]*circuitBreaker
+	errorFormatter     ErrorResponseFormatter
+	sendTracer         SendTracer
+	outputSenders      map[string]OutputSender
+	source             *FunctionContext
+	breakersMu         sync.Mutex
+	pluginTimings      []PluginTiming
+	timingsMu          sync.Mutex
+	values             map[string]interface{}
+	valuesMu           sync.Mutex
+	replies            map[string]*pendingReply
+	repliesMu          sync.Mutex
+	outputsMu          sync.RWMutex
+	mode               string
 }
 
 type EventRequest struct {
-	InputName    string               `json:"inputName,omitempty"`
-	BindingEvent *common.BindingEvent `json:"bindingEvent,omitempty"`
-	TopicEvent   *common.TopicEvent   `json:"topicEvent,omitempty"`
-	CloudEvent   *cloudevents.Event   `json:"cloudEventnt,omitempty"`
-	innerEvent   InnerEvent
+	InputName          string                  `json:"inputName,omitempty"`
+	BindingEvent       *common.BindingEvent    `json:"bindingEvent,omitempty"`
+	TopicEvent         *common.TopicEvent      `json:"topicEvent,omitempty"`
+	InvocationEvent    *common.InvocationEvent `json:"invocationEvent,omitempty"`
+	CloudEvent         *cloudevents.Event      `json:"cloudEventnt,omitempty"`
+	CloudEventResponse *cloudevents.Event      `json:"cloudEventResponse,omitempty"`
+	CronTrigger        *CronTrigger            `json:"cronTrigger,omitempty"`
+	innerEvent         InnerEvent
+	topicMetadata      map[string]string
+	requeued           bool
 }
 
 type SyncRequest struct {
@@ -262,10 +611,15 @@ type SyncRequest struct {
 }
 
 type Input struct {
-	Uri           string            `json:"uri,omitempty"`
-	ComponentName string            `json:"componentName"`
-	ComponentType string            `json:"componentType"`
-	Metadata      map[string]string `json:"metadata,omitempty"`
+	Uri            string            `json:"uri,omitempty"`
+	ComponentName  string            `json:"componentName"`
+	ComponentType  string            `json:"componentType"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Schema         string            `json:"schema,omitempty"`
+	schema         *gojsonschema.Schema
+	idempotencyTTL time.Duration
+	retryPolicy    *RetryPolicy
+	filter         []filterCondition
 }
 
 // GetType will be called after the context has been parsed correctly,
@@ -293,19 +647,39 @@ func (o *Output) GetType() ResourceType {
 	return bbt
 }
 
+type BindingResult struct {
+	Data     []byte            `json:"data,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type OutputGroup struct {
+	Outputs  []string `json:"outputs"`
+	Strategy string   `json:"strategy,omitempty"`
+	next     uint32
+}
+
+// Next returns the name of the output that should receive the next message of the round-robin strategy.
+func (g *OutputGroup) Next() string {
+	n := atomic.AddUint32(&g.next, 1)
+	return g.Outputs[(n-1)%uint32(len(g.Outputs))]
+}
+
 type FunctionOut struct {
 	mu       sync.Mutex
 	Code     int               `json:"code"`
 	Data     []byte            `json:"data,omitempty"`
+	Reader   io.Reader         `json:"-"`
+	Result   interface{}       `json:"result,omitempty"`
 	Error    error             `json:"error,omitempty"`
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type PluginsTracing struct {
-	Enable   bool              `json:"enable" yaml:"enable"`
-	Provider *TracingProvider  `json:"provider" yaml:"provider"`
-	Tags     map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
-	Baggage  map[string]string `json:"baggage" yaml:"baggage"`
+	Enable       bool              `json:"enable" yaml:"enable"`
+	Provider     *TracingProvider  `json:"provider" yaml:"provider"`
+	Tags         map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Baggage      map[string]string `json:"baggage" yaml:"baggage"`
+	SamplingRate *float64          `json:"samplingRate,omitempty" yaml:"samplingRate,omitempty"`
 }
 
 type TracingProvider struct {
@@ -316,6 +690,11 @@ type TracingProvider struct {
 type ResponseWriterWrapper struct {
 	http.ResponseWriter
 	statusCode int
+	// body captures the written response, nil unless capturing
+	body *bytes.Buffer
+	// captureLimit caps the captured bytes, 0 means unlimited
+	captureLimit int
+	written      int
 }
 
 func (rww *ResponseWriterWrapper) Status() int {
@@ -327,9 +706,37 @@ func (rww *ResponseWriterWrapper) Header() http.Header {
 }
 
 func (rww *ResponseWriterWrapper) Write(bytes []byte) (int, error) {
+	if rww.body != nil {
+		data := bytes
+		if rww.captureLimit > 0 && rww.body.Len()+len(data) > rww.captureLimit {
+			data = data[:rww.captureLimit-rww.body.Len()]
+		}
+		rww.body.Write(data)
+	}
+	rww.written += len(bytes)
 	return rww.ResponseWriter.Write(bytes)
 }
 
+// Written returns the number of bytes of the response written so far.
+func (rww *ResponseWriterWrapper) Written() int {
+	return rww.written
+}
+
+// Flush flushes the underlying writer if it supports flushing, so that the streamed responses are still flushed.
+func (rww *ResponseWriterWrapper) Flush() {
+	if f, ok := rww.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Body returns the response written so far, nil unless the wrapper is capturing.
+func (rww *ResponseWriterWrapper) Body() []byte {
+	if rww.body == nil {
+		return nil
+	}
+	return rww.body.Bytes()
+}
+
 func (rww *ResponseWriterWrapper) WriteHeader(statusCode int) {
 	rww.statusCode = statusCode
 	rww.ResponseWriter.WriteHeader(statusCode)
@@ -337,57 +744,224 @@ func (rww *ResponseWriterWrapper) WriteHeader(statusCode int) {
 
 func NewResponseWriterWrapper(w http.ResponseWriter, statusCode int) *ResponseWriterWrapper {
 	return &ResponseWriterWrapper{
-		w,
-		statusCode,
+		ResponseWriter: w,
+		statusCode:     statusCode,
+	}
+}
+
+// NewCapturingResponseWriterWrapper returns the wrapper capturing the written response as well.
+func NewCapturingResponseWriterWrapper(w http.ResponseWriter, statusCode int) *ResponseWriterWrapper {
+	return &ResponseWriterWrapper{
+		ResponseWriter: w,
+		statusCode:     statusCode,
+		body:           &bytes.Buffer{},
 	}
 }
 
+// WithCaptureLimit caps the bytes of the response captured, the rest is written without being captured.
+func (rww *ResponseWriterWrapper) WithCaptureLimit(limit int) *ResponseWriterWrapper {
+	rww.captureLimit = limit
+	return rww
+}
+
 func (ctx *FunctionContext) Send(outputName string, data []byte) ([]byte, error) {
+	return ctx.send(ctx.GetNativeContext(), outputName, data)
+}
+
+func (ctx *FunctionContext) SendWithResponse(outputName string, data []byte) (*BindingResult, error) {
+	return ctx.sendWithContext(ctx.GetNativeContext(), outputName, data, nil)
+}
+
+// send sends the data to the output within the native context c and returns the data of the result.
+func (ctx *FunctionContext) send(c context.Context, outputName string, data []byte) ([]byte, error) {
+	result, err := ctx.sendWithContext(c, outputName, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// sendWithContext sends the data to the output within the native context c,
+// the metadata is added to the inner event encapsulating the data.
+func (ctx *FunctionContext) sendWithContext(c context.Context, outputName string, data []byte, metadata map[string]string) (*BindingResult, error) {
 	if !ctx.HasOutputs() {
 		return nil, errors.New("no output")
 	}
 
 	var err error
-	var output *Output
-	var response *dapr.BindingEvent
 	var payload []byte
+	var result *BindingResult
 
-	if v, ok := ctx.Outputs[outputName]; ok {
-		output = v
-	} else {
+	output, ok := ctx.GetOutputs()[outputName]
+	if !ok {
 		return nil, fmt.Errorf("output %s not found", outputName)
 	}
 
+	if b := ctx.getCircuitBreaker(outputName); b != nil {
+		if err := b.allow(); err != nil {
+			return nil, fmt.Errorf("failed to send to output %s: %w", outputName, err)
+		}
+		defer func() {
+			// the sends abandoned by the caller tell nothing about the health of the output
+			if err != nil && c.Err() != nil {
+				b.release()
+				return
+			}
+			b.record(err)
+		}()
+	}
+
+	// the send is abandoned once the invocation is cancelled
+	if err = c.Err(); err != nil {
+		return nil, fmt.Errorf("failed to send to output %s: %w", outputName, err)
+	}
+
+	if end := ctx.startSendSpan(c, outputName, output); end != nil {
+		defer func() {
+			end(err)
+		}()
+	}
+
 	payload = data
 
 	if traceable(output.ComponentType) {
 		ie := NewInnerEvent(ctx)
 		ie.MergeMetadata(ctx.GetInnerEvent())
+		for k, v := range metadata {
+			ie.SetMetadata(k, v)
+		}
 		ie.SetUserData(data)
 		payload = ie.GetCloudEventJSON()
 	}
 
+	result, err = ctx.sendOutput(c, output, payload)
+	return result, err
+}
+
+// sendOutput sends the payload to the output through its sender, or through dapr by default.
+func (ctx *FunctionContext) sendOutput(c context.Context, output *Output, payload []byte) (*BindingResult, error) {
+	if sender := ctx.getOutputSender(output); sender != nil {
+		result, err := sender.SendOutput(c, output, payload)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = &BindingResult{}
+		}
+		return result, nil
+	}
+	client := ctx.getDaprClient()
+	if client == nil {
+		return nil, errors.New("dapr client is not initialized")
+	}
+
+	var err error
+	result := &BindingResult{}
+
 	switch output.GetType() {
 	case OpenFuncTopic:
-		err = ctx.daprClient.PublishEvent(context.Background(), output.ComponentName, output.Uri, payload)
+		err = client.PublishEvent(c, output.ComponentName, output.Uri, payload)
 	case OpenFuncBinding:
+		var response *dapr.BindingEvent
 		in := &dapr.InvokeBindingRequest{
 			Name:      output.ComponentName,
 			Operation: output.Operation,
 			Data:      payload,
 			Metadata:  output.Metadata,
 		}
-		response, err = ctx.daprClient.InvokeBinding(context.Background(), in)
+		response, err = client.InvokeBinding(c, in)
+		if response != nil {
+			result.Data = response.Data
+			result.Metadata = response.Metadata
+		}
+	case OpenFuncService:
+		verb := output.Operation
+		if verb == "" {
+			verb = defaultServiceInvocationVerb
+		}
+		content := &dapr.DataContent{
+			ContentType: output.Metadata[contentTypeMetadataKey],
+			Data:        payload,
+		}
+		if content.ContentType == "" {
+			content.ContentType = defaultServiceInvocationContentType
+		}
+		result.Data, err = client.InvokeMethodWithContent(c, output.ComponentName, output.Uri, verb, content)
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
+
+func (ctx *FunctionContext) SendToGroup(groupName string, data []byte) ([]byte, error) {
+	return ctx.sendToGroup(ctx.GetNativeContext(), groupName, data)
+}
+
+// sendToGroup sends the data to the output group within the native context c.
+func (ctx *FunctionContext) sendToGroup(c context.Context, groupName string, data []byte) ([]byte, error) {
+	group, ok := ctx.OutputGroups[groupName]
+	if !ok {
+		return nil, fmt.Errorf("output group %s not found", groupName)
+	}
+
+	switch group.Strategy {
+	case BroadcastStrategy:
+		var errs []string
+		for _, name := range group.Outputs {
+			if err := c.Err(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				break
+			}
+			if _, err := ctx.send(c, name, data); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to broadcast to output group %s: %s", groupName, strings.Join(errs, "; "))
+		}
+		return nil, nil
+	default:
+		return ctx.send(c, group.Next(), data)
+	}
+}
+
+// SendMatching sends data to every output matching the predicate in the order of their names,
+// the result of each matching output is returned, with an error if none matches or any fails.
+func (ctx *FunctionContext) SendMatching(data []byte, match func(name string, o *Output) bool) (map[string]error, error) {
+	return ctx.sendMatching(ctx.GetNativeContext(), data, match)
+}
 
-	if response != nil {
-		return response.Data, nil
+// sendMatching sends the data to the matching outputs within the native context c.
+func (ctx *FunctionContext) sendMatching(c context.Context, data []byte, match func(name string, o *Output) bool) (map[string]error, error) {
+	var names []string
+	for name, output := range ctx.GetOutputs() {
+		if match(name, output) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no output matched")
+	}
+	sort.Strings(names)
+
+	results := make(map[string]error, len(names))
+	var errs []string
+	for _, name := range names {
+		err := c.Err()
+		if err == nil {
+			_, err = ctx.send(c, name, data)
+		}
+		results[name] = err
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("failed to send to the matching outputs: %s", strings.Join(errs, "; "))
 	}
-	return nil, nil
+	return results, nil
 }
 
 func (ctx *FunctionContext) HasInputs() bool {
@@ -404,6 +978,22 @@ func (ctx *FunctionContext) HasOutputs() bool {
 	return false
 }
 
+func (ctx *FunctionContext) HasInput(name string) bool {
+	if !ctx.HasInputs() {
+		return false
+	}
+	_, ok := ctx.GetInputs()[name]
+	return ok
+}
+
+func (ctx *FunctionContext) HasOutput(name string) bool {
+	if !ctx.HasOutputs() {
+		return false
+	}
+	_, ok := ctx.GetOutputs()[name]
+	return ok
+}
+
 func (ctx *FunctionContext) ReturnOnSuccess() Out {
 	return &FunctionOut{
 		Code: Success,
@@ -416,46 +1006,94 @@ func (ctx *FunctionContext) ReturnOnInternalError() Out {
 	}
 }
 
-func (ctx *FunctionContext) InitDaprClientIfNil() {
+func (ctx *FunctionContext) InitDaprClientIfNil() error {
 	if testMode := os.Getenv(TestModeEnvName); testMode == TestModeOn {
-		return
+		return nil
 	}
 
-	if ctx.daprClient == nil {
-		var err error
-		ctx.mu.Lock()
-		defer ctx.mu.Unlock()
-
-		for attempts := 120; attempts > 0; attempts-- {
-			c, e := dapr.NewClientWithPort(clientGRPCPort)
-			if e == nil {
-				ctx.daprClient = c
-				break
-			}
-			err = e
-			time.Sleep(500 * time.Millisecond)
-		}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 
-		if ctx.daprClient == nil {
+	if ctx.daprClient == nil {
+		c, err := newDaprClient(ctx.getDaprGRPCPort())
+		if err != nil {
 			klog.Errorf("failed to init dapr client: %v", err)
-			panic(err)
+			return err
 		}
+		ctx.daprClient = c
+		ctx.startDaprHealthCheck()
+	}
+	return nil
+}
+
+// getDaprGRPCPort returns the gRPC port of the dapr sidecar, falling back to the default sidecar port.
+func (ctx *FunctionContext) getDaprGRPCPort() string {
+	if ctx.daprGRPCPort == "" {
+		return daprSidecarGRPCPort
 	}
+	return ctx.daprGRPCPort
 }
 
 func (ctx *FunctionContext) DestroyDaprClient() {
+	ctx.stopDaprHealthCheck()
+	ctx.cancelConfigurationSubscriptions()
+	ctx.closeDaprGRPCClient()
+
 	if testMode := os.Getenv(TestModeEnvName); testMode == TestModeOn {
 		return
 	}
 
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	if ctx.daprClient != nil {
-		ctx.mu.Lock()
-		defer ctx.mu.Unlock()
 		ctx.daprClient.Close()
 		ctx.daprClient = nil
 	}
 }
 
+// getDaprClient returns the dapr client, nil if it is not initialized.
+func (ctx *FunctionContext) getDaprClient() dapr.Client {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.daprClient
+}
+
+func (ctx *FunctionContext) FlushBufferedSenders() {
+	ctx.flushBufferedSenders(ctx.GetBaseContext())
+}
+
+// CloseBufferedSenders flushes the messages kept by the buffered senders while the base context is done,
+// within a context outliving the shutdown.
+func (ctx *FunctionContext) CloseBufferedSenders() {
+	ctx.flushBufferedSenders(context.Background())
+}
+
+// flushBufferedSenders flushes the live buffered senders within the context c,
+// the senders keeping messages they could not send are registered again.
+func (ctx *FunctionContext) flushBufferedSenders(c context.Context) {
+	ctx.mu.Lock()
+	senders := ctx.senders
+	ctx.senders = nil
+	ctx.mu.Unlock()
+
+	for _, s := range senders {
+		if err := s.flush(c); err != nil {
+			klog.Errorf("failed to flush buffered sender for output %s: %v", s.outputName, err)
+		}
+	}
+}
+
+func (ctx *FunctionContext) registerBufferedSender(s *BufferedSender) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	for _, sender := range ctx.senders {
+		if sender == s {
+			return
+		}
+	}
+	ctx.senders = append(ctx.senders, s)
+}
+
 func (ctx *FunctionContext) GetPrePlugins() []string {
 	return ctx.PrePlugins
 }
@@ -464,6 +1102,38 @@ func (ctx *FunctionContext) GetPostPlugins() []string {
 	return ctx.PostPlugins
 }
 
+func (ctx *FunctionContext) GetPluginConfig(name string) json.RawMessage {
+	return ctx.PluginsConfig[name]
+}
+
+func (ctx *FunctionContext) GetPluginsHookTimeout() time.Duration {
+	return ctx.hookTimeout
+}
+
+func (ctx *FunctionContext) GetPluginHookTimeout(name string) time.Duration {
+	if timeout, ok := ctx.hookTimeouts[name]; ok {
+		return timeout
+	}
+	return ctx.hookTimeout
+}
+
+func (ctx *FunctionContext) GetHookContext() context.Context {
+	ctx.mu.Lock()
+	c := ctx.hookCtx
+	ctx.mu.Unlock()
+
+	if c == nil {
+		return ctx.GetNativeContext()
+	}
+	return c
+}
+
+func (ctx *FunctionContext) SetHookContext(c context.Context) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.hookCtx = c
+}
+
 func (ctx *FunctionContext) GetRuntime() Runtime {
 	return ctx.Runtime
 }
@@ -476,6 +1146,14 @@ func (ctx *FunctionContext) GetHttpPattern() string {
 	return ctx.HttpPattern
 }
 
+func (ctx *FunctionContext) GetHttpMethods() []string {
+	return ctx.HttpMethods
+}
+
+func (ctx *FunctionContext) GetHttpCacheTTL() time.Duration {
+	return ctx.httpCacheTTL
+}
+
 func (ctx *FunctionContext) GetError() error {
 	return ctx.Error
 }
@@ -485,13 +1163,43 @@ func (ctx *FunctionContext) GetMode() string {
 }
 
 func (ctx *FunctionContext) GetNativeContext() context.Context {
+	if ctx.Ctx == nil {
+		return ctx.GetBaseContext()
+	}
 	return ctx.Ctx
 }
 
+func (ctx *FunctionContext) GetBaseContext() context.Context {
+	if ctx.baseCtx == nil {
+		return context.Background()
+	}
+	return ctx.baseCtx
+}
+
 func (ctx *FunctionContext) SetNativeContext(c context.Context) {
 	ctx.Ctx = c
 }
 
+func (ctx *FunctionContext) Deadline() (time.Time, bool) {
+	return ctx.GetNativeContext().Deadline()
+}
+
+func (ctx *FunctionContext) RemainingTime() time.Duration {
+	return remainingTime(ctx.GetNativeContext())
+}
+
+// remainingTime returns the time left until the deadline of the native context c, zero if there is none.
+func remainingTime(c context.Context) time.Duration {
+	deadline, ok := c.Deadline()
+	if !ok {
+		return 0
+	}
+	if remaining := time.Until(deadline); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
 func (ctx *FunctionContext) SetSyncRequest(w http.ResponseWriter, r *http.Request) {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
@@ -499,39 +1207,85 @@ func (ctx *FunctionContext) SetSyncRequest(w http.ResponseWriter, r *http.Reques
 	ctx.SyncRequest.Request = r
 }
 
+// request returns the http request, nil if not in an http invocation.
+func (sr *SyncRequest) request() *http.Request {
+	if sr == nil {
+		return nil
+	}
+	return sr.Request
+}
+
+func (ctx *FunctionContext) SetRequestID(id string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.RequestID = id
+}
+
+func (ctx *FunctionContext) GetRequestID() string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	// the id of an http request is carried by the request itself
+	if r := ctx.SyncRequest.request(); r != nil {
+		if id := RequestID(r); id != "" {
+			return id
+		}
+	}
+	return ctx.RequestID
+}
+
 func (ctx *FunctionContext) SetEvent(inputName string, event interface{}) {
 	switch t := event.(type) {
 	case *common.BindingEvent:
 		be := event.(*common.BindingEvent)
 		ie := convertEvent(ctx, inputName, be.Data)
-		ctx.setEvent(inputName, be, nil, nil, ie)
+		ctx.setEvent(inputName, be, nil, nil, nil, ie)
+		ctx.setCronTrigger(inputName)
 	case *common.TopicEvent:
 		te := event.(*common.TopicEvent)
 		ie := convertEvent(ctx, inputName, ConvertUserDataToBytes(te.Data))
-		ctx.setEvent(inputName, nil, te, nil, ie)
+		ctx.setEvent(inputName, nil, te, nil, nil, ie)
+	case *common.InvocationEvent:
+		se := event.(*common.InvocationEvent)
+		ie := convertEvent(ctx, inputName, se.Data)
+		ctx.setEvent(inputName, nil, nil, se, nil, ie)
 	case *cloudevents.Event:
 		ce := event.(*cloudevents.Event)
-		ie := convertEvent(ctx, inputName, ce.Data())
-		ctx.setEvent(inputName, nil, nil, ce, ie)
+		var ie InnerEvent
+		if IsProtobufContentType(ce.DataContentType()) {
+			// the protobuf data is passed as is rather than being probed as a JSON inner event
+			ie = convertRawEvent(ctx, inputName, ce.Data())
+		} else {
+			ie = convertEvent(ctx, inputName, ce.Data())
+		}
+		ctx.setEvent(inputName, nil, nil, nil, ce, ie)
 	default:
 		klog.Errorf("failed to resolve event type: %v", t)
 	}
 }
 
-func (ctx *FunctionContext) setEvent(name string, be *common.BindingEvent, te *common.TopicEvent, ce *cloudevents.Event, ie InnerEvent) {
+func (ctx *FunctionContext) setEvent(name string, be *common.BindingEvent, te *common.TopicEvent, se *common.InvocationEvent, ce *cloudevents.Event, ie InnerEvent) {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
 	ctx.Event.InputName = name
 	ctx.Event.BindingEvent = be
 	ctx.Event.TopicEvent = te
+	ctx.Event.InvocationEvent = se
 	ctx.Event.CloudEvent = ce
+	ctx.Event.CloudEventResponse = nil
+	ctx.Event.CronTrigger = nil
 	ctx.Event.innerEvent = ie
+	ctx.Event.topicMetadata = nil
+	ctx.Event.requeued = false
 }
 
 func (ctx *FunctionContext) GetName() string {
 	return ctx.Name
 }
 
+func (ctx *FunctionContext) GetVersion() string {
+	return ctx.Version
+}
+
 func (ctx *FunctionContext) GetContext() *FunctionContext {
 	return ctx
 }
@@ -541,6 +1295,8 @@ func (ctx *FunctionContext) GetInputs() map[string]*Input {
 }
 
 func (ctx *FunctionContext) GetOutputs() map[string]*Output {
+	ctx.outputsMu.RLock()
+	defer ctx.outputsMu.RUnlock()
 	return ctx.Outputs
 }
 
@@ -548,6 +1304,18 @@ func (ctx *FunctionContext) GetPodName() string {
 	return ctx.podName
 }
 
+// instanceName returns the name of the pod, or the hostname in self-host mode when no instance name is set.
+func (ctx *FunctionContext) instanceName() string {
+	if ctx.podName == "" && ctx.mode == SelfHostMode {
+		hostname, err := os.Hostname()
+		if err != nil {
+			klog.Warningf("failed to get hostname: %v", err)
+		}
+		return hostname
+	}
+	return ctx.podName
+}
+
 func (ctx *FunctionContext) GetPodNamespace() string {
 	return ctx.podNamespace
 }
@@ -556,6 +1324,58 @@ func (ctx *FunctionContext) GetSyncRequest() *SyncRequest {
 	return ctx.SyncRequest
 }
 
+func (ctx *FunctionContext) GetPathParam(name string) string {
+	return ctx.SyncRequest.pathParam(name)
+}
+
+func (ctx *FunctionContext) GetRequestPath() string {
+	return ctx.SyncRequest.path()
+}
+
+func (ctx *FunctionContext) GetRequestHeader(name string) string {
+	return ctx.GetRequestHeaders().Get(name)
+}
+
+func (ctx *FunctionContext) GetRequestHeaders() http.Header {
+	return ctx.SyncRequest.headers()
+}
+
+func (ctx *FunctionContext) SetResponseHeader(name, value string) {
+	ctx.SyncRequest.setResponseHeader(name, value)
+}
+
+func (sr *SyncRequest) pathParam(name string) string {
+	r := sr.request()
+	if r == nil {
+		return ""
+	}
+	return PathParam(r, name)
+}
+
+func (sr *SyncRequest) path() string {
+	r := sr.request()
+	if r == nil {
+		return ""
+	}
+	return r.URL.Path
+}
+
+func (sr *SyncRequest) headers() http.Header {
+	r := sr.request()
+	if r == nil {
+		return http.Header{}
+	}
+	return r.Header
+}
+
+func (sr *SyncRequest) setResponseHeader(name, value string) {
+	if sr == nil || sr.ResponseWriter == nil {
+		klog.Warningf("failed to set response header %s: not in an http invocation", name)
+		return
+	}
+	sr.ResponseWriter.Header().Set(name, value)
+}
+
 func (ctx *FunctionContext) GetBindingEvent() *common.BindingEvent {
 	return ctx.Event.BindingEvent
 }
@@ -564,10 +1384,24 @@ func (ctx *FunctionContext) GetTopicEvent() *common.TopicEvent {
 	return ctx.Event.TopicEvent
 }
 
+func (ctx *FunctionContext) GetInvocationEvent() *common.InvocationEvent {
+	return ctx.Event.InvocationEvent
+}
+
 func (ctx *FunctionContext) GetCloudEvent() *cloudevents.Event {
 	return ctx.Event.CloudEvent
 }
 
+func (ctx *FunctionContext) GetCloudEventResponse() *cloudevents.Event {
+	return ctx.Event.CloudEventResponse
+}
+
+func (ctx *FunctionContext) GetInputName() string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.Event.InputName
+}
+
 func (ctx *FunctionContext) GetInnerEvent() InnerEvent {
 	return ctx.Event.innerEvent
 }
@@ -590,6 +1424,13 @@ func (ctx *FunctionContext) WithError(err error) RuntimeContext {
 	return ctx
 }
 
+func (ctx *FunctionContext) WithCloudEventResponse(ce *cloudevents.Event) RuntimeContext {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.Event.CloudEventResponse = ce
+	return ctx
+}
+
 func (ctx *FunctionContext) GetOut() Out {
 	return ctx.Out
 }
@@ -624,6 +1465,35 @@ func (o *FunctionOut) WithData(data []byte) *FunctionOut {
 	return o
 }
 
+func (o *FunctionOut) GetReader() io.Reader {
+	return o.Reader
+}
+
+func (o *FunctionOut) WithReader(r io.Reader) *FunctionOut {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Reader = r
+	return o
+}
+
+func (o *FunctionOut) WithMetadata(metadata map[string]string) *FunctionOut {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Metadata = metadata
+	return o
+}
+
+func (o *FunctionOut) GetResult() interface{} {
+	return o.Result
+}
+
+func (o *FunctionOut) WithResult(result interface{}) *FunctionOut {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Result = result
+	return o
+}
+
 func (tracing *PluginsTracing) IsEnabled() bool {
 	return tracing.Enable
 }
@@ -652,6 +1522,66 @@ func (tracing *PluginsTracing) GetBaggage() map[string]string {
 	return tracing.Baggage
 }
 
+func (tracing *PluginsTracing) GetSamplingRate() float64 {
+	if tracing.SamplingRate == nil {
+		return 1
+	}
+	return *tracing.SamplingRate
+}
+
+// validateTracing verifies the configuration of the tracing plugin if it is enabled.
+func (ctx *FunctionContext) validateTracing() error {
+	if ctx.PluginsTracing == nil || !ctx.PluginsTracing.Enable {
+		return nil
+	}
+	if ctx.PluginsTracing.Provider == nil || ctx.PluginsTracing.Provider.Name == "" {
+		return errors.New("the tracing plugin is enabled, but its configuration is incorrect")
+	}
+
+	switch ctx.PluginsTracing.Provider.Name {
+	case TracingProviderSkywalking, TracingProviderOpentelemetry:
+	default:
+		return fmt.Errorf("invalid tracing provider name: %s", ctx.PluginsTracing.Provider.Name)
+	}
+	if rate := ctx.PluginsTracing.GetSamplingRate(); rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid tracing sampling rate: %v, it must be between 0 and 1", rate)
+	}
+	return nil
+}
+
+// parseTracing validates the tracing configuration and registers the tracing plugin.
+func (ctx *FunctionContext) parseTracing() error {
+	if err := ctx.validateTracing(); err != nil {
+		return err
+	}
+	if ctx.PluginsTracing == nil || !ctx.PluginsTracing.Enable {
+		return nil
+	}
+
+	ctx.PrePlugins = registerTracingPluginIntoPrePlugins(ctx.PrePlugins, ctx.PluginsTracing.Provider.Name)
+	ctx.PostPlugins = registerTracingPluginIntoPostPlugins(ctx.PostPlugins, ctx.PluginsTracing.Provider.Name)
+
+	if ctx.PluginsTracing.Tags != nil {
+		if funcName, ok := ctx.PluginsTracing.Tags["func"]; !ok || funcName != ctx.Name {
+			ctx.PluginsTracing.Tags["func"] = ctx.Name
+		}
+		// omit the instance tags rather than tagging blanks
+		if instance := ctx.instanceName(); instance != "" {
+			ctx.PluginsTracing.Tags["instance"] = instance
+		}
+		if ctx.podNamespace != "" {
+			ctx.PluginsTracing.Tags["namespace"] = ctx.podNamespace
+		}
+	}
+	return nil
+}
+
+// tracingFailOpen detects if an incorrect tracing configuration disables the tracing rather than failing the function.
+func tracingFailOpen() bool {
+	failOpen, _ := strconv.ParseBool(os.Getenv(TracingFailOpenEnvName))
+	return failOpen
+}
+
 func registerTracingPluginIntoPrePlugins(plugins []string, target string) []string {
 	if len(plugins) == 0 {
 		plugins = append(plugins, target)
@@ -665,12 +1595,21 @@ func registerTracingPluginIntoPostPlugins(plugins []string, target string) []str
 	if len(plugins) == 0 {
 		plugins = append(plugins, target)
 	} else if exist := hasPlugin(plugins, target); !exist {
-		plugins = append(plugins[:1], plugins[:]...)
-		plugins[0] = target
+		// prepend to a new slice rather than shifting the configured plugins in place
+		plugins = append([]string{target}, plugins...)
 	}
 	return plugins
 }
 
+// reversePlugins returns the plugins in the reverse order.
+func reversePlugins(plugins []string) []string {
+	reversed := make([]string, 0, len(plugins))
+	for i := len(plugins) - 1; i >= 0; i-- {
+		reversed = append(reversed, plugins[i])
+	}
+	return reversed
+}
+
 func hasPlugin(plugins []string, target string) bool {
 	for _, plg := range plugins {
 		if plg == target {
@@ -680,55 +1619,218 @@ func hasPlugin(plugins []string, target string) bool {
 	return false
 }
 
-func GetRuntimeContext() (RuntimeContext, error) {
-	if ctx, err := parseContext(); err != nil {
+type RuntimeContextOption func(*FunctionContext)
+
+// WithBaseContext sets the root context from which the native context of every invocation is derived.
+func WithBaseContext(c context.Context) RuntimeContextOption {
+	return func(ctx *FunctionContext) {
+		ctx.baseCtx = c
+	}
+}
+
+// WithTimeout attaches a root timeout to the base context of the function.
+func WithTimeout(timeout time.Duration) RuntimeContextOption {
+	return func(ctx *FunctionContext) {
+		ctx.baseCtx, ctx.baseCancel = context.WithTimeout(ctx.GetBaseContext(), timeout)
+	}
+}
+
+// WithFunctionContext configures the function with the function context built in code, such as by a Builder,
+// in place of the env FUNC_CONTEXT.
+func WithFunctionContext(fc *FunctionContext) RuntimeContextOption {
+	return func(ctx *FunctionContext) {
+		ctx.source = fc
+	}
+}
+
+func GetRuntimeContext(opts ...RuntimeContextOption) (RuntimeContext, error) {
+	if ctx, err := parseContext(opts...); err != nil {
 		return nil, err
 	} else {
+		ctx.Ctx = ctx.GetBaseContext()
 		return ctx, nil
 	}
 }
 
-func parseContext() (*FunctionContext, error) {
+// parseContext applies the options, then parses the function context given with WithFunctionContext
+// or the env FUNC_CONTEXT.
+func parseContext(opts ...RuntimeContextOption) (*FunctionContext, error) {
 	ctx := &FunctionContext{
 		Inputs:  make(map[string]*Input),
 		Outputs: make(map[string]*Output),
 	}
+	for _, opt := range opts {
+		opt(ctx)
+	}
 
-	data := os.Getenv(FunctionContextEnvName)
-	if data == "" {
-		return nil, fmt.Errorf("env %s not found", FunctionContextEnvName)
+	if ctx.source != nil {
+		// the function context built in code is validated and prepared the same way as FUNC_CONTEXT
+		ctx.apply(ctx.source)
+		ctx.source = nil
+	} else {
+		env := os.Getenv(FunctionContextEnvName)
+		if env == "" {
+			return nil, fmt.Errorf("env %s not found", FunctionContextEnvName)
+		}
+		if err := json.Unmarshal([]byte(env), ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	err := json.Unmarshal([]byte(data), ctx)
-	if err != nil {
+	if err := ctx.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.prepare(); err != nil {
 		return nil, err
 	}
+	return ctx, nil
+}
+
+// apply sets the configuration of the function context src on the function context, the inputs, the outputs
+// and the output groups are copied so that the function contexts do not share them.
+func (ctx *FunctionContext) apply(src *FunctionContext) {
+	ctx.Name = src.Name
+	ctx.Version = src.Version
+	ctx.Runtime = src.Runtime
+	ctx.Port = src.Port
+	ctx.State = src.State
+	ctx.PrePlugins = src.PrePlugins
+	ctx.PostPlugins = src.PostPlugins
+	ctx.ReversePostPlugins = src.ReversePostPlugins
+	ctx.PluginsHookTimeout = src.PluginsHookTimeout
+	ctx.PluginHookTimeouts = src.PluginHookTimeouts
+	ctx.PluginsConfig = src.PluginsConfig
+	ctx.CircuitBreaker = src.CircuitBreaker
+	ctx.HttpPattern = src.HttpPattern
+	ctx.HttpMethods = src.HttpMethods
+	ctx.HttpSchema = src.HttpSchema
+	ctx.HttpCacheTTL = src.HttpCacheTTL
+
+	for name, input := range src.Inputs {
+		if input != nil {
+			in := *input
+			input = &in
+		}
+		ctx.Inputs[name] = input
+	}
+	for name, output := range src.Outputs {
+		if output != nil {
+			out := *output
+			output = &out
+		}
+		ctx.Outputs[name] = output
+	}
+	if src.OutputGroups != nil {
+		ctx.OutputGroups = make(map[string]*OutputGroup, len(src.OutputGroups))
+		for name, group := range src.OutputGroups {
+			if group != nil {
+				group = &OutputGroup{Outputs: group.Outputs, Strategy: group.Strategy}
+			}
+			ctx.OutputGroups[name] = group
+		}
+	}
+	if src.PluginsTracing != nil {
+		// the tags are completed by the function context
+		tracing := *src.PluginsTracing
+		if tracing.Tags != nil {
+			tracing.Tags = make(map[string]string, len(src.PluginsTracing.Tags))
+			for k, v := range src.PluginsTracing.Tags {
+				tracing.Tags[k] = v
+			}
+		}
+		ctx.PluginsTracing = &tracing
+	}
+}
 
+// Validate verifies the function context: its runtime, the component types of its inputs and outputs,
+// its output groups, its tracing configuration and its port. It does not modify the function context.
+func (ctx *FunctionContext) Validate() error {
 	switch ctx.Runtime {
 	case Async, Knative:
 		break
 	default:
-		return nil, fmt.Errorf("invalid runtime: %s", ctx.Runtime)
+		return fmt.Errorf("invalid runtime: %s", ctx.Runtime)
+	}
+
+	for name, in := range ctx.Inputs {
+		if in == nil {
+			return fmt.Errorf("input %s is nil", name)
+		}
+		if _, err := getBuildingBlockType(in.ComponentType); err != nil {
+			klog.Errorf("failed to get building block type for input %s: %v", name, err)
+			return err
+		}
+	}
+
+	for name, out := range ctx.Outputs {
+		if err := validateOutput(name, out); err != nil {
+			klog.Errorf("failed to get building block type for output %s: %v", name, err)
+			return err
+		}
+	}
+
+	for name, group := range ctx.OutputGroups {
+		if group == nil || len(group.Outputs) == 0 {
+			return fmt.Errorf("output group %s has no outputs", name)
+		}
+		switch group.Strategy {
+		case "", RoundRobinStrategy, BroadcastStrategy:
+			break
+		default:
+			return fmt.Errorf("invalid strategy for output group %s: %s", name, group.Strategy)
+		}
+		for _, out := range group.Outputs {
+			if _, ok := ctx.Outputs[out]; !ok {
+				return fmt.Errorf("output %s of output group %s not found", out, name)
+			}
+		}
+	}
+
+	// an incorrect tracing configuration disables the tracing when it fails open
+	if err := ctx.validateTracing(); err != nil && !tracingFailOpen() {
+		return err
+	}
+
+	if ctx.Port != "" {
+		if _, err := strconv.Atoi(ctx.Port); err != nil {
+			return fmt.Errorf("error parsing port: %s", err.Error())
+		}
 	}
+	return nil
+}
+
+// prepare parses the configuration of the function context once it is validated.
+func (ctx *FunctionContext) prepare() error {
+	var err error
 
 	ctx.Event = &EventRequest{}
 	ctx.SyncRequest = &SyncRequest{}
 
 	if ctx.HasInputs() {
 		for name, in := range ctx.GetInputs() {
-			if _, err := getBuildingBlockType(in.ComponentType); err != nil {
-				klog.Errorf("failed to get building block type for input %s: %v", name, err)
-				return nil, err
+			if in.Schema != "" {
+				if in.schema, err = loadSchema(in.Schema); err != nil {
+					return fmt.Errorf("failed to load schema for input %s: %v", name, err)
+				}
+			}
+			if err := in.parseCron(); err != nil {
+				return fmt.Errorf("invalid cron input %s: %v", name, err)
+			}
+			if err := in.parseIdempotency(); err != nil {
+				return fmt.Errorf("invalid idempotency for input %s: %v", name, err)
+			}
+			if err := in.parseRetry(); err != nil {
+				return fmt.Errorf("invalid retry policy for input %s: %v", name, err)
+			}
+			if err := in.parseFilter(); err != nil {
+				return fmt.Errorf("invalid filter for input %s: %v", name, err)
 			}
 		}
 	}
 
-	if ctx.HasOutputs() {
-		for name, out := range ctx.GetOutputs() {
-			if _, err := getBuildingBlockType(out.ComponentType); err != nil {
-				klog.Errorf("failed to get building block type for output %s: %v", name, err)
-				return nil, err
-			}
+	for _, group := range ctx.OutputGroups {
+		if group.Strategy == "" {
+			group.Strategy = RoundRobinStrategy
 		}
 	}
 
@@ -742,58 +1844,89 @@ func parseContext() (*FunctionContext, error) {
 	if ctx.mode == KubernetesMode {
 		podName := os.Getenv(PodNameEnvName)
 		if podName == "" {
-			return nil, errors.New("the name of the pod cannot be retrieved from the environment, " +
+			return errors.New("the name of the pod cannot be retrieved from the environment, " +
 				"you need to set the POD_NAME environment variable")
 		}
 		ctx.podName = podName
 
 		podNamespace := os.Getenv(PodNamespaceEnvName)
 		if podNamespace == "" {
-			return nil, errors.New("the namespace of the pod cannot be retrieved from the environment, " +
+			return errors.New("the namespace of the pod cannot be retrieved from the environment, " +
 				"you need to set the POD_NAMESPACE environment variable")
 		}
 		ctx.podNamespace = podNamespace
+	} else {
+		// the instance name and namespace are optional in self-host mode
+		ctx.podName = os.Getenv(InstanceNameEnvName)
+		ctx.podNamespace = os.Getenv(InstanceNamespaceEnvName)
 	}
 
-	if ctx.PluginsTracing != nil && ctx.PluginsTracing.Enable {
-		if ctx.PluginsTracing.Provider != nil && ctx.PluginsTracing.Provider.Name != "" {
-			switch ctx.PluginsTracing.Provider.Name {
-			case TracingProviderSkywalking, TracingProviderOpentelemetry:
-				ctx.PrePlugins = registerTracingPluginIntoPrePlugins(ctx.PrePlugins, ctx.PluginsTracing.Provider.Name)
-				ctx.PostPlugins = registerTracingPluginIntoPostPlugins(ctx.PostPlugins, ctx.PluginsTracing.Provider.Name)
-			default:
-				return nil, fmt.Errorf("invalid tracing provider name: %s", ctx.PluginsTracing.Provider.Name)
-			}
-			if ctx.PluginsTracing.Tags != nil {
-				if funcName, ok := ctx.PluginsTracing.Tags["func"]; !ok || funcName != ctx.Name {
-					ctx.PluginsTracing.Tags["func"] = ctx.Name
-				}
-				ctx.PluginsTracing.Tags["instance"] = ctx.podName
-				ctx.PluginsTracing.Tags["namespace"] = ctx.podNamespace
+	// the post-hooks unwind the pre-hooks like a stack unless they are configured explicitly
+	if ctx.ReversePostPlugins && ctx.PostPlugins == nil {
+		ctx.PostPlugins = reversePlugins(ctx.PrePlugins)
+	}
+
+	if err := ctx.parseTracing(); err != nil {
+		if !tracingFailOpen() {
+			return err
+		}
+		klog.Warningf("tracing is disabled due to the incorrect configuration: %v", err)
+		ctx.PluginsTracing.Enable = false
+	}
+
+	if ctx.HttpSchema != "" {
+		if ctx.httpSchema, err = loadSchema(ctx.HttpSchema); err != nil {
+			return fmt.Errorf("failed to load http schema: %v", err)
+		}
+	}
+
+	for i, method := range ctx.HttpMethods {
+		ctx.HttpMethods[i] = strings.ToUpper(method)
+	}
+
+	if ctx.HttpCacheTTL != "" {
+		if ctx.httpCacheTTL, err = time.ParseDuration(ctx.HttpCacheTTL); err != nil {
+			return fmt.Errorf("failed to parse httpCacheTTL: %v", err)
+		}
+		if ctx.httpCacheTTL <= 0 {
+			return errors.New("httpCacheTTL must be positive")
+		}
+	}
+
+	if ctx.CircuitBreaker != nil {
+		if err := ctx.CircuitBreaker.parse(); err != nil {
+			return fmt.Errorf("invalid circuit breaker: %v", err)
+		}
+	}
+
+	if ctx.PluginsHookTimeout != "" {
+		timeout, err := time.ParseDuration(ctx.PluginsHookTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid plugins hook timeout: %s", ctx.PluginsHookTimeout)
+		}
+		ctx.hookTimeout = timeout
+	}
+
+	if len(ctx.PluginHookTimeouts) > 0 {
+		ctx.hookTimeouts = make(map[string]time.Duration, len(ctx.PluginHookTimeouts))
+		for name, value := range ctx.PluginHookTimeouts {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				return fmt.Errorf("invalid hook timeout of plugin %s: %s", name, value)
 			}
-		} else {
-			return nil, errors.New("the tracing plugin is enabled, but its configuration is incorrect")
+			ctx.hookTimeouts[name] = timeout
 		}
 	}
 
 	if ctx.Port == "" {
 		ctx.Port = defaultPort
-	} else {
-		if _, err := strconv.Atoi(ctx.Port); err != nil {
-			return nil, fmt.Errorf("error parsing port: %s", err.Error())
-		}
 	}
 
 	// When using self-hosted mode, configure the client port via env,
 	// refer to https://docs.dapr.io/reference/environment/
-	port := os.Getenv("DAPR_GRPC_PORT")
-	if port == "" {
-		clientGRPCPort = daprSidecarGRPCPort
-	} else {
-		clientGRPCPort = port
-	}
+	ctx.daprGRPCPort = os.Getenv("DAPR_GRPC_PORT")
 
-	return ctx, nil
+	return nil
 }
 
 func NewFunctionOut() *FunctionOut {
@@ -818,7 +1951,7 @@ func getBuildingBlockType(componentType string) (ResourceType, error) {
 	if len(typeSplit) > 1 {
 		t := typeSplit[0]
 		switch ResourceType(t) {
-		case OpenFuncBinding, OpenFuncTopic:
+		case OpenFuncBinding, OpenFuncTopic, OpenFuncService:
 			return ResourceType(t), nil
 		default:
 			return "", fmt.Errorf("unknown component type: %s", t)
@@ -1,9 +1,19 @@
 package context
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
 )
 
 var (
@@ -100,9 +110,79 @@ var (
       "oapServer": "localhost:xxx"
     }
   }
+}`
+	funcCtxWithOutputGroups = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "outputs": {
+    "a": {
+      "uri": "a",
+      "componentName": "kafka-a",
+      "componentType": "bindings.kafka"
+    },
+    "b": {
+      "uri": "b",
+      "componentName": "kafka-b",
+      "componentType": "bindings.kafka"
+    },
+    "c": {
+      "uri": "c",
+      "componentName": "kafka-c",
+      "componentType": "bindings.kafka"
+    }
+  },
+  "outputGroups": {
+    "spread": {
+      "outputs": ["a", "b", "c"]
+    },
+    "all": {
+      "outputs": ["a", "b", "c"],
+      "strategy": "broadcast"
+    }
+  }
 }`
 )
 
+// fakeDaprClient records the requests sent through the dapr client
+type fakeDaprClient struct {
+	dapr.Client
+	mu        sync.Mutex
+	bindings  map[string]int
+	published map[string]int
+	invoked   []string
+}
+
+func newFakeDaprClient() *fakeDaprClient {
+	return &fakeDaprClient{
+		bindings:  map[string]int{},
+		published: map[string]int{},
+	}
+}
+
+func (c *fakeDaprClient) InvokeBinding(ctx context.Context, in *dapr.InvokeBindingRequest) (*dapr.BindingEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bindings[in.Name]++
+	return &dapr.BindingEvent{Data: in.Data, Metadata: map[string]string{"etag": "fake-etag"}}, nil
+}
+
+func (c *fakeDaprClient) PublishEvent(ctx context.Context, pubsubName, topicName string, data interface{}, opts ...dapr.PublishEventOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published[pubsubName+"/"+topicName]++
+	return nil
+}
+
+func (c *fakeDaprClient) InvokeMethodWithContent(ctx context.Context, appID, methodName, verb string, content *dapr.DataContent) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invoked = append(c.invoked, appID+"/"+methodName+"/"+verb+"/"+content.ContentType)
+	return content.Data, nil
+}
+
+func (c *fakeDaprClient) Close() {}
+
 // TestParseFunctionContext tests and verifies the function that parses the function FunctionContext
 func TestParseFunctionContext(t *testing.T) {
 	_, err := GetRuntimeContext()
@@ -123,6 +203,12 @@ func TestParseFunctionContext(t *testing.T) {
 					if ctx.GetPodNamespace() != "test" {
 						t.Fatal("Error parse function context: failed to parse pod namespace")
 					}
+					if ctx.GetName() != "function-test" {
+						t.Fatal("Error parse function context: failed to parse name")
+					}
+					if ctx.GetVersion() != "v1.0.0" {
+						t.Fatal("Error parse function context: failed to parse version")
+					}
 				}
 			} else {
 				t.Fatal("Error set function context env")
@@ -292,3 +378,807 @@ func TestParseFunctionContext(t *testing.T) {
 		t.Fatal("Error set function context env")
 	}
 }
+
+// TestHasInputAndOutput tests and verifies the functions that detect a specific input or output by name
+func TestHasInputAndOutput(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtx); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	ctx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if !ctx.HasInput("cron") || !ctx.HasInput("eventbus") {
+		t.Fatal("Error detect existing input")
+	}
+	if ctx.HasInput("absent") {
+		t.Fatal("Error detect absent input")
+	}
+
+	if !ctx.HasOutput("echo") || !ctx.HasOutput("target") {
+		t.Fatal("Error detect existing output")
+	}
+	if ctx.HasOutput("absent") {
+		t.Fatal("Error detect absent output")
+	}
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithAsyncRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	ctx, err = GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if ctx.HasInput("cron") || ctx.HasOutput("echo") {
+		t.Fatal("Error detect input or output in a context without any")
+	}
+}
+
+// TestSendToGroup tests and verifies the distribution of data across an output group
+func TestSendToGroup(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithOutputGroups); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+
+	if ctx.OutputGroups["spread"].Strategy != RoundRobinStrategy {
+		t.Fatal("Error parse function context: failed to set default output group strategy")
+	}
+
+	client := newFakeDaprClient()
+	ctx.daprClient = client
+
+	for i := 0; i < 6; i++ {
+		if _, err := ctx.SendToGroup("spread", []byte("hello")); err != nil {
+			t.Fatalf("Error send to output group: %v", err)
+		}
+	}
+	for _, name := range []string{"kafka-a", "kafka-b", "kafka-c"} {
+		if client.bindings[name] != 2 {
+			t.Fatalf("Error distribute data in round-robin: %s received %d messages", name, client.bindings[name])
+		}
+	}
+
+	client = newFakeDaprClient()
+	ctx.daprClient = client
+
+	if _, err := ctx.SendToGroup("all", []byte("hello")); err != nil {
+		t.Fatalf("Error send to output group: %v", err)
+	}
+	for _, name := range []string{"kafka-a", "kafka-b", "kafka-c"} {
+		if client.bindings[name] != 1 {
+			t.Fatalf("Error distribute data in broadcast: %s received %d messages", name, client.bindings[name])
+		}
+	}
+
+	if _, err := ctx.SendToGroup("absent", []byte("hello")); err == nil {
+		t.Fatal("Error send to an absent output group")
+	}
+}
+
+// TestSendToService tests and verifies the service invocation output
+func TestSendToService(t *testing.T) {
+	env := `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "outputs": {
+    "orders": {
+      "uri": "neworder",
+      "operation": "put",
+      "componentName": "order-app",
+      "componentType": "service.invocation",
+      "metadata": {
+        "Content-Type": "text/plain"
+      }
+    },
+    "default": {
+      "uri": "hello",
+      "componentName": "hello-app",
+      "componentType": "service.invocation"
+    }
+  }
+}`
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, env); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	if rtCtx.GetOutputs()["orders"].GetType() != OpenFuncService {
+		t.Fatal("Error parse function context: failed to parse service output type")
+	}
+
+	ctx := rtCtx.GetContext()
+	client := newFakeDaprClient()
+	ctx.daprClient = client
+
+	out, err := ctx.Send("orders", []byte("order-1"))
+	if err != nil {
+		t.Fatalf("Error invoke service: %v", err)
+	}
+	if string(out) != "order-1" {
+		t.Fatalf("Error invoke service: got response %s", out)
+	}
+
+	if _, err := ctx.Send("default", []byte("{}")); err != nil {
+		t.Fatalf("Error invoke service: %v", err)
+	}
+
+	if len(client.invoked) != 2 ||
+		client.invoked[0] != "order-app/neworder/put/text/plain" ||
+		client.invoked[1] != "hello-app/hello/post/application/json" {
+		t.Fatalf("Error invoke service: got invocations %v", client.invoked)
+	}
+}
+
+// TestSendWithResponse tests and verifies that the metadata of the binding response is surfaced
+func TestSendWithResponse(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithOutputGroups); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	ctx.daprClient = newFakeDaprClient()
+
+	result, err := ctx.SendWithResponse("a", []byte("hello"))
+	if err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	if result.Metadata["etag"] != "fake-etag" {
+		t.Fatalf("Error surface response metadata: got %v", result.Metadata)
+	}
+	if len(result.Data) == 0 {
+		t.Fatal("Error surface response data")
+	}
+
+	data, err := ctx.Send("a", []byte("hello"))
+	if err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	if len(data) == 0 {
+		t.Fatal("Error send data: failed to return response data")
+	}
+
+	if _, err := ctx.SendWithResponse("absent", []byte("hello")); err == nil {
+		t.Fatal("Error send data to an absent output")
+	}
+}
+
+// TestSendCancelled tests and verifies that the sends are abandoned once the invocation is cancelled
+func TestSendCancelled(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithOutputGroups); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	client := newFakeDaprClient()
+	ctx.daprClient = client
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetNativeContext(c)
+
+	if _, err := ctx.SendWithResponse("a", []byte("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error send data after cancellation: got %v", err)
+	}
+	if _, err := ctx.SendToGroup("all", []byte("hello")); err == nil {
+		t.Fatal("Error broadcast data after cancellation")
+	}
+	if len(client.bindings) != 0 {
+		t.Fatalf("Error send data after cancellation: sent %v", client.bindings)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	ctx := &FunctionContext{}
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("Error deadline without a native context deadline")
+	}
+	if remaining := ctx.RemainingTime(); remaining != 0 {
+		t.Fatalf("Error remaining time without a deadline: got %s", remaining)
+	}
+
+	deadline := time.Now().Add(time.Minute)
+	c, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ctx.SetNativeContext(c)
+
+	if got, ok := ctx.Deadline(); !ok || !got.Equal(deadline) {
+		t.Fatalf("Error deadline: got %v, %v", got, ok)
+	}
+	if remaining := ctx.RemainingTime(); remaining <= 0 || remaining > time.Minute {
+		t.Fatalf("Error remaining time: got %s", remaining)
+	}
+
+	c, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	ctx.SetNativeContext(c)
+
+	if remaining := ctx.RemainingTime(); remaining != 0 {
+		t.Fatalf("Error remaining time after the deadline: got %s", remaining)
+	}
+}
+
+func TestSendMatching(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithOutputGroups); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	client := newFakeDaprClient()
+	ctx.daprClient = client
+
+	results, err := ctx.SendMatching([]byte("hello"), func(name string, o *Output) bool {
+		return o.ComponentName != "kafka-b"
+	})
+	if err != nil {
+		t.Fatalf("Error send to matching outputs: %v", err)
+	}
+	if !reflect.DeepEqual(results, map[string]error{"a": nil, "c": nil}) {
+		t.Fatalf("Error send to matching outputs: got results %v", results)
+	}
+	if !reflect.DeepEqual(client.bindings, map[string]int{"kafka-a": 1, "kafka-c": 1}) {
+		t.Fatalf("Error send to matching outputs: sent %v", client.bindings)
+	}
+
+	if _, err := ctx.SendMatching([]byte("hello"), func(name string, o *Output) bool {
+		return false
+	}); err == nil {
+		t.Fatal("Error send without matching outputs")
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetNativeContext(c)
+	results, err = ctx.SendMatching([]byte("hello"), func(name string, o *Output) bool {
+		return name == "b"
+	})
+	if err == nil || !errors.Is(results["b"], context.Canceled) {
+		t.Fatalf("Error send to matching outputs after cancellation: got %v, %v", results, err)
+	}
+}
+
+// TestInitDaprClientIfNil tests and verifies that failures to create the dapr client are returned to the caller
+func TestInitDaprClientIfNil(t *testing.T) {
+	defer func(fn func(string) (dapr.Client, error)) {
+		newDaprClient = fn
+	}(newDaprClient)
+
+	ctx := &FunctionContext{}
+
+	newDaprClient = func(port string) (dapr.Client, error) {
+		return nil, errors.New("sidecar unavailable")
+	}
+	if err := ctx.InitDaprClientIfNil(); err == nil || !strings.Contains(err.Error(), "sidecar unavailable") {
+		t.Fatal("Error init dapr client: failed to return the construction error")
+	}
+	if ctx.daprClient != nil {
+		t.Fatal("Error init dapr client: client set after a failure")
+	}
+
+	client := newFakeDaprClient()
+	newDaprClient = func(port string) (dapr.Client, error) {
+		return client, nil
+	}
+	if err := ctx.InitDaprClientIfNil(); err != nil {
+		t.Fatalf("Error init dapr client: %v", err)
+	}
+	if ctx.daprClient != client {
+		t.Fatal("Error init dapr client: client not set")
+	}
+
+	// The test mode skips the initialization of the dapr client
+	if err := os.Setenv(TestModeEnvName, TestModeOn); err != nil {
+		t.Fatal("Error set test mode env")
+	}
+	defer os.Unsetenv(TestModeEnvName)
+
+	newDaprClient = func(port string) (dapr.Client, error) {
+		return nil, errors.New("sidecar unavailable")
+	}
+	if err := (&FunctionContext{}).InitDaprClientIfNil(); err != nil {
+		t.Fatalf("Error init dapr client in test mode: %v", err)
+	}
+}
+
+// TestDaprGRPCPortIsolation tests and verifies that each context keeps its own dapr gRPC port
+func TestDaprGRPCPortIsolation(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv("DAPR_GRPC_PORT")
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithAsyncRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	if err := os.Setenv("DAPR_GRPC_PORT", "50011"); err != nil {
+		t.Fatal("Error set dapr grpc port env")
+	}
+	ctxA, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if err := os.Setenv("DAPR_GRPC_PORT", "50012"); err != nil {
+		t.Fatal("Error set dapr grpc port env")
+	}
+	ctxB, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if err := os.Unsetenv("DAPR_GRPC_PORT"); err != nil {
+		t.Fatal("Error unset dapr grpc port env")
+	}
+	ctxC, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if ctxA.GetContext().getDaprGRPCPort() != "50011" ||
+		ctxB.GetContext().getDaprGRPCPort() != "50012" ||
+		ctxC.GetContext().getDaprGRPCPort() != daprSidecarGRPCPort {
+		t.Fatal("Error parse function context: dapr grpc port is shared between contexts")
+	}
+
+	defer func(fn func(string) (dapr.Client, error)) {
+		newDaprClient = fn
+	}(newDaprClient)
+
+	var ports []string
+	newDaprClient = func(port string) (dapr.Client, error) {
+		ports = append(ports, port)
+		return newFakeDaprClient(), nil
+	}
+	for _, ctx := range []RuntimeContext{ctxA, ctxB} {
+		if err := ctx.InitDaprClientIfNil(); err != nil {
+			t.Fatalf("Error init dapr client: %v", err)
+		}
+		ctx.GetContext().stopDaprHealthCheck()
+	}
+	if len(ports) != 2 || ports[0] != "50011" || ports[1] != "50012" {
+		t.Fatalf("Error init dapr client: got ports %v", ports)
+	}
+}
+
+// TestNativeContext tests and verifies that the native context is never nil and honors the root timeout
+// TestGetMode tests and verifies the operating environment mode exposed through the RuntimeContext
+func TestGetMode(t *testing.T) {
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithKnativeRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	var rtCtx RuntimeContext
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	if rtCtx.GetMode() != SelfHostMode {
+		t.Fatalf("Error get mode: got %s", rtCtx.GetMode())
+	}
+
+	os.Unsetenv(ModeEnvName)
+	os.Setenv(PodNameEnvName, "test-pod")
+	defer os.Unsetenv(PodNameEnvName)
+	os.Setenv(PodNamespaceEnvName, "test")
+	defer os.Unsetenv(PodNamespaceEnvName)
+
+	rtCtx, err = GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	if rtCtx.GetMode() != KubernetesMode {
+		t.Fatalf("Error get mode: got %s", rtCtx.GetMode())
+	}
+}
+
+// TestTracingSamplingRate tests and verifies the parsing of the sampling rate of the tracing config
+func TestTracingSamplingRate(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	for rate, want := range map[string]float64{"": 1, `"samplingRate": 0,`: 0, `"samplingRate": 0.25,`: 0.25, `"samplingRate": 1.5,`: -1} {
+		os.Setenv(FunctionContextEnvName, fmt.Sprintf(`{
+  "name": "function-test",
+  "runtime": "Knative",
+  "pluginsTracing": {
+    "enable": true,
+    %s
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost:11800"
+    }
+  }
+}`, rate))
+		ctx, err := GetRuntimeContext()
+		if want < 0 {
+			if err == nil {
+				t.Fatalf("Error parse function context: accepted the sampling rate %s", rate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error parse function context: %s", err.Error())
+		}
+		if got := ctx.GetPluginsTracingCfg().GetSamplingRate(); got != want {
+			t.Fatalf("Error parse sampling rate %q: got %v, want %v", rate, got, want)
+		}
+	}
+}
+
+// TestReversePostPlugins tests and verifies the post plugins derived as the reverse of the pre plugins
+func TestReversePostPlugins(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	parse := func(plugins string) RuntimeContext {
+		os.Setenv(FunctionContextEnvName, fmt.Sprintf(`{
+  "name": "function-test",
+  "runtime": "Knative",
+  %s,
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost:11800"
+    }
+  }
+}`, plugins))
+		ctx, err := GetRuntimeContext()
+		if err != nil {
+			t.Fatalf("Error parse function context: %s", err.Error())
+		}
+		return ctx
+	}
+
+	derived := parse(`"prePlugins": ["plugin-a", "plugin-b"], "reversePostPlugins": true`)
+	explicit := parse(`"prePlugins": ["plugin-a", "plugin-b"], "postPlugins": ["plugin-b", "plugin-a"]`)
+	if !reflect.DeepEqual(derived.GetPrePlugins(), explicit.GetPrePlugins()) {
+		t.Fatalf("Error derive pre plugins: got %v, want %v", derived.GetPrePlugins(), explicit.GetPrePlugins())
+	}
+	if !reflect.DeepEqual(derived.GetPostPlugins(), explicit.GetPostPlugins()) {
+		t.Fatalf("Error derive post plugins: got %v, want %v", derived.GetPostPlugins(), explicit.GetPostPlugins())
+	}
+	if want := []string{"skywalking", "plugin-b", "plugin-a"}; !reflect.DeepEqual(derived.GetPostPlugins(), want) {
+		t.Fatalf("Error derive post plugins: got %v, want %v", derived.GetPostPlugins(), want)
+	}
+
+	// the explicit post plugins take precedence
+	ctx := parse(`"prePlugins": ["plugin-a", "plugin-b"], "postPlugins": ["plugin-a"], "reversePostPlugins": true`)
+	if want := []string{"skywalking", "plugin-a"}; !reflect.DeepEqual(ctx.GetPostPlugins(), want) {
+		t.Fatalf("Error derive post plugins: got %v, want %v", ctx.GetPostPlugins(), want)
+	}
+}
+
+// TestTracingFailOpen tests and verifies the policies applied to an incorrect tracing configuration
+func TestTracingFailOpen(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	for _, env := range []string{funcCtxWithWrongTracingCfgProvider, funcCtxWithWrongTracingCfg} {
+		if err := os.Setenv(FunctionContextEnvName, env); err != nil {
+			t.Fatal("Error set function context env")
+		}
+
+		// fail-closed by default
+		if _, err := GetRuntimeContext(); err == nil {
+			t.Fatal("Error parse function context: accepted the incorrect tracing config")
+		}
+
+		os.Setenv(TracingFailOpenEnvName, "true")
+		ctx, err := GetRuntimeContext()
+		os.Unsetenv(TracingFailOpenEnvName)
+		if err != nil {
+			t.Fatalf("Error parse function context with tracing fail-open: %s", err.Error())
+		}
+		if ctx.GetPluginsTracingCfg().IsEnabled() {
+			t.Fatal("Error parse function context: tracing not disabled")
+		}
+		if len(ctx.GetPrePlugins()) != 3 || len(ctx.GetPostPlugins()) != 3 {
+			t.Fatalf("Error parse function context: tracing plugin registered: %v, %v", ctx.GetPrePlugins(), ctx.GetPostPlugins())
+		}
+	}
+	os.Unsetenv(FunctionContextEnvName)
+}
+
+// TestSelfHostTracingTags tests and verifies the instance tags of the tracing config in self-host mode
+func TestSelfHostTracingTags(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithTracingCfg); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	ctx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("Error get hostname: %v", err)
+	}
+	tags := ctx.GetPluginsTracingCfg().GetTags()
+	if tags["instance"] != hostname {
+		t.Fatalf("Error populate tags: got instance tag %q, want the hostname %q", tags["instance"], hostname)
+	}
+	if _, ok := tags["namespace"]; ok {
+		t.Fatalf("Error populate tags: unexpected namespace tag %q", tags["namespace"])
+	}
+
+	os.Setenv(InstanceNameEnvName, "local-1")
+	defer os.Unsetenv(InstanceNameEnvName)
+	os.Setenv(InstanceNamespaceEnvName, "dev")
+	defer os.Unsetenv(InstanceNamespaceEnvName)
+
+	ctx, err = GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	tags = ctx.GetPluginsTracingCfg().GetTags()
+	if tags["instance"] != "local-1" || tags["namespace"] != "dev" {
+		t.Fatalf("Error populate tags: got %v", tags)
+	}
+	if ctx.GetPodName() != "local-1" || ctx.GetPodNamespace() != "dev" {
+		t.Fatal("Error parse function context: failed to parse instance name and namespace")
+	}
+}
+
+// TestRequestHeaders tests and verifies the access to the headers of the http request
+func TestRequestHeaders(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithAsyncRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	if v := rtCtx.GetRequestHeader("Authorization"); v != "" {
+		t.Fatalf("Error get header outside an http invocation: %s", v)
+	}
+	if len(rtCtx.GetRequestHeaders()) != 0 {
+		t.Fatal("Error get headers outside an http invocation")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Add("Accept", "text/plain")
+	rtCtx.SetSyncRequest(httptest.NewRecorder(), r)
+
+	if v := rtCtx.GetRequestHeader("authorization"); v != "Bearer token" {
+		t.Fatalf("Error get header: %s", v)
+	}
+	if v := rtCtx.GetRequestHeaders().Values("Accept"); len(v) != 2 {
+		t.Fatalf("Error get headers: %v", v)
+	}
+}
+
+func TestNativeContext(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithAsyncRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	ctx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	if ctx.GetNativeContext() == nil {
+		t.Fatal("Error parse function context: native context is nil")
+	}
+	if _, ok := ctx.GetNativeContext().Deadline(); ok {
+		t.Fatal("Error parse function context: native context has an unexpected deadline")
+	}
+
+	ctx.SetNativeContext(nil)
+	if ctx.GetNativeContext() == nil {
+		t.Fatal("Error get native context: native context is nil")
+	}
+
+	ctx, err = GetRuntimeContext(WithTimeout(50 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	if _, ok := ctx.GetNativeContext().Deadline(); !ok {
+		t.Fatal("Error parse function context: failed to attach the root timeout")
+	}
+	select {
+	case <-ctx.GetNativeContext().Done():
+	case <-time.After(time.Second):
+		t.Fatal("Error parse function context: root timeout not honored")
+	}
+
+	base, cancel := context.WithCancel(context.Background())
+	ctx, err = GetRuntimeContext(WithBaseContext(base))
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	cancel()
+	if ctx.GetNativeContext().Err() == nil || ctx.GetBaseContext().Err() == nil {
+		t.Fatal("Error parse function context: failed to attach the base context")
+	}
+}
+
+func TestFunctionContextValidate(t *testing.T) {
+	input := func(componentType string) map[string]*Input {
+		return map[string]*Input{"in": {ComponentName: "in", ComponentType: componentType}}
+	}
+	output := func(componentType string) map[string]*Output {
+		return map[string]*Output{"out": {ComponentName: "out", ComponentType: componentType}}
+	}
+	tracing := func(provider string, rate float64) *PluginsTracing {
+		return &PluginsTracing{Enable: true, Provider: &TracingProvider{Name: provider}, SamplingRate: &rate}
+	}
+
+	for name, tc := range map[string]struct {
+		ctx *FunctionContext
+		err string
+	}{
+		"valid": {
+			ctx: &FunctionContext{Runtime: Async, Inputs: input("bindings.kafka"), Outputs: output("pubsub.kafka")},
+		},
+		"invalid runtime": {
+			ctx: &FunctionContext{Runtime: "Lambda"},
+			err: "invalid runtime: Lambda",
+		},
+		"nil input": {
+			ctx: &FunctionContext{Runtime: Async, Inputs: map[string]*Input{"in": nil}},
+			err: "input in is nil",
+		},
+		"invalid input type": {
+			ctx: &FunctionContext{Runtime: Async, Inputs: input("kafka")},
+			err: "invalid component type",
+		},
+		"unknown input type": {
+			ctx: &FunctionContext{Runtime: Async, Inputs: input("queue.kafka")},
+			err: "unknown component type: queue",
+		},
+		"nil output": {
+			ctx: &FunctionContext{Runtime: Knative, Outputs: map[string]*Output{"out": nil}},
+			err: "output out is nil",
+		},
+		"unknown output type": {
+			ctx: &FunctionContext{Runtime: Knative, Outputs: output("state.redis")},
+			err: "unknown component type: state",
+		},
+		"empty output group": {
+			ctx: &FunctionContext{Runtime: Knative, OutputGroups: map[string]*OutputGroup{"group": {}}},
+			err: "output group group has no outputs",
+		},
+		"invalid output group strategy": {
+			ctx: &FunctionContext{Runtime: Knative, Outputs: output("pubsub.kafka"),
+				OutputGroups: map[string]*OutputGroup{"group": {Outputs: []string{"out"}, Strategy: "random"}}},
+			err: "invalid strategy for output group group: random",
+		},
+		"unknown output of output group": {
+			ctx: &FunctionContext{Runtime: Knative, OutputGroups: map[string]*OutputGroup{"group": {Outputs: []string{"out"}}}},
+			err: "output out of output group group not found",
+		},
+		"tracing without provider": {
+			ctx: &FunctionContext{Runtime: Knative, PluginsTracing: &PluginsTracing{Enable: true}},
+			err: "the tracing plugin is enabled, but its configuration is incorrect",
+		},
+		"invalid tracing provider": {
+			ctx: &FunctionContext{Runtime: Knative, PluginsTracing: tracing("zipkin", 1)},
+			err: "invalid tracing provider name: zipkin",
+		},
+		"invalid tracing sampling rate": {
+			ctx: &FunctionContext{Runtime: Knative, PluginsTracing: tracing(TracingProviderSkywalking, 2)},
+			err: "invalid tracing sampling rate: 2, it must be between 0 and 1",
+		},
+		"invalid port": {
+			ctx: &FunctionContext{Runtime: Knative, Port: "http"},
+			err: "error parsing port",
+		},
+	} {
+		err := tc.ctx.Validate()
+		if tc.err == "" {
+			if err != nil {
+				t.Fatalf("Error validate function context %s: %v", name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("Error validate function context %s: got %v, want %s", name, err, tc.err)
+		}
+	}
+
+	// the incorrect tracing configuration is valid when the tracing fails open
+	os.Setenv(TracingFailOpenEnvName, "true")
+	defer os.Unsetenv(TracingFailOpenEnvName)
+	ctx := &FunctionContext{Runtime: Knative, PluginsTracing: tracing("zipkin", 1)}
+	if err := ctx.Validate(); err != nil {
+		t.Fatalf("Error validate function context failing open: %v", err)
+	}
+	// the function context is not modified
+	if ctx.Port != "" || !ctx.PluginsTracing.Enable || ctx.PrePlugins != nil {
+		t.Fatal("Error validate function context: modified")
+	}
+}
@@ -0,0 +1,55 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	CronBindingType     = "bindings.cron"
+	ScheduleMetadataKey = "schedule"
+)
+
+// CronTrigger describes the tick of a cron binding input.
+type CronTrigger struct {
+	// Name is the name of the cron input.
+	Name string `json:"name"`
+	// Schedule is the schedule of the cron input, such as `@every 5s`.
+	Schedule string `json:"schedule"`
+	// Time is the time the tick was received.
+	Time time.Time `json:"time"`
+}
+
+func (i *Input) isCron() bool {
+	return strings.EqualFold(i.ComponentType, CronBindingType)
+}
+
+// parseCron validates that the schedule of the cron input is set.
+func (i *Input) parseCron() error {
+	if i.isCron() && i.Metadata[ScheduleMetadataKey] == "" {
+		return fmt.Errorf("metadata %s is required for %s input", ScheduleMetadataKey, CronBindingType)
+	}
+	return nil
+}
+
+// setCronTrigger records the tick if the binding event comes from a cron input.
+func (ctx *FunctionContext) setCronTrigger(inputName string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if in, ok := ctx.Inputs[inputName]; ok && in.isCron() {
+		ctx.Event.CronTrigger = &CronTrigger{
+			Name:     inputName,
+			Schedule: in.Metadata[ScheduleMetadataKey],
+			Time:     time.Now(),
+		}
+	}
+}
+
+// GetCronTrigger returns the tick of the current event, nil if the event does not come from a cron input.
+func (ctx *FunctionContext) GetCronTrigger() *CronTrigger {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.Event.CronTrigger
+}
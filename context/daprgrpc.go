@@ -0,0 +1,73 @@
+package context
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/klog/v2"
+)
+
+const (
+	daprAPITokenEnvName = "DAPR_API_TOKEN"
+	daprAPITokenKey     = "dapr-api-token"
+)
+
+// daprGRPCClient is the subset of the Dapr gRPC API used directly by the context,
+// the Dapr Go SDK client does not expose the configuration and metadata APIs yet.
+type daprGRPCClient interface {
+	GetConfigurationAlpha1(ctx context.Context, in *pb.GetConfigurationRequest, opts ...grpc.CallOption) (*pb.GetConfigurationResponse, error)
+	SubscribeConfigurationAlpha1(ctx context.Context, in *pb.SubscribeConfigurationRequest, opts ...grpc.CallOption) (pb.Dapr_SubscribeConfigurationAlpha1Client, error)
+	GetMetadata(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*pb.GetMetadataResponse, error)
+}
+
+var newDaprGRPCClient = func(port string) (daprGRPCClient, io.Closer, error) {
+	conn, err := grpc.Dial(net.JoinHostPort("127.0.0.1", port), grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewDaprClient(conn), conn, nil
+}
+
+func (ctx *FunctionContext) getDaprGRPCClient() (daprGRPCClient, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if ctx.grpcClient != nil {
+		return ctx.grpcClient, nil
+	}
+
+	client, conn, err := newDaprGRPCClient(ctx.getDaprGRPCPort())
+	if err != nil {
+		return nil, err
+	}
+	ctx.grpcClient = client
+	ctx.grpcConn = conn
+	return ctx.grpcClient, nil
+}
+
+// closeDaprGRPCClient closes the gRPC connection to dapr.
+func (ctx *FunctionContext) closeDaprGRPCClient() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if ctx.grpcConn != nil {
+		if err := ctx.grpcConn.Close(); err != nil {
+			klog.Warningf("failed to close dapr grpc connection: %v", err)
+		}
+		ctx.grpcConn = nil
+	}
+	ctx.grpcClient = nil
+}
+
+func withDaprAPIToken(ctx context.Context) context.Context {
+	if token := os.Getenv(daprAPITokenEnvName); token != "" {
+		return metadata.AppendToOutgoingContext(ctx, daprAPITokenKey, token)
+	}
+	return ctx
+}
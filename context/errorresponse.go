@@ -0,0 +1,46 @@
+package context
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ErrorResponseFormatter renders the body of the error responses generated by the runtime,
+// such as the requests rejected before reaching the function.
+type ErrorResponseFormatter func(code int, message, requestID string) (contentType string, body []byte)
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// JSONErrorResponse renders the error as `{"error": ..., "requestId": ...}`, it is the default formatter.
+func JSONErrorResponse(code int, message, requestID string) (string, []byte) {
+	body, _ := json.Marshal(&errorResponse{
+		Error:     strings.TrimSpace(message),
+		RequestID: requestID,
+	})
+	return "application/json", body
+}
+
+// PlainTextErrorResponse renders the error message as plain text.
+func PlainTextErrorResponse(code int, message, requestID string) (string, []byte) {
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	return "text/plain; charset=utf-8", []byte(message)
+}
+
+// WithErrorResponseFormatter sets the formatter of the error responses generated by the runtime.
+func WithErrorResponseFormatter(f ErrorResponseFormatter) RuntimeContextOption {
+	return func(ctx *FunctionContext) {
+		ctx.errorFormatter = f
+	}
+}
+
+func (ctx *FunctionContext) GetErrorResponseFormatter() ErrorResponseFormatter {
+	if ctx.errorFormatter == nil {
+		return JSONErrorResponse
+	}
+	return ctx.errorFormatter
+}
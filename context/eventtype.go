@@ -0,0 +1,80 @@
+package context
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// EventType tells which source the current event comes from.
+type EventType string
+
+const (
+	EventTypeNone       EventType = "none"
+	EventTypeBinding    EventType = "binding"
+	EventTypeTopic      EventType = "topic"
+	EventTypeInvocation EventType = "invocation"
+	EventTypeCloudEvent EventType = "cloudevent"
+	EventTypeHTTP       EventType = "http"
+)
+
+// GetEventType returns the source of the current event, based on which event is set.
+func (ctx *FunctionContext) GetEventType() EventType {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	switch {
+	case ctx.Event != nil && ctx.Event.BindingEvent != nil:
+		return EventTypeBinding
+	case ctx.Event != nil && ctx.Event.TopicEvent != nil:
+		return EventTypeTopic
+	case ctx.Event != nil && ctx.Event.InvocationEvent != nil:
+		return EventTypeInvocation
+	case ctx.Event != nil && ctx.Event.CloudEvent != nil:
+		return EventTypeCloudEvent
+	case ctx.SyncRequest.request() != nil:
+		return EventTypeHTTP
+	default:
+		return EventTypeNone
+	}
+}
+
+// GetRawData returns the payload of the current event as received, whatever its source.
+// The body of the http request is restored once read so that the function is still able to read it.
+func (ctx *FunctionContext) GetRawData() []byte {
+	eventType := ctx.GetEventType()
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	switch eventType {
+	case EventTypeBinding:
+		return ctx.Event.BindingEvent.Data
+	case EventTypeTopic:
+		if te := ctx.Event.TopicEvent; te.RawData != nil {
+			return te.RawData
+		}
+		return ConvertUserDataToBytes(ctx.Event.TopicEvent.Data)
+	case EventTypeInvocation:
+		return ctx.Event.InvocationEvent.Data
+	case EventTypeCloudEvent:
+		return ctx.Event.CloudEvent.Data()
+	case EventTypeHTTP:
+		return readBody(ctx.SyncRequest.Request)
+	default:
+		return nil
+	}
+}
+
+// readBody reads the body of the http request and restores it.
+func readBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return data
+}
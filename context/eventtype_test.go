@@ -0,0 +1,90 @@
+package context
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/dapr/go-sdk/service/common"
+)
+
+func TestGetEventType(t *testing.T) {
+	ce := cloudevents.NewEvent()
+	ce.SetID("1")
+	ce.SetSource("test")
+	ce.SetType("test")
+	if err := ce.SetData("text/plain", []byte("cloudevent")); err != nil {
+		t.Fatalf("Error set cloudevent data: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		event     interface{}
+		eventType EventType
+		data      string
+	}{
+		{
+			name:      "binding",
+			event:     &common.BindingEvent{Data: []byte("binding")},
+			eventType: EventTypeBinding,
+			data:      "binding",
+		},
+		{
+			name:      "topic",
+			event:     &common.TopicEvent{Data: "decoded", RawData: []byte("topic")},
+			eventType: EventTypeTopic,
+			data:      "topic",
+		},
+		{
+			name:      "topic without raw data",
+			event:     &common.TopicEvent{Data: map[string]string{"a": "b"}},
+			eventType: EventTypeTopic,
+			data:      `{"a":"b"}`,
+		},
+		{
+			name:      "invocation",
+			event:     &common.InvocationEvent{Data: []byte("invocation")},
+			eventType: EventTypeInvocation,
+			data:      "invocation",
+		},
+		{
+			name:      "cloudevent",
+			event:     &ce,
+			eventType: EventTypeCloudEvent,
+			data:      "cloudevent",
+		},
+	}
+	for _, tt := range tests {
+		ctx := &FunctionContext{Event: &EventRequest{}, SyncRequest: &SyncRequest{}}
+		ctx.SetEvent("input", tt.event)
+		if got := ctx.GetEventType(); got != tt.eventType {
+			t.Fatalf("Error get event type of %s: got %s", tt.name, got)
+		}
+		if got := string(ctx.GetRawData()); got != tt.data {
+			t.Fatalf("Error get raw data of %s: got %s", tt.name, got)
+		}
+	}
+
+	ctx := &FunctionContext{Event: &EventRequest{}, SyncRequest: &SyncRequest{}}
+	if got := ctx.GetEventType(); got != EventTypeNone {
+		t.Fatalf("Error get event type without event: got %s", got)
+	}
+	if data := ctx.GetRawData(); data != nil {
+		t.Fatalf("Error get raw data without event: got %s", data)
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("http"))
+	ctx.SetSyncRequest(httptest.NewRecorder(), r)
+	if got := ctx.GetEventType(); got != EventTypeHTTP {
+		t.Fatalf("Error get event type of http: got %s", got)
+	}
+	if got := string(ctx.GetRawData()); got != "http" {
+		t.Fatalf("Error get raw data of http: got %s", got)
+	}
+	// the body is still readable by the function
+	if body, _ := ioutil.ReadAll(r.Body); string(body) != "http" {
+		t.Fatalf("Error read body after get raw data: got %s", body)
+	}
+}
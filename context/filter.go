@@ -0,0 +1,93 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterMetadataKey sets the filter of the events of a binding or a topic input, which is a comma-separated list of
+// `key=value` or `key!=value` conditions on the metadata of the event. The events not matching all the conditions
+// are acknowledged and dropped without running the function.
+const FilterMetadataKey = "filter"
+
+type filterCondition struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// parseFilter validates the filter of the input.
+func (i *Input) parseFilter() error {
+	filter, ok := i.Metadata[FilterMetadataKey]
+	if !ok {
+		return nil
+	}
+	if t := i.GetType(); t != OpenFuncBinding && t != OpenFuncTopic {
+		return fmt.Errorf("%s is only supported by binding and topic inputs", FilterMetadataKey)
+	}
+
+	var conditions []filterCondition
+	for _, expr := range strings.Split(filter, ",") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		c := filterCondition{}
+		sep := "="
+		if strings.Contains(expr, "!=") {
+			sep = "!="
+			c.negate = true
+		}
+		parts := strings.SplitN(expr, sep, 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid %s condition: %s", FilterMetadataKey, expr)
+		}
+		c.key, c.value = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		conditions = append(conditions, c)
+	}
+	if len(conditions) == 0 {
+		return fmt.Errorf("%s requires at least one condition", FilterMetadataKey)
+	}
+	i.filter = conditions
+	return nil
+}
+
+// MatchEvent detects if the current event of the context matches the filter of the input,
+// any event matches if the input has no filter.
+func (i *Input) MatchEvent(ctx RuntimeContext) bool {
+	if len(i.filter) == 0 {
+		return true
+	}
+
+	metadata := eventMetadata(ctx)
+	for _, c := range i.filter {
+		if (metadata[c.key] == c.value) == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// eventMetadata returns the metadata of the inner event, and the metadata of the binding event
+// or the attributes of the topic event.
+func eventMetadata(ctx RuntimeContext) map[string]string {
+	metadata := map[string]string{}
+	if ie := ctx.GetInnerEvent(); ie != nil {
+		for k, v := range ie.GetMetadata() {
+			metadata[k] = v
+		}
+	}
+	if be := ctx.GetBindingEvent(); be != nil {
+		for k, v := range be.Metadata {
+			metadata[k] = v
+		}
+	}
+	if te := ctx.GetTopicEvent(); te != nil {
+		metadata["id"] = te.ID
+		metadata["source"] = te.Source
+		metadata["type"] = te.Type
+		metadata["subject"] = te.Subject
+		metadata["topic"] = te.Topic
+		metadata["pubsubname"] = te.PubsubName
+	}
+	return metadata
+}
@@ -0,0 +1,49 @@
+package context
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// ErrNotHTTPInvocation is returned when the http request is accessed outside an http invocation.
+var ErrNotHTTPInvocation = errors.New("not in an http invocation")
+
+func (ctx *FunctionContext) ParseForm() (url.Values, error) {
+	return ctx.SyncRequest.parseForm()
+}
+
+func (ctx *FunctionContext) ParseMultipart(maxMemory int64) (*multipart.Form, error) {
+	return ctx.SyncRequest.parseMultipart(maxMemory)
+}
+
+func (sr *SyncRequest) httpRequest() (*http.Request, error) {
+	r := sr.request()
+	if r == nil {
+		return nil, ErrNotHTTPInvocation
+	}
+	return r, nil
+}
+
+func (sr *SyncRequest) parseForm() (url.Values, error) {
+	r, err := sr.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+func (sr *SyncRequest) parseMultipart(maxMemory int64) (*multipart.Form, error) {
+	r, err := sr.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return nil, err
+	}
+	return r.MultipartForm, nil
+}
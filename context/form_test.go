@@ -0,0 +1,101 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newFormTestContext(t *testing.T) RuntimeContext {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithAsyncRuntime); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	return rtCtx
+}
+
+// TestParseForm tests and verifies the parsing of the url-encoded form of the http request
+func TestParseForm(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	rtCtx := newFormTestContext(t)
+
+	if _, err := rtCtx.ParseForm(); !errors.Is(err, ErrNotHTTPInvocation) {
+		t.Fatalf("Error parse form outside an http invocation: %v", err)
+	}
+
+	form := url.Values{"name": {"alice"}, "tag": {"a", "b"}}
+	r := httptest.NewRequest(http.MethodPost, "/?lang=en", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rtCtx.SetSyncRequest(httptest.NewRecorder(), r)
+
+	values, err := rtCtx.ParseForm()
+	if err != nil {
+		t.Fatalf("Error parse form: %v", err)
+	}
+	if values.Get("name") != "alice" || len(values["tag"]) != 2 || values.Get("lang") != "en" {
+		t.Fatalf("Error parse form: got %v", values)
+	}
+}
+
+// TestParseMultipart tests and verifies the parsing of the multipart form of the http request
+func TestParseMultipart(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	rtCtx := newFormTestContext(t)
+
+	if _, err := rtCtx.ParseMultipart(1 << 20); !errors.Is(err, ErrNotHTTPInvocation) {
+		t.Fatalf("Error parse multipart outside an http invocation: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	if err := mw.WriteField("name", "alice"); err != nil {
+		t.Fatalf("Error write field: %v", err)
+	}
+	fw, err := mw.CreateFormFile("file", "hello.txt")
+	if err != nil {
+		t.Fatalf("Error create form file: %v", err)
+	}
+	fw.Write([]byte("hello world"))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rtCtx.SetSyncRequest(httptest.NewRecorder(), r)
+
+	form, err := rtCtx.ParseMultipart(1 << 20)
+	if err != nil {
+		t.Fatalf("Error parse multipart: %v", err)
+	}
+	if v := form.Value["name"]; len(v) != 1 || v[0] != "alice" {
+		t.Fatalf("Error parse multipart value: got %v", v)
+	}
+	files := form.File["file"]
+	if len(files) != 1 || files[0].Filename != "hello.txt" {
+		t.Fatalf("Error parse multipart file: got %v", files)
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		t.Fatalf("Error open file: %v", err)
+	}
+	defer f.Close()
+	if data, _ := ioutil.ReadAll(f); string(data) != "hello world" {
+		t.Fatalf("Error read file: got %s", data)
+	}
+}
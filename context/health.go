@@ -0,0 +1,101 @@
+package context
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"k8s.io/klog/v2"
+)
+
+const (
+	daprHealthCheckInterval = 10 * time.Second
+	daprHealthCheckTimeout  = 3 * time.Second
+)
+
+// CheckDaprHealth probes the dapr sidecar through the metadata API,
+// the dapr client is recreated if the sidecar cannot be reached.
+func (ctx *FunctionContext) CheckDaprHealth() error {
+	if testMode := os.Getenv(TestModeEnvName); testMode == TestModeOn {
+		return nil
+	}
+
+	err := ctx.probeDapr()
+	if err == nil {
+		return nil
+	}
+	klog.Warningf("dapr sidecar is unhealthy, reconnecting: %v", err)
+
+	if err := ctx.reconnectDaprClient(); err != nil {
+		return err
+	}
+	return ctx.probeDapr()
+}
+
+func (ctx *FunctionContext) probeDapr() error {
+	client, err := ctx.getDaprGRPCClient()
+	if err != nil {
+		return err
+	}
+
+	c, cancel := context.WithTimeout(withDaprAPIToken(context.Background()), daprHealthCheckTimeout)
+	defer cancel()
+	_, err = client.GetMetadata(c, &empty.Empty{})
+	return err
+}
+
+// reconnectDaprClient drops the connections to dapr and swaps in a new dapr client,
+// the stale client is kept if the new one cannot be created so that the client is never missing.
+func (ctx *FunctionContext) reconnectDaprClient() error {
+	ctx.closeDaprGRPCClient()
+
+	client, err := newDaprClient(ctx.getDaprGRPCPort())
+	if err != nil {
+		klog.Errorf("failed to recreate dapr client: %v", err)
+		return err
+	}
+
+	ctx.mu.Lock()
+	stale := ctx.daprClient
+	ctx.daprClient = client
+	ctx.mu.Unlock()
+
+	if stale != nil {
+		stale.Close()
+	}
+	return nil
+}
+
+// startDaprHealthCheck periodically checks the health of the dapr sidecar until stopDaprHealthCheck is called.
+func (ctx *FunctionContext) startDaprHealthCheck() {
+	if ctx.healthStop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	ctx.healthStop = stop
+	go func() {
+		ticker := time.NewTicker(daprHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := ctx.CheckDaprHealth(); err != nil {
+					klog.Errorf("dapr health check failed: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (ctx *FunctionContext) stopDaprHealthCheck() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.healthStop != nil {
+		close(ctx.healthStop)
+		ctx.healthStop = nil
+	}
+}
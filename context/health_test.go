@@ -0,0 +1,123 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	dapr "github.com/dapr/go-sdk/client"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+)
+
+// fakeDaprSidecar answers the metadata probes until its connection is dropped
+type fakeDaprSidecar struct {
+	daprGRPCClient
+	dropped bool
+	closed  int
+}
+
+func (s *fakeDaprSidecar) GetMetadata(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*pb.GetMetadataResponse, error) {
+	if s.dropped {
+		return nil, errors.New("connection dropped")
+	}
+	return &pb.GetMetadataResponse{}, nil
+}
+
+func (s *fakeDaprSidecar) Close() error {
+	s.closed++
+	return nil
+}
+
+// TestCheckDaprHealth tests and verifies the reconnection of the dapr client after a dropped connection
+func TestCheckDaprHealth(t *testing.T) {
+	defer func(fn func(string) (dapr.Client, error)) {
+		newDaprClient = fn
+	}(newDaprClient)
+	defer func(fn func(string) (daprGRPCClient, io.Closer, error)) {
+		newDaprGRPCClient = fn
+	}(newDaprGRPCClient)
+
+	var sidecars []*fakeDaprSidecar
+	newDaprGRPCClient = func(port string) (daprGRPCClient, io.Closer, error) {
+		s := &fakeDaprSidecar{}
+		sidecars = append(sidecars, s)
+		return s, s, nil
+	}
+
+	var clients int
+	newDaprClient = func(port string) (dapr.Client, error) {
+		clients++
+		return newFakeDaprClient(), nil
+	}
+
+	ctx := &FunctionContext{}
+	if err := ctx.InitDaprClientIfNil(); err != nil {
+		t.Fatalf("Error init dapr client: %v", err)
+	}
+	defer ctx.DestroyDaprClient()
+
+	if err := ctx.CheckDaprHealth(); err != nil {
+		t.Fatalf("Error check dapr health: %v", err)
+	}
+	if clients != 1 || len(sidecars) != 1 {
+		t.Fatal("Error check dapr health: reconnected a healthy client")
+	}
+
+	// Simulate a restart of the sidecar
+	sidecars[0].dropped = true
+	stale := ctx.daprClient
+
+	if err := ctx.CheckDaprHealth(); err != nil {
+		t.Fatalf("Error check dapr health after reconnection: %v", err)
+	}
+	if clients != 2 || len(sidecars) != 2 || sidecars[0].closed != 1 {
+		t.Fatal("Error check dapr health: failed to reconnect the dropped connection")
+	}
+	if ctx.daprClient == nil || ctx.daprClient == stale {
+		t.Fatal("Error check dapr health: failed to recreate the dapr client")
+	}
+}
+
+// TestReconnectDaprClientWhileSending tests and verifies that the dapr client is never missing while it is reconnected
+func TestReconnectDaprClientWhileSending(t *testing.T) {
+	defer func(fn func(string) (dapr.Client, error)) {
+		newDaprClient = fn
+	}(newDaprClient)
+	newDaprClient = func(port string) (dapr.Client, error) {
+		return newFakeDaprClient(), nil
+	}
+
+	ctx := &FunctionContext{daprClient: newFakeDaprClient()}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := ctx.Publish("pubsub", "topic", []byte("hello"), nil); err != nil {
+					t.Errorf("Error publish while reconnecting: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := ctx.reconnectDaprClient(); err != nil {
+			t.Fatalf("Error reconnect dapr client: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
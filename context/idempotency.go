@@ -0,0 +1,131 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+const (
+	// IdempotencyKeyMetadataKey names the source of the idempotency key of the input events:
+	// a metadata field of the binding events, or `id` for the id of the topic events.
+	IdempotencyKeyMetadataKey = "idempotencyKey"
+	// IdempotencyStoreMetadataKey names the state store recording the processed keys.
+	IdempotencyStoreMetadataKey = "idempotencyStore"
+	// IdempotencyTTLMetadataKey sets how long the processed keys are recorded, 24h by default.
+	IdempotencyTTLMetadataKey = "idempotencyTTL"
+
+	topicEventIDKey       = "id"
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// parseIdempotency validates the idempotency configuration of the input.
+func (i *Input) parseIdempotency() error {
+	if i.Metadata[IdempotencyKeyMetadataKey] == "" {
+		return nil
+	}
+	if i.Metadata[IdempotencyStoreMetadataKey] == "" {
+		return fmt.Errorf("%s is required when %s is set", IdempotencyStoreMetadataKey, IdempotencyKeyMetadataKey)
+	}
+
+	i.idempotencyTTL = defaultIdempotencyTTL
+	if ttl, ok := i.Metadata[IdempotencyTTLMetadataKey]; ok {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", IdempotencyTTLMetadataKey, err)
+		}
+		if d < time.Second {
+			return fmt.Errorf("%s must be at least 1s", IdempotencyTTLMetadataKey)
+		}
+		i.idempotencyTTL = d
+	}
+	return nil
+}
+
+// GetIdempotencyKey returns the idempotency key of the current event,
+// empty if the idempotency is not enabled for its input or the event carries no key.
+func (ctx *FunctionContext) GetIdempotencyKey() string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	input, ok := ctx.Inputs[ctx.Event.InputName]
+	if !ok {
+		return ""
+	}
+	source := input.Metadata[IdempotencyKeyMetadataKey]
+	if source == "" {
+		return ""
+	}
+
+	switch {
+	case ctx.Event.BindingEvent != nil:
+		return ctx.Event.BindingEvent.Metadata[source]
+	case ctx.Event.TopicEvent != nil && source == topicEventIDKey:
+		return ctx.Event.TopicEvent.ID
+	default:
+		return ""
+	}
+}
+
+// IsProcessed detects if the event with the idempotency key has already been processed by the current input.
+func (ctx *FunctionContext) IsProcessed(key string) (bool, error) {
+	return ctx.isProcessed(ctx.GetNativeContext(), key)
+}
+
+// isProcessed looks the idempotency key up within the native context c.
+func (ctx *FunctionContext) isProcessed(c context.Context, key string) (bool, error) {
+	client, store, stateKey, err := ctx.idempotencyState(key)
+	if err != nil {
+		return false, err
+	}
+
+	item, err := client.GetState(c, store, stateKey)
+	if err != nil {
+		return false, err
+	}
+	return item != nil && len(item.Value) > 0, nil
+}
+
+// MarkProcessed records the idempotency key of the processed event of the current input until its TTL expires.
+func (ctx *FunctionContext) MarkProcessed(key string) error {
+	return ctx.markProcessed(ctx.GetNativeContext(), key)
+}
+
+// markProcessed records the idempotency key within the native context c.
+func (ctx *FunctionContext) markProcessed(c context.Context, key string) error {
+	client, store, stateKey, err := ctx.idempotencyState(key)
+	if err != nil {
+		return err
+	}
+
+	ctx.mu.Lock()
+	ttl := ctx.Inputs[ctx.Event.InputName].idempotencyTTL
+	ctx.mu.Unlock()
+
+	return client.SaveBulkState(c, store, &dapr.SetStateItem{
+		Key:      stateKey,
+		Value:    []byte(time.Now().UTC().Format(time.RFC3339)),
+		Metadata: map[string]string{"ttlInSeconds": strconv.Itoa(int(ttl.Seconds()))},
+	})
+}
+
+// idempotencyState returns the dapr client, the state store and the state key recording the idempotency key.
+func (ctx *FunctionContext) idempotencyState(key string) (dapr.Client, string, string, error) {
+	client := ctx.getDaprClient()
+	if client == nil {
+		return nil, "", "", errors.New("dapr client is not initialized")
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	input, ok := ctx.Inputs[ctx.Event.InputName]
+	if !ok || input.Metadata[IdempotencyStoreMetadataKey] == "" {
+		return nil, "", "", fmt.Errorf("idempotency is not enabled for input %s", ctx.Event.InputName)
+	}
+	return client, input.Metadata[IdempotencyStoreMetadataKey], fmt.Sprintf("%s||%s||%s", ctx.Name, ctx.Event.InputName, key), nil
+}
@@ -0,0 +1,138 @@
+package context
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	dapr "github.com/dapr/go-sdk/client"
+	"github.com/dapr/go-sdk/service/common"
+)
+
+var funcCtxWithIdempotency = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "orders",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "idempotencyKey": "messageId",
+        "idempotencyStore": "statestore",
+        "idempotencyTTL": "1h"
+      }
+    },
+    "events": {
+      "uri": "events",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka",
+      "metadata": {
+        "idempotencyKey": "id",
+        "idempotencyStore": "statestore"
+      }
+    }
+  }
+}`
+
+// fakeStateClient records the states saved through the dapr client
+type fakeStateClient struct {
+	*fakeDaprClient
+	states   map[string][]byte
+	metadata map[string]map[string]string
+}
+
+func (c *fakeStateClient) GetState(ctx context.Context, storeName, key string) (*dapr.StateItem, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &dapr.StateItem{Key: key, Value: c.states[storeName+"/"+key]}, nil
+}
+
+func (c *fakeStateClient) SaveBulkState(ctx context.Context, storeName string, items ...*dapr.SetStateItem) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, item := range items {
+		c.states[storeName+"/"+item.Key] = item.Value
+		c.metadata[storeName+"/"+item.Key] = item.Metadata
+	}
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithIdempotency); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	ctx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+
+	client := &fakeStateClient{
+		fakeDaprClient: newFakeDaprClient(),
+		states:         map[string][]byte{},
+		metadata:       map[string]map[string]string{},
+	}
+	ctx.GetContext().daprClient = client
+
+	for name, event := range map[string]interface{}{
+		"orders": &common.BindingEvent{Data: []byte("order"), Metadata: map[string]string{"messageId": "m1"}},
+		"events": &common.TopicEvent{ID: "e1", Data: "event"},
+	} {
+		ctx.SetEvent(name, event)
+
+		key := ctx.GetIdempotencyKey()
+		if key == "" {
+			t.Fatalf("Error get idempotency key of input %s", name)
+		}
+
+		// first delivery
+		if processed, err := ctx.IsProcessed(key); err != nil || processed {
+			t.Fatalf("Error detect first delivery of input %s: %v", name, err)
+		}
+		if err := ctx.MarkProcessed(key); err != nil {
+			t.Fatalf("Error mark processed event of input %s: %v", name, err)
+		}
+
+		// redelivery
+		if processed, err := ctx.IsProcessed(key); err != nil || !processed {
+			t.Fatalf("Error detect redelivery of input %s: %v", name, err)
+		}
+	}
+
+	if ttl := client.metadata["statestore/function-test||orders||m1"]["ttlInSeconds"]; ttl != "3600" {
+		t.Fatalf("Error set ttl of processed key: %s", ttl)
+	}
+	if ttl := client.metadata["statestore/function-test||events||e1"]["ttlInSeconds"]; ttl != "86400" {
+		t.Fatalf("Error set default ttl of processed key: %s", ttl)
+	}
+
+	ctx.SetEvent("orders", &common.BindingEvent{Data: []byte("order")})
+	if key := ctx.GetIdempotencyKey(); key != "" {
+		t.Fatalf("Error get idempotency key of event without key: %s", key)
+	}
+
+	if err := os.Setenv(FunctionContextEnvName, `{
+  "name": "function-test",
+  "runtime": "Async",
+  "inputs": {
+    "orders": {
+      "componentName": "orders",
+      "componentType": "bindings.kafka",
+      "metadata": {"idempotencyKey": "messageId"}
+    }
+  }
+}`); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	if _, err := GetRuntimeContext(); err == nil {
+		t.Fatal("Error detect missing idempotency store")
+	}
+}
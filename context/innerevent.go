@@ -208,3 +208,11 @@ func convertEvent(ctx RuntimeContext, inputName string, data interface{}) InnerE
 	inner.SetSubject(inputName)
 	return inner
 }
+
+// convertRawEvent wraps the data into an inner event as is.
+func convertRawEvent(ctx RuntimeContext, inputName string, data []byte) InnerEvent {
+	inner := NewInnerEvent(ctx)
+	inner.SetSubject(inputName)
+	inner.SetUserData(data)
+	return inner
+}
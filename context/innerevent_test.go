@@ -18,7 +18,10 @@ var funcCtx = `{
     "cron": {
       "uri": "cron_input",
       "componentName": "cron_input",
-      "componentType": "bindings.cron"
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 2s"
+      }
     },
     "eventbus": {
       "uri": "default",
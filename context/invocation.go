@@ -0,0 +1,222 @@
+package context
+
+import (
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// invocationContext is the context of a single invocation, the state of the invocation, such as its native context,
+// its http request, its output, its error and its values, is kept in the invocation rather than in the function context shared by the concurrent invocations.
+type invocationContext struct {
+	*FunctionContext
+
+	stateMu sync.Mutex
+	native  context.Context
+	hook    context.Context
+	sync    *SyncRequest
+	out     Out
+	err     error
+
+	values   map[string]interface{}
+	valuesMu sync.Mutex
+}
+
+// NewInvocationContext returns the context of an invocation of the function context,
+// the native context, the http request, the output, the error and the values set in the invocation are only visible to its plugins and its function.
+func NewInvocationContext(ctx RuntimeContext) RuntimeContext {
+	return &invocationContext{FunctionContext: ctx.GetContext(), sync: &SyncRequest{}}
+}
+
+// GetNativeContext returns the native context of the invocation, the base context if none has been set.
+func (ctx *invocationContext) GetNativeContext() context.Context {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	if ctx.native == nil {
+		return ctx.GetBaseContext()
+	}
+	return ctx.native
+}
+
+// SetNativeContext sets the native context of the invocation.
+func (ctx *invocationContext) SetNativeContext(c context.Context) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	ctx.native = c
+}
+
+// SetSyncRequest sets the http request of the invocation.
+func (ctx *invocationContext) SetSyncRequest(w http.ResponseWriter, r *http.Request) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	ctx.sync.ResponseWriter = w
+	ctx.sync.Request = r
+}
+
+func (ctx *invocationContext) GetSyncRequest() *SyncRequest {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	return ctx.sync
+}
+
+func (ctx *invocationContext) GetRequestID() string {
+	// the id of an http request is carried by the request itself
+	if r := ctx.GetSyncRequest().request(); r != nil {
+		if id := RequestID(r); id != "" {
+			return id
+		}
+	}
+	return ctx.FunctionContext.GetRequestID()
+}
+
+func (ctx *invocationContext) GetPathParam(name string) string {
+	return ctx.GetSyncRequest().pathParam(name)
+}
+
+func (ctx *invocationContext) GetRequestPath() string {
+	return ctx.GetSyncRequest().path()
+}
+
+func (ctx *invocationContext) GetRequestHeader(name string) string {
+	return ctx.GetRequestHeaders().Get(name)
+}
+
+func (ctx *invocationContext) GetRequestHeaders() http.Header {
+	return ctx.GetSyncRequest().headers()
+}
+
+func (ctx *invocationContext) SetResponseHeader(name, value string) {
+	ctx.GetSyncRequest().setResponseHeader(name, value)
+}
+
+func (ctx *invocationContext) ParseForm() (url.Values, error) {
+	return ctx.GetSyncRequest().parseForm()
+}
+
+func (ctx *invocationContext) ParseMultipart(maxMemory int64) (*multipart.Form, error) {
+	return ctx.GetSyncRequest().parseMultipart(maxMemory)
+}
+
+// GetEventType returns the source of the current event, the events take precedence over the http request.
+func (ctx *invocationContext) GetEventType() EventType {
+	if t := ctx.FunctionContext.GetEventType(); t != EventTypeNone {
+		return t
+	}
+	if ctx.GetSyncRequest().request() != nil {
+		return EventTypeHTTP
+	}
+	return EventTypeNone
+}
+
+func (ctx *invocationContext) GetRawData() []byte {
+	if ctx.GetEventType() == EventTypeHTTP {
+		return readBody(ctx.GetSyncRequest().Request)
+	}
+	return ctx.FunctionContext.GetRawData()
+}
+
+// WithOut sets the output of the function in the invocation.
+func (ctx *invocationContext) WithOut(out *FunctionOut) RuntimeContext {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	ctx.out = out
+	return ctx
+}
+
+// WithError sets the error of the function, or of the hook aborting it, in the invocation.
+func (ctx *invocationContext) WithError(err error) RuntimeContext {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	ctx.err = err
+	return ctx
+}
+
+func (ctx *invocationContext) GetOut() Out {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	return ctx.out
+}
+
+func (ctx *invocationContext) GetError() error {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	return ctx.err
+}
+
+func (ctx *invocationContext) GetHookContext() context.Context {
+	ctx.stateMu.Lock()
+	c := ctx.hook
+	ctx.stateMu.Unlock()
+
+	if c == nil {
+		return ctx.GetNativeContext()
+	}
+	return c
+}
+
+// SetHookContext sets the context of the plugin hook running in the invocation.
+func (ctx *invocationContext) SetHookContext(c context.Context) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+
+	ctx.hook = c
+}
+
+func (ctx *invocationContext) Deadline() (time.Time, bool) {
+	return ctx.GetNativeContext().Deadline()
+}
+
+func (ctx *invocationContext) RemainingTime() time.Duration {
+	return remainingTime(ctx.GetNativeContext())
+}
+
+func (ctx *invocationContext) Send(outputName string, data []byte) ([]byte, error) {
+	return ctx.send(ctx.GetNativeContext(), outputName, data)
+}
+
+func (ctx *invocationContext) SendWithResponse(outputName string, data []byte) (*BindingResult, error) {
+	return ctx.sendWithContext(ctx.GetNativeContext(), outputName, data, nil)
+}
+
+func (ctx *invocationContext) SendToGroup(groupName string, data []byte) ([]byte, error) {
+	return ctx.sendToGroup(ctx.GetNativeContext(), groupName, data)
+}
+
+func (ctx *invocationContext) SendMatching(data []byte, match func(name string, o *Output) bool) (map[string]error, error) {
+	return ctx.sendMatching(ctx.GetNativeContext(), data, match)
+}
+
+func (ctx *invocationContext) SendAndWait(outputName string, data []byte, replyTopic string, timeout time.Duration) ([]byte, error) {
+	return ctx.sendAndWait(ctx.GetNativeContext(), outputName, data, replyTopic, timeout)
+}
+
+func (ctx *invocationContext) Publish(component, topic string, data []byte, metadata map[string]string) error {
+	return ctx.publish(ctx.GetNativeContext(), component, topic, data, metadata)
+}
+
+func (ctx *invocationContext) PublishToTopic(input *Input, topic string, data []byte) error {
+	return ctx.publishToTopic(ctx.GetNativeContext(), input, topic, data)
+}
+
+func (ctx *invocationContext) RequeueWithDelay(delay time.Duration) error {
+	return ctx.requeueWithDelay(ctx.GetNativeContext(), delay)
+}
+
+func (ctx *invocationContext) IsProcessed(key string) (bool, error) {
+	return ctx.isProcessed(ctx.GetNativeContext(), key)
+}
+
+func (ctx *invocationContext) MarkProcessed(key string) error {
+	return ctx.markProcessed(ctx.GetNativeContext(), key)
+}
@@ -0,0 +1,63 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestInvocationContextNativeContext(t *testing.T) {
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+	fc, client := newBufferedSenderTestContext(t)
+
+	first := NewInvocationContext(fc)
+	second := NewInvocationContext(fc)
+	if first.GetNativeContext() != fc.GetBaseContext() {
+		t.Fatal("Error get native context of an invocation before it is set")
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	first.SetNativeContext(c)
+	second.SetNativeContext(context.Background())
+	cancel()
+
+	if fc.GetNativeContext() == c {
+		t.Fatal("Error set native context of an invocation on the function context")
+	}
+	if second.GetNativeContext() != context.Background() {
+		t.Fatal("Error get native context of the invocation")
+	}
+
+	if _, err := first.(Context).Send("topic", []byte("hello")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error send in a cancelled invocation: %v", err)
+	}
+	if _, err := second.(Context).Send("topic", []byte("hello")); err != nil {
+		t.Fatalf("Error send in an invocation alongside a cancelled one: %v", err)
+	}
+	if n := client.publishedCount("kafka-server/sample"); n != 1 {
+		t.Fatalf("Error send: %d messages published, want 1", n)
+	}
+}
+
+func TestInvocationContextError(t *testing.T) {
+	fc := &FunctionContext{}
+
+	first := NewInvocationContext(fc)
+	second := NewInvocationContext(fc)
+	first.WithOut(NewFunctionOut().WithCode(InternalError)).WithError(errors.New("aborted"))
+
+	if second.GetError() != nil || second.GetOut() != nil {
+		t.Fatal("Error get error state of another invocation")
+	}
+	if fc.GetError() != nil || fc.GetOut() != nil {
+		t.Fatal("Error get error state of an invocation from the function context")
+	}
+	if err := first.GetError(); err == nil || err.Error() != "aborted" {
+		t.Fatalf("Error get error of the invocation: %v", err)
+	}
+	if code := first.GetOut().GetCode(); code != InternalError {
+		t.Fatalf("Error get output code of the invocation: %d", code)
+	}
+}
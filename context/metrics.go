@@ -0,0 +1,277 @@
+package context
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	counterMetric = "counter"
+	gaugeMetric   = "gauge"
+	summaryMetric = "summary"
+)
+
+var (
+	metricNameRegexp  = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+	// DefaultMetrics is the registry of the application metrics served on /metrics.
+	DefaultMetrics = NewMetricsRegistry()
+)
+
+// Counter is a metric that only goes up.
+type Counter interface {
+	Inc()
+	Add(v float64)
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge interface {
+	Set(v float64)
+	Add(v float64)
+}
+
+// Observer is a summary metric tracking the count and the sum of the observations.
+type Observer interface {
+	Observe(v float64)
+}
+
+// MetricsRegistry holds the application metrics and writes them in the Prometheus text format.
+type MetricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	kind   string
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	mu     sync.Mutex
+	family string
+	labels string
+	value  float64
+	count  uint64
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{families: map[string]*metricFamily{}}
+}
+
+// Counter returns the counter of the name and the tags, a counter discarding the values is returned
+// if the name or the tags are invalid.
+func (r *MetricsRegistry) Counter(name string, tags map[string]string) Counter {
+	s, err := r.series(counterMetric, name, tags)
+	if err != nil {
+		klog.Errorf("failed to get counter %s: %v", name, err)
+		return &noopMetric{}
+	}
+	return (*counter)(s)
+}
+
+// Gauge returns the gauge of the name and the tags, a gauge discarding the values is returned
+// if the name or the tags are invalid.
+func (r *MetricsRegistry) Gauge(name string, tags map[string]string) Gauge {
+	s, err := r.series(gaugeMetric, name, tags)
+	if err != nil {
+		klog.Errorf("failed to get gauge %s: %v", name, err)
+		return &noopMetric{}
+	}
+	return (*gauge)(s)
+}
+
+// Summary returns the summary of the name and the tags, a summary discarding the observations is returned
+// if the name or the tags are invalid.
+func (r *MetricsRegistry) Summary(name string, tags map[string]string) Observer {
+	s, err := r.series(summaryMetric, name, tags)
+	if err != nil {
+		klog.Errorf("failed to get summary %s: %v", name, err)
+		return &noopMetric{}
+	}
+	return (*summary)(s)
+}
+
+func (r *MetricsRegistry) series(kind, name string, tags map[string]string) (*metricSeries, error) {
+	if !metricNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid metric name %q", name)
+	}
+	labels, err := formatLabels(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &metricFamily{kind: kind, series: map[string]*metricSeries{}}
+		r.families[name] = f
+	} else if f.kind != kind {
+		return nil, fmt.Errorf("metric %s is already registered as a %s", name, f.kind)
+	}
+	s, ok := f.series[labels]
+	if !ok {
+		s = &metricSeries{family: name, labels: labels}
+		f.series[labels] = s
+	}
+	return s, nil
+}
+
+// formatLabels validates the tags and formats them as the sorted Prometheus labels.
+func formatLabels(tags map[string]string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if !labelNameRegexp.MatchString(k) || strings.HasPrefix(k, "__") {
+			return "", fmt.Errorf("invalid tag name %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labelValueEscaper.Replace(tags[k])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}", nil
+}
+
+// Write writes the metrics in the Prometheus text format, sorted by their names and labels.
+func (r *MetricsRegistry) Write(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]*metricFamily, 0, len(names))
+	series := make([][]*metricSeries, 0, len(names))
+	for _, name := range names {
+		f := r.families[name]
+		labels := make([]string, 0, len(f.series))
+		for l := range f.series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		ss := make([]*metricSeries, 0, len(labels))
+		for _, l := range labels {
+			ss = append(ss, f.series[l])
+		}
+		families = append(families, f)
+		series = append(series, ss)
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for i, name := range names {
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, families[i].kind)
+		for _, s := range series[i] {
+			s.mu.Lock()
+			value, count := s.value, s.count
+			s.mu.Unlock()
+			if families[i].kind == summaryMetric {
+				fmt.Fprintf(bw, "%s_sum%s %s\n", name, s.labels, formatFloat(value))
+				fmt.Fprintf(bw, "%s_count%s %d\n", name, s.labels, count)
+				continue
+			}
+			fmt.Fprintf(bw, "%s%s %s\n", name, s.labels, formatFloat(value))
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics on /metrics.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		klog.Errorf("failed to write metrics: %v", err)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type counter metricSeries
+
+func (c *counter) Inc() {
+	c.Add(1)
+}
+
+func (c *counter) Add(v float64) {
+	if v < 0 {
+		klog.Errorf("failed to add %v to counter %s: counters cannot decrease", v, c.family)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += v
+}
+
+type gauge metricSeries
+
+func (g *gauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = v
+}
+
+func (g *gauge) Add(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value += v
+}
+
+type summary metricSeries
+
+func (s *summary) Observe(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value += v
+	s.count++
+}
+
+type noopMetric struct{}
+
+func (*noopMetric) Inc()              {}
+func (*noopMetric) Add(v float64)     {}
+func (*noopMetric) Set(v float64)     {}
+func (*noopMetric) Observe(v float64) {}
+
+// Counter returns the application counter of the name and the tags, served on /metrics.
+func (ctx *FunctionContext) Counter(name string, tags map[string]string) Counter {
+	return DefaultMetrics.Counter(name, tags)
+}
+
+// Gauge returns the application gauge of the name and the tags, served on /metrics.
+func (ctx *FunctionContext) Gauge(name string, tags map[string]string) Gauge {
+	return DefaultMetrics.Gauge(name, tags)
+}
+
+// Summary returns the application summary of the name and the tags, served on /metrics.
+func (ctx *FunctionContext) Summary(name string, tags map[string]string) Observer {
+	return DefaultMetrics.Summary(name, tags)
+}
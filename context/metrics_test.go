@@ -0,0 +1,42 @@
+package context
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	r := NewMetricsRegistry()
+
+	r.Counter("requests_total", map[string]string{"path": "/a", "code": "200"}).Inc()
+	r.Counter("requests_total", map[string]string{"code": "200", "path": "/a"}).Add(2)
+	r.Counter("requests_total", map[string]string{"code": "500", "path": "/a"}).Inc()
+	r.Counter("requests_total", nil).Add(-1)
+	r.Gauge("queue_depth", nil).Set(5)
+	r.Gauge("queue_depth", nil).Add(-2)
+	r.Summary("latency_seconds", map[string]string{"op": `say "hi"`}).Observe(0.5)
+	r.Summary("latency_seconds", map[string]string{"op": `say "hi"`}).Observe(1)
+
+	// the invalid metrics are discarded
+	r.Counter("requests-total", nil).Inc()
+	r.Counter("invalid_tags", map[string]string{"__name": "v"}).Inc()
+	r.Gauge("requests_total", nil).Set(1)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Error write metrics: %v", err)
+	}
+	expected := `# TYPE latency_seconds summary
+latency_seconds_sum{op="say \"hi\""} 1.5
+latency_seconds_count{op="say \"hi\""} 2
+# TYPE queue_depth gauge
+queue_depth 3
+# TYPE requests_total counter
+requests_total 0
+requests_total{code="200",path="/a"} 3
+requests_total{code="500",path="/a"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("Error write metrics: got\n%s", buf.String())
+	}
+}
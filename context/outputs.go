@@ -0,0 +1,64 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+)
+
+// validateOutput checks the output the way the outputs of the function context are checked when parsed.
+func validateOutput(name string, out *Output) error {
+	if name == "" {
+		return errors.New("the name of the output is empty")
+	}
+	if out == nil {
+		return fmt.Errorf("output %s is nil", name)
+	}
+	if _, err := getBuildingBlockType(out.ComponentType); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddOutput registers the output at runtime so that data can be sent to it, it fails if the output already exists.
+// The outputs are copied on write so that the maps returned by GetOutputs are never modified.
+func (ctx *FunctionContext) AddOutput(name string, out *Output) error {
+	if err := validateOutput(name, out); err != nil {
+		return fmt.Errorf("invalid output %s: %w", name, err)
+	}
+
+	ctx.outputsMu.Lock()
+	defer ctx.outputsMu.Unlock()
+
+	if _, ok := ctx.Outputs[name]; ok {
+		return fmt.Errorf("output %s already exists", name)
+	}
+	outputs := make(map[string]*Output, len(ctx.Outputs)+1)
+	for k, v := range ctx.Outputs {
+		outputs[k] = v
+	}
+	outputs[name] = out
+	ctx.Outputs = outputs
+	return nil
+}
+
+// RemoveOutput unregisters the output, it is a no-op if the output does not exist.
+func (ctx *FunctionContext) RemoveOutput(name string) {
+	ctx.outputsMu.Lock()
+	if _, ok := ctx.Outputs[name]; !ok {
+		ctx.outputsMu.Unlock()
+		return
+	}
+	outputs := make(map[string]*Output, len(ctx.Outputs))
+	for k, v := range ctx.Outputs {
+		if k != name {
+			outputs[k] = v
+		}
+	}
+	ctx.Outputs = outputs
+	ctx.outputsMu.Unlock()
+
+	// the output added again later does not inherit the state of the circuit breaker
+	ctx.breakersMu.Lock()
+	delete(ctx.breakers, name)
+	ctx.breakersMu.Unlock()
+}
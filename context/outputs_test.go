@@ -0,0 +1,90 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDynamicOutputs(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithOutputGroups); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	client := newFakeDaprClient()
+	ctx.daprClient = client
+
+	if _, err := ctx.Send("d", []byte("hello")); err == nil {
+		t.Fatal("Error send to output not added")
+	}
+
+	out := &Output{ComponentName: "kafka-d", ComponentType: "bindings.kafka", Operation: "create"}
+	if err := ctx.AddOutput("d", out); err != nil {
+		t.Fatalf("Error add output: %v", err)
+	}
+	if !ctx.HasOutput("d") {
+		t.Fatal("Error has output added")
+	}
+	if _, err := ctx.Send("d", []byte("hello")); err != nil {
+		t.Fatalf("Error send to output added: %v", err)
+	}
+	if client.bindings["kafka-d"] != 1 {
+		t.Fatalf("Error send to output added: sent %v", client.bindings)
+	}
+
+	if err := ctx.AddOutput("d", out); err == nil {
+		t.Fatal("Error add output already existing")
+	}
+	if err := ctx.AddOutput("e", &Output{ComponentName: "e", ComponentType: "unknown"}); err == nil {
+		t.Fatal("Error add output of invalid component type")
+	}
+	if err := ctx.AddOutput("f", nil); err == nil {
+		t.Fatal("Error add nil output")
+	}
+	if err := ctx.AddOutput("", out); err == nil {
+		t.Fatal("Error add output without name")
+	}
+
+	ctx.RemoveOutput("d")
+	ctx.RemoveOutput("missing")
+	if ctx.HasOutput("d") {
+		t.Fatal("Error has output removed")
+	}
+	if _, err := ctx.Send("d", []byte("hello")); err == nil {
+		t.Fatal("Error send to output removed")
+	}
+	if _, err := ctx.Send("a", []byte("hello")); err != nil {
+		t.Fatalf("Error send to output parsed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("dynamic-%d", i)
+			if err := ctx.AddOutput(name, &Output{ComponentName: name, ComponentType: "bindings.kafka"}); err != nil {
+				t.Errorf("Error add output %s: %v", name, err)
+			}
+			for range ctx.GetOutputs() {
+			}
+			ctx.HasOutput("a")
+			ctx.RemoveOutput(name)
+		}(i)
+	}
+	wg.Wait()
+	if len(ctx.GetOutputs()) != 3 {
+		t.Fatalf("Error add and remove outputs concurrently: got %v", ctx.GetOutputs())
+	}
+}
@@ -0,0 +1,27 @@
+package context
+
+import (
+	"context"
+)
+
+// OutputSender sends the data to the outputs of a component type without dapr,
+// it is provided by the adapters of the brokers reached directly.
+type OutputSender interface {
+	// SendOutput sends the data to the output within the invocation context.
+	SendOutput(c context.Context, output *Output, data []byte) (*BindingResult, error)
+}
+
+// WithOutputSender sets the sender of the outputs of the component type, such as `pubsub.kafka`, in place of dapr.
+func WithOutputSender(componentType string, sender OutputSender) RuntimeContextOption {
+	return func(ctx *FunctionContext) {
+		if ctx.outputSenders == nil {
+			ctx.outputSenders = map[string]OutputSender{}
+		}
+		ctx.outputSenders[componentType] = sender
+	}
+}
+
+// getOutputSender returns the sender of the output, nil if the output is sent through dapr.
+func (ctx *FunctionContext) getOutputSender(output *Output) OutputSender {
+	return ctx.outputSenders[output.ComponentType]
+}
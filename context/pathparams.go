@@ -0,0 +1,30 @@
+package context
+
+import (
+	"context"
+	"net/http"
+)
+
+// CatchAllPathParam names the path parameter capturing the rest of the path matched by the catch-all segment `*`
+// ending the http pattern, such as `/proxy/*`.
+const CatchAllPathParam = "*"
+
+type pathParamsKey struct{}
+
+// WithPathParams returns a shallow copy of the request carrying the path parameters captured from the http pattern.
+func WithPathParams(r *http.Request, params map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+}
+
+// PathParams returns all the path parameters captured from the http pattern for the request.
+func PathParams(r *http.Request) map[string]string {
+	if params, ok := r.Context().Value(pathParamsKey{}).(map[string]string); ok {
+		return params
+	}
+	return nil
+}
+
+// PathParam returns the value of the path parameter with the given name for the request.
+func PathParam(r *http.Request, name string) string {
+	return PathParams(r)[name]
+}
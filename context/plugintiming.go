@@ -0,0 +1,43 @@
+package context
+
+import (
+	"time"
+)
+
+const (
+	PhasePre  = "pre"
+	PhasePost = "post"
+)
+
+// PluginTiming is the duration of the hook of a plugin in a phase of the current invocation.
+type PluginTiming struct {
+	Plugin   string
+	Phase    string
+	Duration time.Duration
+}
+
+// RecordPluginTiming records the duration of the hook of the plugin in the phase.
+func (ctx *FunctionContext) RecordPluginTiming(pluginName string, phase string, d time.Duration) {
+	ctx.timingsMu.Lock()
+	defer ctx.timingsMu.Unlock()
+
+	ctx.pluginTimings = append(ctx.pluginTimings, PluginTiming{Plugin: pluginName, Phase: phase, Duration: d})
+}
+
+// GetPluginTimings returns the durations of the plugin hooks run in the current invocation so far.
+func (ctx *FunctionContext) GetPluginTimings() []PluginTiming {
+	ctx.timingsMu.Lock()
+	defer ctx.timingsMu.Unlock()
+
+	timings := make([]PluginTiming, len(ctx.pluginTimings))
+	copy(timings, ctx.pluginTimings)
+	return timings
+}
+
+// ResetPluginTimings clears the durations of the plugin hooks at the start of an invocation.
+func (ctx *FunctionContext) ResetPluginTimings() {
+	ctx.timingsMu.Lock()
+	defer ctx.timingsMu.Unlock()
+
+	ctx.pluginTimings = nil
+}
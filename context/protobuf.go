@@ -0,0 +1,32 @@
+package context
+
+import (
+	"fmt"
+	"mime"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	ProtobufContentType  = "application/protobuf"
+	xProtobufContentType = "application/x-protobuf"
+)
+
+// IsProtobufContentType detects if the content type is protobuf, ignoring its parameters.
+func IsProtobufContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ProtobufContentType || mediaType == xProtobufContentType
+}
+
+// UnmarshalProtoData unmarshals the protobuf-encoded data of the cloudevent into the message,
+// the data is carried as is in binary content mode, or as `data_base64` in structured content mode.
+func UnmarshalProtoData(ce cloudevents.Event, m proto.Message) error {
+	if !IsProtobufContentType(ce.DataContentType()) {
+		return fmt.Errorf("the data content type of the cloudevent is %q, not %s", ce.DataContentType(), ProtobufContentType)
+	}
+	return proto.Unmarshal(ce.Data(), m)
+}
@@ -0,0 +1,40 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// Publish publishes the data to the topic of the pubsub component through dapr, without an output declared for it.
+// The metadata is passed to the pubsub component, such as `ttlInSeconds` or `rawPayload`.
+func (ctx *FunctionContext) Publish(component, topic string, data []byte, metadata map[string]string) error {
+	return ctx.publish(ctx.GetNativeContext(), component, topic, data, metadata)
+}
+
+// publish publishes the data to the topic within the native context c.
+func (ctx *FunctionContext) publish(c context.Context, component, topic string, data []byte, metadata map[string]string) error {
+	if component == "" || topic == "" {
+		return errors.New("the pubsub component and the topic are required")
+	}
+	client := ctx.getDaprClient()
+	if client == nil {
+		return errors.New("dapr client is not initialized")
+	}
+
+	// the publish is abandoned once the invocation is cancelled
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("failed to publish to topic %s of %s: %w", topic, component, err)
+	}
+
+	var opts []dapr.PublishEventOption
+	if len(metadata) > 0 {
+		opts = append(opts, dapr.PublishEventWithMetadata(metadata))
+	}
+	if err := client.PublishEvent(c, component, topic, data, opts...); err != nil {
+		return fmt.Errorf("failed to publish to topic %s of %s: %w", topic, component, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+type publishClient struct {
+	*fakeDaprClient
+	requests []*pb.PublishEventRequest
+	err      error
+}
+
+func (c *publishClient) PublishEvent(ctx context.Context, pubsubName, topicName string, data interface{}, opts ...dapr.PublishEventOption) error {
+	req := &pb.PublishEventRequest{PubsubName: pubsubName, Topic: topicName, Data: data.([]byte)}
+	for _, opt := range opts {
+		opt(req)
+	}
+	c.requests = append(c.requests, req)
+	return c.err
+}
+
+func TestPublish(t *testing.T) {
+	ctx := &FunctionContext{}
+	if err := ctx.Publish("pubsub", "orders", []byte("hello"), nil); err == nil {
+		t.Fatal("Error publish without dapr client")
+	}
+
+	client := &publishClient{fakeDaprClient: newFakeDaprClient()}
+	ctx.daprClient = client
+
+	if err := ctx.Publish("pubsub", "orders", []byte("hello"), map[string]string{"ttlInSeconds": "60"}); err != nil {
+		t.Fatalf("Error publish: %v", err)
+	}
+	if err := ctx.Publish("pubsub", "audit", []byte("world"), nil); err != nil {
+		t.Fatalf("Error publish: %v", err)
+	}
+	expected := []*pb.PublishEventRequest{
+		{PubsubName: "pubsub", Topic: "orders", Data: []byte("hello"), Metadata: map[string]string{"ttlInSeconds": "60"}},
+		{PubsubName: "pubsub", Topic: "audit", Data: []byte("world")},
+	}
+	if !reflect.DeepEqual(client.requests, expected) {
+		t.Fatalf("Error publish: got %v", client.requests)
+	}
+
+	if err := ctx.Publish("", "orders", []byte("hello"), nil); err == nil {
+		t.Fatal("Error publish without component")
+	}
+	if err := ctx.Publish("pubsub", "", []byte("hello"), nil); err == nil {
+		t.Fatal("Error publish without topic")
+	}
+
+	client.err = errors.New("unavailable")
+	if err := ctx.Publish("pubsub", "orders", []byte("hello"), nil); !errors.Is(err, client.err) {
+		t.Fatalf("Error publish failure: got %v", err)
+	}
+	client.err = nil
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetNativeContext(c)
+	if err := ctx.Publish("pubsub", "orders", []byte("hello"), nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Error publish after cancellation: got %v", err)
+	}
+	if len(client.requests) != 3 {
+		t.Fatalf("Error publish after cancellation: published %d", len(client.requests))
+	}
+}
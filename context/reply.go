@@ -0,0 +1,113 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CorrelationIDMetadataKey is the metadata key of the inner event correlating the reply with the request.
+	CorrelationIDMetadataKey = "correlationID"
+	// ReplyTopicMetadataKey is the metadata key of the inner event telling the replier the topic to reply to.
+	ReplyTopicMetadataKey = "replyTopic"
+)
+
+// pendingReply is the reply awaited by a SendAndWait call.
+type pendingReply struct {
+	topic string
+	ch    chan []byte
+}
+
+// SendAndWait publishes the data to the pubsub output and waits for the reply on the reply topic,
+// the request and the reply are correlated by the id in the metadata of their inner events, which the
+// replying function forwards as the metadata of the incoming events is merged into the sent ones.
+// It waits until the native context is done if the timeout is not positive.
+func (ctx *FunctionContext) SendAndWait(outputName string, data []byte, replyTopic string, timeout time.Duration) ([]byte, error) {
+	return ctx.sendAndWait(ctx.GetNativeContext(), outputName, data, replyTopic, timeout)
+}
+
+// sendAndWait publishes the data and waits for the reply within the native context c.
+func (ctx *FunctionContext) sendAndWait(c context.Context, outputName string, data []byte, replyTopic string, timeout time.Duration) ([]byte, error) {
+	if !ctx.HasOutput(outputName) {
+		return nil, fmt.Errorf("output %s not found", outputName)
+	}
+	if ctx.GetOutputs()[outputName].GetType() != OpenFuncTopic {
+		return nil, fmt.Errorf("output %s is not a pubsub", outputName)
+	}
+	if replyTopic == "" {
+		return nil, errors.New("reply topic required")
+	}
+
+	id := uuid.New().String()
+	reply := ctx.awaitReply(id, replyTopic)
+	defer ctx.cancelReply(id)
+
+	metadata := map[string]string{
+		CorrelationIDMetadataKey: id,
+		ReplyTopicMetadataKey:    replyTopic,
+	}
+	if _, err := ctx.sendWithContext(c, outputName, data, metadata); err != nil {
+		return nil, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case data := <-reply:
+		return data, nil
+	case <-expired:
+		return nil, fmt.Errorf("no reply on topic %s within %s", replyTopic, timeout)
+	case <-c.Done():
+		return nil, fmt.Errorf("no reply on topic %s: %w", replyTopic, c.Err())
+	}
+}
+
+// DeliverReply hands the user data of the topic event over to the SendAndWait call awaiting it,
+// it returns false if the event is not a reply so that it is processed by the function.
+func (ctx *FunctionContext) DeliverReply() bool {
+	te := ctx.GetTopicEvent()
+	ie := ctx.GetInnerEvent()
+	if te == nil || ie == nil {
+		return false
+	}
+	id := ie.GetMetadata()[CorrelationIDMetadataKey]
+	if id == "" {
+		return false
+	}
+
+	ctx.repliesMu.Lock()
+	defer ctx.repliesMu.Unlock()
+	p, ok := ctx.replies[id]
+	if !ok || p.topic != te.Topic {
+		return false
+	}
+	delete(ctx.replies, id)
+	p.ch <- ie.GetUserData()
+	return true
+}
+
+func (ctx *FunctionContext) awaitReply(id string, topic string) <-chan []byte {
+	ctx.repliesMu.Lock()
+	defer ctx.repliesMu.Unlock()
+	if ctx.replies == nil {
+		ctx.replies = map[string]*pendingReply{}
+	}
+	p := &pendingReply{topic: topic, ch: make(chan []byte, 1)}
+	ctx.replies[id] = p
+	return p.ch
+}
+
+func (ctx *FunctionContext) cancelReply(id string) {
+	ctx.repliesMu.Lock()
+	defer ctx.repliesMu.Unlock()
+	delete(ctx.replies, id)
+}
@@ -0,0 +1,101 @@
+package context
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dapr/go-sdk/service/common"
+)
+
+var funcCtxWithReplies = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "inputs": {
+    "replies": {
+      "uri": "replies",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  },
+  "outputs": {
+    "requests": {
+      "uri": "requests",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`
+
+// echoBroker replies to the published requests on their reply topic
+type echoBroker struct {
+	ctx    *FunctionContext
+	silent bool
+}
+
+func (b *echoBroker) SendOutput(c context.Context, output *Output, data []byte) (*BindingResult, error) {
+	if b.silent {
+		return nil, nil
+	}
+	request := convertEvent(b.ctx, output.Uri, data)
+	reply := NewInnerEvent(b.ctx)
+	reply.MergeMetadata(request)
+	reply.SetUserData(append([]byte("echo: "), request.GetUserData()...))
+
+	go func() {
+		b.ctx.SetEvent("replies", &common.TopicEvent{
+			Topic:      request.GetMetadata()[ReplyTopicMetadataKey],
+			PubsubName: output.ComponentName,
+			Data:       reply.GetCloudEventJSON(),
+		})
+		b.ctx.DeliverReply()
+	}()
+	return nil, nil
+}
+
+func TestSendAndWait(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithReplies); err != nil {
+		t.Fatal("Error set function context env")
+	}
+
+	broker := &echoBroker{}
+	rtCtx, err := GetRuntimeContext(WithOutputSender("pubsub.kafka", broker))
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	ctx := rtCtx.GetContext()
+	broker.ctx = ctx
+
+	data, err := ctx.SendAndWait("requests", []byte("hello"), "replies", time.Second)
+	if err != nil {
+		t.Fatalf("Error send and wait: %v", err)
+	}
+	if string(data) != "echo: hello" {
+		t.Fatalf("Error send and wait: got reply %q", data)
+	}
+	if len(ctx.replies) != 0 {
+		t.Fatalf("Error send and wait: %d pending replies left", len(ctx.replies))
+	}
+
+	// the events without a pending correlation id are processed by the function
+	ctx.SetEvent("replies", &common.TopicEvent{Topic: "replies", PubsubName: "msg", Data: []byte("hello")})
+	if ctx.DeliverReply() {
+		t.Fatal("Error deliver an event which is not a reply")
+	}
+
+	broker.silent = true
+	if _, err := ctx.SendAndWait("requests", []byte("hello"), "replies", 50*time.Millisecond); err == nil {
+		t.Fatal("Error send and wait without reply")
+	}
+
+	if _, err := ctx.SendAndWait("requests", []byte("hello"), "", time.Second); err == nil {
+		t.Fatal("Error send and wait without reply topic")
+	}
+}
@@ -0,0 +1,80 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// ScheduledDeliveryMetadataKey names the publish metadata delaying the delivery of the requeued event until
+// the time in the http time format, following the dapr convention of the Azure Service Bus component.
+const ScheduledDeliveryMetadataKey = "ScheduledEnqueueTimeUtc"
+
+// RequeueWithDelay republishes the payload of the current topic event to its topic, to be delivered after the delay,
+// and acks the original event whatever the function returns.
+func (ctx *FunctionContext) RequeueWithDelay(delay time.Duration) error {
+	return ctx.requeueWithDelay(ctx.GetNativeContext(), delay)
+}
+
+// requeueWithDelay republishes the payload of the current topic event within the native context c.
+func (ctx *FunctionContext) requeueWithDelay(c context.Context, delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("invalid delay %s", delay)
+	}
+	te := ctx.GetTopicEvent()
+	if te == nil {
+		return errors.New("only the topic events can be requeued")
+	}
+	input, ok := ctx.GetInputs()[ctx.GetInputName()]
+	if !ok {
+		return fmt.Errorf("input %s not found", ctx.GetInputName())
+	}
+
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("failed to requeue to topic %s: %w", te.Topic, err)
+	}
+
+	deliverAt := time.Now().Add(delay).UTC().Format(http.TimeFormat)
+	data := ctx.GetRawData()
+	if sender := ctx.outputSenders[input.ComponentType]; sender != nil {
+		metadata := map[string]string{}
+		for k, v := range input.Metadata {
+			metadata[k] = v
+		}
+		metadata[ScheduledDeliveryMetadataKey] = deliverAt
+		output := &Output{
+			Uri:           te.Topic,
+			ComponentName: input.ComponentName,
+			ComponentType: input.ComponentType,
+			Metadata:      metadata,
+		}
+		if _, err := sender.SendOutput(c, output, data); err != nil {
+			return fmt.Errorf("failed to requeue to topic %s: %w", te.Topic, err)
+		}
+	} else {
+		client := ctx.getDaprClient()
+		if client == nil {
+			return errors.New("dapr client is not initialized")
+		}
+		opt := dapr.PublishEventWithMetadata(map[string]string{ScheduledDeliveryMetadataKey: deliverAt})
+		if err := client.PublishEvent(c, input.ComponentName, te.Topic, data, opt); err != nil {
+			return fmt.Errorf("failed to requeue to topic %s: %w", te.Topic, err)
+		}
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.Event.requeued = true
+	return nil
+}
+
+// IsRequeued detects if the current event has been requeued by the function, so that it is acked.
+func (ctx *FunctionContext) IsRequeued() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.Event != nil && ctx.Event.requeued
+}
@@ -0,0 +1,61 @@
+package context
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dapr/go-sdk/service/common"
+)
+
+func TestRequeueWithDelay(t *testing.T) {
+	client := &publishClient{fakeDaprClient: newFakeDaprClient()}
+	ctx := &FunctionContext{
+		Event: &EventRequest{},
+		Inputs: map[string]*Input{
+			"orders": {Uri: "orders", ComponentName: "msg", ComponentType: "pubsub.kafka"},
+			"events": {ComponentName: "events", ComponentType: "bindings.kafka"},
+		},
+		daprClient: client,
+	}
+
+	ctx.SetEvent("events", &common.BindingEvent{Data: []byte("hello")})
+	if err := ctx.RequeueWithDelay(time.Minute); err == nil {
+		t.Fatal("Error requeue binding event")
+	}
+
+	ctx.SetEvent("orders", &common.TopicEvent{ID: "1", Topic: "orders", PubsubName: "msg", RawData: []byte("hello")})
+	if err := ctx.RequeueWithDelay(-time.Second); err == nil {
+		t.Fatal("Error requeue with negative delay")
+	}
+	if ctx.IsRequeued() {
+		t.Fatal("Error requeued after failure")
+	}
+
+	if err := ctx.RequeueWithDelay(time.Minute); err != nil {
+		t.Fatalf("Error requeue: %v", err)
+	}
+	if !ctx.IsRequeued() {
+		t.Fatal("Error requeued")
+	}
+	if len(client.requests) != 1 {
+		t.Fatalf("Error requeue: published %d", len(client.requests))
+	}
+	req := client.requests[0]
+	if req.PubsubName != "msg" || req.Topic != "orders" || string(req.Data) != "hello" {
+		t.Fatalf("Error requeue: published %v", req)
+	}
+	deliverAt, err := time.Parse(http.TimeFormat, req.Metadata[ScheduledDeliveryMetadataKey])
+	if err != nil {
+		t.Fatalf("Error requeue: invalid scheduled delivery %v", err)
+	}
+	if d := time.Until(deliverAt); d < 55*time.Second || d > time.Minute {
+		t.Fatalf("Error requeue: delivered in %s", d)
+	}
+
+	// the next event is not requeued
+	ctx.SetEvent("orders", &common.TopicEvent{ID: "2", Topic: "orders", PubsubName: "msg", RawData: []byte("world")})
+	if ctx.IsRequeued() {
+		t.Fatal("Error requeued for next event")
+	}
+}
@@ -0,0 +1,89 @@
+package context
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// RetryMaxAttemptsMetadataKey sets how many times the function is run for a binding event failing with
+	// an internal error or a transient failure, the retry is disabled unless it is greater than 1.
+	RetryMaxAttemptsMetadataKey = "retryMaxAttempts"
+	// RetryBackoffMetadataKey sets the wait before the first retry, doubled after each retry, 100ms by default.
+	RetryBackoffMetadataKey = "retryBackoff"
+	// RetryMaxBackoffMetadataKey caps the wait between the retries, 10s by default.
+	RetryMaxBackoffMetadataKey = "retryMaxBackoff"
+	// RetryDeadlineMetadataKey sets the time after which no more retries are made, 30s by default.
+	RetryDeadlineMetadataKey = "retryDeadline"
+
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+	defaultRetryDeadline   = 30 * time.Second
+)
+
+// RetryPolicy describes how the function is retried for the binding events failing with an internal error
+// or a transient failure.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Deadline    time.Duration
+}
+
+// NextBackoff returns the wait before the retry following the one waiting for the given backoff.
+func (p *RetryPolicy) NextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// GetRetryPolicy returns the retry policy of the input, nil if the retry is not enabled.
+func (i *Input) GetRetryPolicy() *RetryPolicy {
+	return i.retryPolicy
+}
+
+// parseRetry validates the retry policy of the input.
+func (i *Input) parseRetry() error {
+	attempts, ok := i.Metadata[RetryMaxAttemptsMetadataKey]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(attempts)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%s must be a positive integer", RetryMaxAttemptsMetadataKey)
+	}
+	if n == 1 {
+		return nil
+	}
+	if i.GetType() != OpenFuncBinding {
+		return fmt.Errorf("%s is only supported by binding inputs", RetryMaxAttemptsMetadataKey)
+	}
+
+	policy := &RetryPolicy{
+		MaxAttempts: n,
+		Backoff:     defaultRetryBackoff,
+		MaxBackoff:  defaultRetryMaxBackoff,
+		Deadline:    defaultRetryDeadline,
+	}
+	for key, d := range map[string]*time.Duration{
+		RetryBackoffMetadataKey:    &policy.Backoff,
+		RetryMaxBackoffMetadataKey: &policy.MaxBackoff,
+		RetryDeadlineMetadataKey:   &policy.Deadline,
+	} {
+		v, ok := i.Metadata[key]
+		if !ok {
+			continue
+		}
+		if *d, err = time.ParseDuration(v); err != nil || *d <= 0 {
+			return fmt.Errorf("%s must be a positive duration", key)
+		}
+	}
+	if policy.Backoff > policy.MaxBackoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+
+	i.retryPolicy = policy
+	return nil
+}
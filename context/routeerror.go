@@ -0,0 +1,41 @@
+package context
+
+import (
+	"context"
+	"fmt"
+)
+
+// RouteError is returned by a topic function to publish the failed payload to the topic,
+// such as a retry or a dead-letter topic, of the pubsub component of the input instead of redelivering it.
+type RouteError struct {
+	Topic string
+	Err   error
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("route to topic %s: %v", e.Topic, e.Err)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// PublishToTopic publishes the data to the topic of the pubsub component of the input.
+func (ctx *FunctionContext) PublishToTopic(input *Input, topic string, data []byte) error {
+	return ctx.publishToTopic(ctx.GetNativeContext(), input, topic, data)
+}
+
+// publishToTopic publishes the data to the topic of the pubsub component of the input within the native context c.
+func (ctx *FunctionContext) publishToTopic(c context.Context, input *Input, topic string, data []byte) error {
+	if input.GetType() != OpenFuncTopic {
+		return fmt.Errorf("component %s of type %s is not a pubsub", input.ComponentName, input.ComponentType)
+	}
+
+	output := &Output{
+		Uri:           topic,
+		ComponentName: input.ComponentName,
+		ComponentType: input.ComponentType,
+	}
+	_, err := ctx.sendOutput(c, output, data)
+	return err
+}
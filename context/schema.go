@@ -0,0 +1,66 @@
+package context
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// SchemaValidationError is returned when a payload does not match the JSON schema of its input.
+type SchemaValidationError struct {
+	Errors []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("payload does not match the schema: %s", strings.Join(e.Errors, "; "))
+}
+
+// loadSchema compiles the JSON schema, which is either an inline JSON document or the path of a schema file.
+func loadSchema(schema string) (*gojsonschema.Schema, error) {
+	s := strings.TrimSpace(schema)
+	if !strings.HasPrefix(s, "{") {
+		data, err := ioutil.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		s = string(data)
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewStringLoader(s))
+}
+
+func validatePayload(schema *gojsonschema.Schema, data []byte) error {
+	if schema == nil {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return &SchemaValidationError{Errors: []string{err.Error()}}
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	var errs []string
+	for _, e := range result.Errors() {
+		errs = append(errs, e.String())
+	}
+	return &SchemaValidationError{Errors: errs}
+}
+
+// ValidatePayload validates the payload against the JSON schema of the input, if any.
+func (i *Input) ValidatePayload(data []byte) error {
+	return validatePayload(i.schema, data)
+}
+
+// HasHttpSchema detects if a JSON schema is set for the body of http requests.
+func (ctx *FunctionContext) HasHttpSchema() bool {
+	return ctx.httpSchema != nil
+}
+
+// ValidateHttpPayload validates the body of http requests against the http JSON schema, if any.
+func (ctx *FunctionContext) ValidateHttpPayload(data []byte) error {
+	return validatePayload(ctx.httpSchema, data)
+}
@@ -0,0 +1,105 @@
+package context
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	orderSchema = `{
+  "type": "object",
+  "properties": {
+    "id": {"type": "string"},
+    "amount": {"type": "number", "minimum": 0}
+  },
+  "required": ["id"]
+}`
+	funcCtxWithSchema = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka",
+      "schema": %s
+    },
+    "cron": {
+      "uri": "cron_job",
+      "componentName": "cron_job",
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 2s"
+      }
+    }
+  }
+}`
+)
+
+func TestValidatePayload(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal("Error create temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	schemaFile := filepath.Join(dir, "order.json")
+	if err := ioutil.WriteFile(schemaFile, []byte(orderSchema), 0644); err != nil {
+		t.Fatal("Error write schema file")
+	}
+
+	for name, schema := range map[string]string{
+		"inline": strings.NewReplacer("\n", "", `"`, `\"`).Replace(orderSchema),
+		"file":   schemaFile,
+	} {
+		env := strings.Replace(funcCtxWithSchema, "%s", `"`+schema+`"`, 1)
+		if err := os.Setenv(FunctionContextEnvName, env); err != nil {
+			t.Fatal("Error set function context env")
+		}
+
+		ctx, err := GetRuntimeContext()
+		if err != nil {
+			t.Fatalf("Error parse function context with %s schema: %s", name, err.Error())
+		}
+
+		orders := ctx.GetInputs()["orders"]
+		if err := orders.ValidatePayload([]byte(`{"id": "1", "amount": 10}`)); err != nil {
+			t.Fatalf("Error validate valid payload with %s schema: %s", name, err.Error())
+		}
+
+		err = orders.ValidatePayload([]byte(`{"amount": -1}`))
+		verr, ok := err.(*SchemaValidationError)
+		if !ok {
+			t.Fatalf("Error detect invalid payload with %s schema: %v", name, err)
+		}
+		if len(verr.Errors) != 2 {
+			t.Fatalf("Error report validation errors with %s schema: %v", name, verr.Errors)
+		}
+
+		if err := orders.ValidatePayload([]byte(`not json`)); err == nil {
+			t.Fatalf("Error detect malformed payload with %s schema", name)
+		}
+
+		if err := ctx.GetInputs()["cron"].ValidatePayload([]byte(`not json`)); err != nil {
+			t.Fatalf("Error skip validation for input without schema: %s", err.Error())
+		}
+	}
+
+	env := strings.Replace(funcCtxWithSchema, "%s", `"`+filepath.Join(dir, "absent.json")+`"`, 1)
+	if err := os.Setenv(FunctionContextEnvName, env); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	if _, err := GetRuntimeContext(); err == nil {
+		t.Fatal("Error detect missing schema file")
+	}
+}
@@ -0,0 +1,30 @@
+package context
+
+import (
+	"context"
+)
+
+// SendTracer traces the sends to the outputs, it is set by the tracing plugins.
+type SendTracer interface {
+	// StartSend starts the child span of the send to the output within the native context,
+	// the returned function ends the span with the error of the send.
+	StartSend(ctx context.Context, outputName string, output *Output) func(err error)
+}
+
+func (ctx *FunctionContext) SetSendTracer(t SendTracer) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.sendTracer = t
+}
+
+// startSendSpan starts the span of the send to the output within the native context c, nil if the tracing is not enabled.
+func (ctx *FunctionContext) startSendSpan(c context.Context, outputName string, output *Output) func(err error) {
+	ctx.mu.Lock()
+	t := ctx.sendTracer
+	ctx.mu.Unlock()
+
+	if t == nil || ctx.PluginsTracing == nil || !ctx.PluginsTracing.IsEnabled() {
+		return nil
+	}
+	return t.StartSend(c, outputName, output)
+}
@@ -0,0 +1,89 @@
+package context
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+var funcCtxWithTracedOutput = `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost:xxx"
+    }
+  },
+  "outputs": {
+    "binding": {
+      "uri": "echo",
+      "componentName": "echo",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+
+// fakeSendTracer records the sends and the errors ending their spans
+type fakeSendTracer struct {
+	started []string
+	ended   []error
+}
+
+func (t *fakeSendTracer) StartSend(ctx context.Context, outputName string, output *Output) func(err error) {
+	t.started = append(t.started, outputName+"/"+output.ComponentName)
+	return func(err error) {
+		t.ended = append(t.ended, err)
+	}
+}
+
+// TestSendTracer tests and verifies that a span is started and ended for every send
+func TestSendTracer(t *testing.T) {
+	if err := os.Setenv(ModeEnvName, SelfHostMode); err != nil {
+		t.Fatal("Error set mode env")
+	}
+	defer os.Unsetenv(ModeEnvName)
+
+	if err := os.Setenv(FunctionContextEnvName, funcCtxWithTracedOutput); err != nil {
+		t.Fatal("Error set function context env")
+	}
+	defer os.Unsetenv(FunctionContextEnvName)
+
+	rtCtx, err := GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("Error parse function context: %s", err.Error())
+	}
+	tracer := &fakeSendTracer{}
+	rtCtx.SetSendTracer(tracer)
+
+	ctx := rtCtx.GetContext()
+	client := &failingDaprClient{fakeDaprClient: newFakeDaprClient()}
+	ctx.daprClient = client
+
+	if _, err := ctx.Send("binding", []byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	client.fail = true
+	if _, err := ctx.Send("binding", []byte("hello")); err == nil {
+		t.Fatal("Error send data to the failing output")
+	}
+
+	if len(tracer.started) != 2 || tracer.started[0] != "binding/echo" {
+		t.Fatalf("Error start the send spans: got %v", tracer.started)
+	}
+	if len(tracer.ended) != 2 || tracer.ended[0] != nil || tracer.ended[1] == nil {
+		t.Fatalf("Error end the send spans: got %v", tracer.ended)
+	}
+
+	// no span is started when the tracing is disabled
+	ctx.PluginsTracing.Enable = false
+	client.fail = false
+	if _, err := ctx.Send("binding", []byte("hello")); err != nil {
+		t.Fatalf("Error send data: %v", err)
+	}
+	if len(tracer.started) != 2 {
+		t.Fatalf("Error start a send span with the tracing disabled: got %v", tracer.started)
+	}
+}
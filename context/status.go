@@ -0,0 +1,40 @@
+package context
+
+import "net/http"
+
+// StatusClass tells how the runtimes handle the code of the function output.
+type StatusClass int
+
+const (
+	// StatusUnset is the class of the output without a code, the event is acknowledged.
+	StatusUnset StatusClass = iota
+	// StatusOK acknowledges the event as processed.
+	StatusOK
+	// StatusFailed reports the failure, the event is not redelivered unless the function asks for it.
+	StatusFailed
+	// StatusRetryable reports a transient failure, the event is redelivered.
+	StatusRetryable
+)
+
+// retryableCodes lists the error codes of the transient failures.
+var retryableCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// GetStatusClass maps the code of the function output to its status class,
+// the codes are http status codes.
+func GetStatusClass(code int) StatusClass {
+	switch {
+	case code == 0:
+		return StatusUnset
+	case code < http.StatusBadRequest:
+		return StatusOK
+	case retryableCodes[code]:
+		return StatusRetryable
+	default:
+		return StatusFailed
+	}
+}
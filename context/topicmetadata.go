@@ -0,0 +1,70 @@
+package context
+
+import (
+	"context"
+	"strconv"
+)
+
+const (
+	// TopicPartitionMetadataKey names the metadata of the topic events carrying their partition.
+	TopicPartitionMetadataKey = "partition"
+	// TopicOffsetMetadataKey names the metadata of the topic events carrying their offset.
+	TopicOffsetMetadataKey = "offset"
+)
+
+type topicMetadataKey struct{}
+
+// WithTopicEventMetadata attaches the metadata of the topic event to the context passed to the topic handler,
+// since the dapr topic event cannot carry it. It is used by the input adapters such as kafka.
+func WithTopicEventMetadata(c context.Context, metadata map[string]string) context.Context {
+	return context.WithValue(c, topicMetadataKey{}, metadata)
+}
+
+// TopicEventMetadataFromContext returns the metadata of the topic event attached to the context, if any.
+func TopicEventMetadataFromContext(c context.Context) map[string]string {
+	metadata, _ := c.Value(topicMetadataKey{}).(map[string]string)
+	return metadata
+}
+
+// SetTopicEventMetadata sets the metadata of the current topic event.
+func (ctx *FunctionContext) SetTopicEventMetadata(metadata map[string]string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.Event.topicMetadata = metadata
+}
+
+// GetTopicEventMetadata returns the metadata of the current topic event, nil if it carries none.
+func (ctx *FunctionContext) GetTopicEventMetadata() map[string]string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.Event == nil || ctx.Event.TopicEvent == nil {
+		return nil
+	}
+	return ctx.Event.topicMetadata
+}
+
+// GetTopicPartition returns the partition of the current topic event, false if it carries no valid partition.
+func (ctx *FunctionContext) GetTopicPartition() (int32, bool) {
+	value, ok := ctx.GetTopicEventMetadata()[TopicPartitionMetadataKey]
+	if !ok {
+		return 0, false
+	}
+	partition, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(partition), true
+}
+
+// GetTopicOffset returns the offset of the current topic event, false if it carries no valid offset.
+func (ctx *FunctionContext) GetTopicOffset() (int64, bool) {
+	value, ok := ctx.GetTopicEventMetadata()[TopicOffsetMetadataKey]
+	if !ok {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return offset, true
+}
@@ -0,0 +1,53 @@
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dapr/go-sdk/service/common"
+)
+
+func TestTopicPartitionAndOffset(t *testing.T) {
+	ctx := &FunctionContext{Event: &EventRequest{}}
+
+	ctx.SetEvent("orders", &common.TopicEvent{ID: "1", RawData: []byte("hello")})
+	if _, ok := ctx.GetTopicPartition(); ok {
+		t.Fatal("Error get partition of topic event without metadata")
+	}
+	if _, ok := ctx.GetTopicOffset(); ok {
+		t.Fatal("Error get offset of topic event without metadata")
+	}
+
+	c := WithTopicEventMetadata(context.Background(), map[string]string{
+		TopicPartitionMetadataKey: "3",
+		TopicOffsetMetadataKey:    "9007199254740993",
+	})
+	ctx.SetTopicEventMetadata(TopicEventMetadataFromContext(c))
+	if partition, ok := ctx.GetTopicPartition(); !ok || partition != 3 {
+		t.Fatalf("Error get partition: got %d, %v", partition, ok)
+	}
+	if offset, ok := ctx.GetTopicOffset(); !ok || offset != 9007199254740993 {
+		t.Fatalf("Error get offset: got %d, %v", offset, ok)
+	}
+
+	ctx.SetTopicEventMetadata(map[string]string{
+		TopicPartitionMetadataKey: "4294967296",
+		TopicOffsetMetadataKey:    "latest",
+	})
+	if _, ok := ctx.GetTopicPartition(); ok {
+		t.Fatal("Error get partition out of range")
+	}
+	if _, ok := ctx.GetTopicOffset(); ok {
+		t.Fatal("Error get invalid offset")
+	}
+
+	// the metadata does not outlive its topic event
+	ctx.SetTopicEventMetadata(map[string]string{TopicPartitionMetadataKey: "1"})
+	ctx.SetEvent("events", &common.BindingEvent{Data: []byte("hello")})
+	if _, ok := ctx.GetTopicPartition(); ok {
+		t.Fatal("Error get partition of binding event")
+	}
+	if TopicEventMetadataFromContext(context.Background()) != nil {
+		t.Fatal("Error get metadata of context without topic event")
+	}
+}
@@ -0,0 +1,58 @@
+package context
+
+// Set stores the value under the key in the context, to share data between the plugins and the function.
+// The values stored during an invocation are kept in the invocation context returned by NewInvocationContext.
+func (ctx *FunctionContext) Set(key string, v interface{}) {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	if ctx.values == nil {
+		ctx.values = map[string]interface{}{}
+	}
+	ctx.values[key] = v
+}
+
+// Value returns the value stored under the key in the context.
+func (ctx *FunctionContext) Value(key string) (interface{}, bool) {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	v, ok := ctx.values[key]
+	return v, ok
+}
+
+// ResetValues clears the values stored in the context.
+func (ctx *FunctionContext) ResetValues() {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	ctx.values = nil
+}
+
+// Set stores the value under the key for the rest of the invocation.
+func (ctx *invocationContext) Set(key string, v interface{}) {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	if ctx.values == nil {
+		ctx.values = map[string]interface{}{}
+	}
+	ctx.values[key] = v
+}
+
+// Value returns the value stored under the key in the invocation.
+func (ctx *invocationContext) Value(key string) (interface{}, bool) {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	v, ok := ctx.values[key]
+	return v, ok
+}
+
+// ResetValues clears the values stored in the invocation.
+func (ctx *invocationContext) ResetValues() {
+	ctx.valuesMu.Lock()
+	defer ctx.valuesMu.Unlock()
+
+	ctx.values = nil
+}
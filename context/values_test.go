@@ -0,0 +1,67 @@
+package context
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	ctx := &FunctionContext{}
+
+	if _, ok := ctx.Value("missing"); ok {
+		t.Fatal("Error get value never set")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("key-%d-%d", i, j)
+				ctx.Set(key, j)
+				if v, ok := ctx.Value(key); !ok || v.(int) != j {
+					t.Errorf("Error get value %s: got %v", key, v)
+				}
+				ctx.Value("shared")
+				ctx.Set("shared", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if v, ok := ctx.Value("key-3-42"); !ok || v.(int) != 42 {
+		t.Fatalf("Error get value: got %v", v)
+	}
+
+	ctx.ResetValues()
+	if _, ok := ctx.Value("shared"); ok {
+		t.Fatal("Error get value after reset")
+	}
+}
+
+func TestInvocationContextValues(t *testing.T) {
+	fc := &FunctionContext{}
+	fc.Set("shared", "function")
+
+	first := NewInvocationContext(fc)
+	second := NewInvocationContext(fc)
+	first.Set("key", "first")
+
+	if _, ok := second.Value("key"); ok {
+		t.Fatal("Error get value of another invocation")
+	}
+	if _, ok := fc.Value("key"); ok {
+		t.Fatal("Error get value of an invocation from the function context")
+	}
+	if _, ok := first.Value("shared"); ok {
+		t.Fatal("Error get value of the function context from an invocation")
+	}
+	if v, ok := first.Value("key"); !ok || v.(string) != "first" {
+		t.Fatalf("Error get value: got %v", v)
+	}
+	if first.GetContext() != fc {
+		t.Fatal("Error get function context of the invocation")
+	}
+}
@@ -3,46 +3,85 @@ package framework
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"strings"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"k8s.io/klog/v2"
 
 	ofctx "github.com/tpiperatgod/offf-go/context"
 	"github.com/tpiperatgod/offf-go/plugin"
-	plgExample "github.com/tpiperatgod/offf-go/plugin/plugin-example"
+	// Register the default plugins into the plugin registry
+	_ "github.com/tpiperatgod/offf-go/plugin/debug"
+	_ "github.com/tpiperatgod/offf-go/plugin/plugin-example"
+	_ "github.com/tpiperatgod/offf-go/plugin/ratelimit"
 	"github.com/tpiperatgod/offf-go/runtime"
 	"github.com/tpiperatgod/offf-go/runtime/async"
 	"github.com/tpiperatgod/offf-go/runtime/knative"
 )
 
 type functionsFrameworkImpl struct {
-	funcContext ofctx.RuntimeContext
-	prePlugins  []plugin.Plugin
-	postPlugins []plugin.Plugin
-	pluginMap   map[string]plugin.Plugin
-	runtime     runtime.Interface
+	funcContext       ofctx.RuntimeContext
+	prePlugins        []plugin.Plugin
+	postPlugins       []plugin.Plugin
+	pluginMap         map[string]plugin.Plugin
+	pluginsRegistered bool
+	interceptors      []Interceptor
+	warmUps           []WarmUpFunc
+	runtime           runtime.Interface
 }
 
+// HandlerFunc is the signature of the OpenFunction functions.
+type HandlerFunc func(ofctx.Context, []byte) (ofctx.Out, error)
+
+// WarmUpFunc runs the expensive initialization of the function, such as loading models or warming caches,
+// before the function is served.
+type WarmUpFunc func(ofctx.Context) error
+
+// Interceptor wraps the function call, it can modify the input data before calling next
+// and the output after it returns. Plugins are preferred for the side effects.
+type Interceptor func(next HandlerFunc) HandlerFunc
+
 // Framework is the interface for the function conversion.
 type Framework interface {
 	Register(ctx context.Context, fn interface{}) error
+	RegisterWithPattern(ctx context.Context, pattern string, fn interface{}) error
 	RegisterPlugins(customPlugins map[string]plugin.Plugin)
+	Use(interceptors ...Interceptor)
+	WarmUp(fns ...WarmUpFunc)
 	Start(ctx context.Context) error
 	GetRuntime() runtime.Interface
+	Port() string
+	HttpPattern() string
 }
 
-func NewFramework() (*functionsFrameworkImpl, error) {
+// NewFramework creates the framework of the function context parsed from FUNC_CONTEXT,
+// or of the function context built in code and given with ofctx.WithFunctionContext.
+func NewFramework(opts ...ofctx.RuntimeContextOption) (*functionsFrameworkImpl, error) {
 	fwk := &functionsFrameworkImpl{}
 
+	// Apply the logging configuration before anything is logged
+	if err := configureLogging(); err != nil {
+		klog.Errorf("failed to configure logging: %v\n", err)
+		return nil, err
+	}
+
 	// Parse OpenFunction FunctionContext
-	if ctx, err := ofctx.GetRuntimeContext(); err != nil {
+	if ctx, err := ofctx.GetRuntimeContext(opts...); err != nil {
 		klog.Errorf("failed to parse OpenFunction FunctionContext: %v\n", err)
 		return nil, err
 	} else {
 		fwk.funcContext = ctx
 	}
 
+	// Register the Avro decoder once, so that the schemas it resolves are cached across the parses
+	if codec := ofctx.NewAvroCodecFromEnv(); codec != nil {
+		ofctx.RegisterDecoder(codec)
+	}
+
 	// Scan the local directory and register the plugins if exist
 	// Register the framework default plugins under `plugin` directory
 	fwk.pluginMap = map[string]plugin.Plugin{}
@@ -56,19 +95,82 @@ func NewFramework() (*functionsFrameworkImpl, error) {
 	return fwk, nil
 }
 
+// Validate verifies the function context, the runtime and the plugins the same way NewFramework
+// and RegisterPlugins do, but without creating the runtime service, so that no port is bound.
+func Validate(customPlugins map[string]plugin.Plugin, opts ...ofctx.RuntimeContextOption) error {
+	ctx, err := ofctx.GetRuntimeContext(opts...)
+	if err != nil {
+		klog.Errorf("failed to parse OpenFunction FunctionContext: %v\n", err)
+		return err
+	}
+
+	if err := validateRuntime(ctx); err != nil {
+		klog.Errorf("failed to validate runtime: %v\n", err)
+		return err
+	}
+
+	fwk := &functionsFrameworkImpl{funcContext: ctx}
+	if errs := fwk.registerPlugins(customPlugins); len(errs) > 0 {
+		klog.Errorf("failed to validate plugins: %v\n", errs[0])
+		return errs[0]
+	}
+
+	return nil
+}
+
 func (fwk *functionsFrameworkImpl) Register(ctx context.Context, fn interface{}) error {
+	return fwk.register(ctx, fwk.runtime, fn)
+}
+
+// RegisterWithPattern registers the function on the http pattern in place of the httpPattern of the function context,
+// so that several functions are served by the process, each on its own pattern. It fails if the pattern collides
+// with a pattern already registered, or if the runtime is not Knative.
+func (fwk *functionsFrameworkImpl) RegisterWithPattern(ctx context.Context, pattern string, fn interface{}) error {
+	rt, ok := fwk.runtime.(*knative.Runtime)
+	if !ok {
+		err := fmt.Errorf("the http patterns are not supported by the %s runtime", fwk.funcContext.GetRuntime())
+		klog.Errorf("failed to register function: %v", err)
+		return err
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		err := fmt.Errorf("invalid http pattern %q, it must start with /", pattern)
+		klog.Errorf("failed to register function: %v", err)
+		return err
+	}
+	return fwk.register(ctx, rt.WithPattern(pattern), fn)
+}
+
+func (fwk *functionsFrameworkImpl) register(ctx context.Context, rt runtime.Interface, fn interface{}) error {
+	// The plugins are bound to the function at registration,
+	// so register the default plugins if RegisterPlugins has not been called yet.
+	if !fwk.pluginsRegistered {
+		klog.Warning("RegisterPlugins should be called before Register, registering the default plugins")
+		fwk.RegisterPlugins(nil)
+	}
+
 	if fnHTTP, ok := fn.(func(http.ResponseWriter, *http.Request)); ok {
-		if err := fwk.runtime.RegisterHTTPFunction(fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnHTTP); err != nil {
+		if err := rt.RegisterHTTPFunction(fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnHTTP); err != nil {
 			klog.Errorf("failed to register function: %v", err)
 			return err
 		}
 	} else if fnOpenFunction, ok := fn.(func(ofctx.Context, []byte) (ofctx.Out, error)); ok {
-		if err := fwk.runtime.RegisterOpenFunction(fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnOpenFunction); err != nil {
+		fnOpenFunction = fwk.intercept(fnOpenFunction)
+		if err := rt.RegisterOpenFunction(fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnOpenFunction); err != nil {
+			klog.Errorf("failed to register function: %v", err)
+			return err
+		}
+	} else if fnStream, ok := fn.(func(ofctx.Context, io.Reader) (ofctx.Out, error)); ok {
+		if err := rt.RegisterStreamFunction(fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnStream); err != nil {
 			klog.Errorf("failed to register function: %v", err)
 			return err
 		}
 	} else if fnCloudEvent, ok := fn.(func(context.Context, cloudevents.Event) error); ok {
-		if err := fwk.runtime.RegisterCloudEventFunction(ctx, fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnCloudEvent); err != nil {
+		if err := rt.RegisterCloudEventFunction(ctx, fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnCloudEvent); err != nil {
+			klog.Errorf("failed to register function: %v", err)
+			return err
+		}
+	} else if fnCloudEventResponse, ok := fn.(func(context.Context, cloudevents.Event) (*cloudevents.Event, error)); ok {
+		if err := rt.RegisterCloudEventResponseFunction(ctx, fwk.funcContext, fwk.prePlugins, fwk.postPlugins, fnCloudEventResponse); err != nil {
 			klog.Errorf("failed to register function: %v", err)
 			return err
 		}
@@ -80,8 +182,37 @@ func (fwk *functionsFrameworkImpl) Register(ctx context.Context, fn interface{})
 	return nil
 }
 
+// Use appends the interceptors applied around the OpenFunction functions registered afterwards,
+// the first interceptor is the outermost one.
+func (fwk *functionsFrameworkImpl) Use(interceptors ...Interceptor) {
+	fwk.interceptors = append(fwk.interceptors, interceptors...)
+}
+
+func (fwk *functionsFrameworkImpl) intercept(fn HandlerFunc) HandlerFunc {
+	for i := len(fwk.interceptors) - 1; i >= 0; i-- {
+		fn = fwk.interceptors[i](fn)
+	}
+	return fn
+}
+
+// WarmUp appends the functions run in order by Start before the runtime serves, the start fails if any of them fails.
+func (fwk *functionsFrameworkImpl) WarmUp(fns ...WarmUpFunc) {
+	fwk.warmUps = append(fwk.warmUps, fns...)
+}
+
 func (fwk *functionsFrameworkImpl) Start(ctx context.Context) error {
+	for _, warmUp := range fwk.warmUps {
+		if err := warmUp(fwk.funcContext.GetContext()); err != nil {
+			klog.Errorf("failed to warm up function: %v", err)
+			return fmt.Errorf("failed to warm up function: %w", err)
+		}
+	}
+
 	err := fwk.runtime.Start(ctx)
+	fwk.stopPlugins()
+	fwk.funcContext.CloseBufferedSenders()
+	// the configuration subscriptions and the dapr health check end along with the dapr client
+	fwk.funcContext.DestroyDaprClient()
 	if err != nil {
 		klog.Error("failed to start runtime service")
 		return err
@@ -89,45 +220,138 @@ func (fwk *functionsFrameworkImpl) Start(ctx context.Context) error {
 	return nil
 }
 
+// stopPlugins stops the background work of the plugins once the runtime stops serving.
+func (fwk *functionsFrameworkImpl) stopPlugins() {
+	for _, plg := range fwk.pluginMap {
+		if s, ok := plg.(plugin.Stoppable); ok {
+			s.Stop()
+		}
+	}
+}
+
 func (fwk *functionsFrameworkImpl) RegisterPlugins(customPlugins map[string]plugin.Plugin) {
-	// Register default plugins
-	fwk.pluginMap = map[string]plugin.Plugin{
-		plgExample.Name: plgExample.New(),
+	for _, err := range fwk.registerPlugins(customPlugins) {
+		klog.Warning(err)
 	}
+}
+
+// registerPlugins registers the custom plugins along with the configured plugins of the plugin registry,
+// and resolves the plugins of each stage, returning the problems found:
+// duplicate plugin names and plugin names that cannot be resolved.
+func (fwk *functionsFrameworkImpl) registerPlugins(customPlugins map[string]plugin.Plugin) []error {
+	var errs []error
 
 	// Register custom plugins
-	if customPlugins != nil {
-		for name, plg := range customPlugins {
-			if _, ok := fwk.pluginMap[name]; !ok {
-				fwk.pluginMap[name] = plg
-			} else {
-				// Skip the registration of plugin with name that already exist
-				continue
-			}
+	fwk.pluginMap = map[string]plugin.Plugin{}
+	for name, plg := range customPlugins {
+		if _, ok := plugin.Lookup(name); ok {
+			// Skip the registration of plugin with name that already exist
+			errs = append(errs, fmt.Errorf("plugin %s is already registered, skipping", name))
+			continue
 		}
+		fwk.pluginMap[name] = plg
 	}
 
-	klog.Infoln("Plugins for pre-hook stage:")
-	for _, plgName := range fwk.funcContext.GetPrePlugins() {
-		if plg, ok := fwk.pluginMap[plgName]; ok {
-			klog.Infof("- %s", plg.Name())
-			fwk.prePlugins = append(fwk.prePlugins, plg)
+	// Register the plugins configured by name from the plugin registry,
+	// the default plugins are only instantiated when they are configured.
+	for _, plgName := range append(fwk.funcContext.GetPrePlugins(), fwk.funcContext.GetPostPlugins()...) {
+		if _, ok := fwk.pluginMap[plgName]; ok {
+			continue
+		}
+		if factory, ok := plugin.Lookup(plgName); ok {
+			fwk.pluginMap[plgName] = factory()
 		}
 	}
 
+	fwk.pluginsRegistered = true
+
+	disabled := disabledPlugins()
+	for name := range disabled {
+		klog.Infof("plugin %s is disabled by %s", name, ofctx.DisabledPluginsEnvName)
+	}
+
+	klog.Infoln("Plugins for pre-hook stage:")
+	var pluginErrs []error
+	fwk.prePlugins, pluginErrs = fwk.resolvePlugins("pre-hook", fwk.funcContext.GetPrePlugins(), disabled)
+	errs = append(errs, pluginErrs...)
+
 	klog.Infoln("Plugins for post-hook stage:")
-	for _, plgName := range fwk.funcContext.GetPostPlugins() {
+	fwk.postPlugins, pluginErrs = fwk.resolvePlugins("post-hook", fwk.funcContext.GetPostPlugins(), disabled)
+	errs = append(errs, pluginErrs...)
+
+	return errs
+}
+
+// resolvePlugins resolves the plugins of the stage by name, skipping the disabled plugins.
+func (fwk *functionsFrameworkImpl) resolvePlugins(stage string, names []string, disabled map[string]bool) ([]plugin.Plugin, []error) {
+	var plugins []plugin.Plugin
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, plgName := range names {
+		if seen[plgName] {
+			errs = append(errs, fmt.Errorf("plugin %s is configured more than once for %s stage, skipping", plgName, stage))
+			continue
+		}
+		seen[plgName] = true
+
+		if disabled[plgName] {
+			klog.Infof("- %s (disabled)", plgName)
+			continue
+		}
+
 		if plg, ok := fwk.pluginMap[plgName]; ok {
 			klog.Infof("- %s", plg.Name())
-			fwk.postPlugins = append(fwk.postPlugins, plg)
+			plugins = append(plugins, plg)
+		} else {
+			errs = append(errs, fmt.Errorf("plugin %s configured for %s stage is not registered", plgName, stage))
 		}
 	}
+	return plugins, errs
+}
+
+// disabledPlugins returns the names of the plugins disabled by the comma-separated DISABLED_PLUGINS env.
+func disabledPlugins() map[string]bool {
+	disabled := map[string]bool{}
+	for _, name := range strings.Split(os.Getenv(ofctx.DisabledPluginsEnvName), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			disabled[name] = true
+		}
+	}
+	return disabled
 }
 
 func (fwk *functionsFrameworkImpl) GetRuntime() runtime.Interface {
 	return fwk.runtime
 }
 
+func validateRuntime(ctx ofctx.RuntimeContext) error {
+	switch ctx.GetRuntime() {
+	case ofctx.Knative:
+		return nil
+	case ofctx.Async:
+		if !ctx.HasInputs() {
+			return errors.New("no inputs defined for the function")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid runtime: %s", ctx.GetRuntime())
+	}
+}
+
+// Port returns the port the function service listens on, after the defaults are applied.
+func (fwk *functionsFrameworkImpl) Port() string {
+	return fwk.funcContext.GetPort()
+}
+
+// HttpPattern returns the http pattern the function is served on, after the defaults are applied.
+func (fwk *functionsFrameworkImpl) HttpPattern() string {
+	if rt, ok := fwk.runtime.(*knative.Runtime); ok {
+		return rt.Pattern()
+	}
+	return fwk.funcContext.GetHttpPattern()
+}
+
 func createRuntime(fwk *functionsFrameworkImpl) error {
 	var err error
 
@@ -3,24 +3,90 @@ package framework
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/big"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	commonv1 "github.com/dapr/dapr/pkg/proto/common/v1"
 	"github.com/dapr/dapr/pkg/proto/runtime/v1"
 	"github.com/dapr/go-sdk/service/common"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"k8s.io/klog/v2"
 
 	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/plugin"
+	ofruntime "github.com/tpiperatgod/offf-go/runtime"
 	"github.com/tpiperatgod/offf-go/runtime/async"
+	"github.com/tpiperatgod/offf-go/runtime/knative"
 )
 
+type slowPlugin struct {
+	delay time.Duration
+	// returned, if set, is told each time a hook returns
+	returned chan struct{}
+}
+
+func (p *slowPlugin) Name() string {
+	return "plugin-slow"
+}
+
+func (p *slowPlugin) Version() string {
+	return "v1"
+}
+
+func (p *slowPlugin) Init() plugin.Plugin {
+	return p
+}
+
+func (p *slowPlugin) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	time.Sleep(p.delay)
+	if p.returned != nil {
+		p.returned <- struct{}{}
+	}
+	return nil
+}
+
+func (p *slowPlugin) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	time.Sleep(p.delay)
+	if p.returned != nil {
+		p.returned <- struct{}{}
+	}
+	return nil
+}
+
+func (p *slowPlugin) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
 func fakeHTTPFunction(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Hello World!")
 }
@@ -95,29 +161,120 @@ func TestHTTPFunction(t *testing.T) {
 	}
 }
 
-func TestCloudEventFunction(t *testing.T) {
+func TestHTTPFunctionMethods(t *testing.T) {
 	env := `{
   "name": "function-demo",
   "version": "v1.0.0",
   "port": "8080",
   "runtime": "Knative",
-  "httpPattern": "/ce"
+  "httpPattern": "/methods",
+  "httpMethods": ["get", "POST"]
 }`
-	var ceDemo = struct {
-		message map[string]string
-		headers map[string]string
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	for method, want := range map[string]int{
+		http.MethodGet:    http.StatusOK,
+		http.MethodPost:   http.StatusOK,
+		http.MethodPut:    http.StatusMethodNotAllowed,
+		http.MethodDelete: http.StatusMethodNotAllowed,
+	} {
+		req, err := http.NewRequest(method, srv.URL+"/methods", nil)
+		if err != nil {
+			t.Fatalf("error creating HTTP request for test: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Fatalf("TestHTTPFunctionMethods: %s got status %v; want %v", method, resp.StatusCode, want)
+		}
+		if want == http.StatusMethodNotAllowed && resp.Header.Get("Allow") != "GET, POST" {
+			t.Fatalf("TestHTTPFunctionMethods: got Allow header %q", resp.Header.Get("Allow"))
+		}
+	}
+}
+
+func TestHTTPFunctionErrorResponse(t *testing.T) {
+	for pattern, tc := range map[string]struct {
+		opts        []ofctx.RuntimeContextOption
+		contentType string
+		body        string
 	}{
-		message: map[string]string{
-			"msg": "Hello World!",
+		"/errors-json": {
+			contentType: "application/json",
+			body:        `{"error":"Method Not Allowed","requestId":"req-1"}`,
 		},
-		headers: map[string]string{
-			"Ce-Specversion": "1.0",
-			"Ce-Type":        "cloudevents.openfunction.samples.helloworld",
-			"Ce-Source":      "cloudevents.openfunction.samples/helloworldsource",
-			"Ce-Id":          "536808d3-88be-4077-9d7a-a3f162705f79",
+		"/errors-text": {
+			opts:        []ofctx.RuntimeContextOption{ofctx.WithErrorResponseFormatter(ofctx.PlainTextErrorResponse)},
+			contentType: "text/plain; charset=utf-8",
+			body:        "Method Not Allowed\n",
 		},
+	} {
+		env := fmt.Sprintf(`{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "%s",
+  "httpMethods": ["GET"]
+}`, pattern)
+		ctx := context.Background()
+		fwk, err := createFramework(env, tc.opts...)
+		if err != nil {
+			t.Fatalf("failed to create framework: %v", err)
+		}
+
+		fwk.RegisterPlugins(nil)
+
+		if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+			t.Fatalf("failed to register HTTP function: %v\n", err)
+		}
+
+		srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+
+		req, err := http.NewRequest(http.MethodPut, srv.URL+pattern, nil)
+		if err != nil {
+			t.Fatalf("error creating HTTP request for test: %v", err)
+		}
+		req.Header.Set(knative.RequestIDHeader, "req-1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"))
+		assert.Equal(t, tc.body, string(data))
 	}
+}
 
+func TestHTTPFunctionPathParams(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/orders/{id}/items/{item}"
+}`
 	ctx := context.Background()
 	fwk, err := createFramework(env)
 	if err != nil {
@@ -126,62 +283,54 @@ func TestCloudEventFunction(t *testing.T) {
 
 	fwk.RegisterPlugins(nil)
 
-	if err := fwk.Register(ctx, fakeCloudEventsFunction); err != nil {
-		t.Fatalf("failed to register CloudEvents function: %v", err)
-	}
-
-	if fwk.GetRuntime() == nil {
-		t.Fatal("failed to create runtime")
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		data := fmt.Sprintf("%s-%s", ctx.GetPathParam("id"), ctx.GetPathParam("item"))
+		return ctx.ReturnOnSuccess().WithData([]byte(data)), nil
 	}
-
-	handler := fwk.GetRuntime().GetHandler()
-	if handler == nil {
-		t.Fatal("handler is nil")
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
 	}
 
-	srv := httptest.NewServer(handler.(http.Handler))
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
 	defer srv.Close()
 
-	messageByte, err := json.Marshal(ceDemo.message)
+	resp, err := http.Get(srv.URL + "/orders/42/items/book")
 	if err != nil {
-		t.Fatalf("failed to marshal message: %v", err)
+		t.Fatalf("http.Get: %v", err)
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequest("POST", srv.URL+"/ce", bytes.NewBuffer(messageByte))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("TestHTTPFunctionPathParams: got status %v; want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		t.Fatalf("error creating HTTP request for test: %v", err)
+		t.Fatalf("ioutil.ReadAll: %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range ceDemo.headers {
-		req.Header.Set(k, v)
+	if got, want := string(body), "42-book"; got != want {
+		t.Fatalf("TestHTTPFunctionPathParams: got %v; want %v", got, want)
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+
+	resp, err = http.Get(srv.URL + "/orders/42")
 	if err != nil {
-		t.Fatalf("failed to do client.Do: %v", err)
+		t.Fatalf("http.Get: %v", err)
 	}
+	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("failed to test cloudevents function: response status = %v, want %v", resp.StatusCode, http.StatusOK)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("TestHTTPFunctionPathParams: got status %v; want %v", resp.StatusCode, http.StatusNotFound)
 	}
 }
 
-func TestAsyncBindingsFunction(t *testing.T) {
+func TestHTTPFunctionMultiplePatterns(t *testing.T) {
 	env := `{
   "name": "function-demo",
-  "version": "v1",
-  "runtime": "Async",
-  "requestID": "a0f2ad8d-5062-4812-91e9-95416489fb01",
-  "port": "50003",
-  "inputs": {
-    "cron": {
-      "uri": "test",
-      "componentName": "test",
-      "componentType": "bindings.Kafka"
-    }
-  }
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/shop/cart"
 }`
-	methodName := "test"
 	ctx := context.Background()
 	fwk, err := createFramework(env)
 	if err != nil {
@@ -190,85 +339,75 @@ func TestAsyncBindingsFunction(t *testing.T) {
 
 	fwk.RegisterPlugins(nil)
 
-	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
-		t.Fatalf("failed to register CloudEvents function: %v", err)
+	cart := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "cart")
 	}
-
-	if fwk.GetRuntime() == nil {
-		t.Fatal("failed to create runtime")
+	product := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		return ctx.ReturnOnSuccess().WithData([]byte("product " + ctx.GetPathParam("id"))), nil
 	}
-
-	server := fwk.GetRuntime().GetHandler()
-	if server == nil {
-		t.Fatal("server is nil")
+	if err := fwk.Register(ctx, cart); err != nil {
+		t.Fatalf("failed to register HTTP function: %v", err)
+	}
+	if err := fwk.RegisterWithPattern(ctx, "/shop/products/{id}", product); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v", err)
 	}
-	s := server.(*async.FakeServer)
-	startTestServer(s)
-
-	t.Run("binding without event", func(t *testing.T) {
-		_, err := s.OnBindingEvent(ctx, nil)
-		assert.Error(t, err)
-	})
 
-	t.Run("binding event for wrong method", func(t *testing.T) {
-		in := &runtime.BindingEventRequest{Name: "invalid"}
-		_, err := s.OnBindingEvent(ctx, in)
-		assert.Error(t, err)
-	})
+	// the collisions are rejected
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "/shop/cart", cart))
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "/shop/products/{name}", product))
+	assert.Error(t, fwk.Register(ctx, cart))
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "shop/orders", cart))
 
-	t.Run("binding event without data", func(t *testing.T) {
-		in := &runtime.BindingEventRequest{Name: methodName}
-		out, err := s.OnBindingEvent(ctx, in)
-		assert.NoError(t, err)
-		assert.NotNil(t, out)
-	})
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
 
-	t.Run("binding event with data", func(t *testing.T) {
-		data := "hello there"
-		in := &runtime.BindingEventRequest{
-			Name: methodName,
-			Data: []byte(data),
+	for path, want := range map[string]string{
+		"/shop/cart":        "cart",
+		"/shop/products/42": "product 42",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
 		}
-		out, err := s.OnBindingEvent(ctx, in)
-		assert.NoError(t, err)
-		assert.NotNil(t, out)
-		assert.Equal(t, data, string(out.Data))
-	})
-
-	t.Run("binding event with metadata", func(t *testing.T) {
-		in := &runtime.BindingEventRequest{
-			Name:     methodName,
-			Metadata: map[string]string{"k1": "v1", "k2": "v2"},
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll: %v", err)
 		}
-		out, err := s.OnBindingEvent(ctx, in)
-		assert.NoError(t, err)
-		assert.NotNil(t, out)
-	})
-
-	stopTestServer(t, s)
-
-}
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want, string(body))
+	}
+	assert.Equal(t, "/shop/cart", fwk.HttpPattern())
 
-func TestAsyncPubsubTopic(t *testing.T) {
-	env := `{
+	// the patterns are only served by the knative runtime
+	env = `{
   "name": "function-demo",
   "version": "v1",
   "runtime": "Async",
-  "requestID": "a0f2ad8d-5062-4812-91e9-95416489fb01",
-  "port": "50003",
+  "port": "50034",
   "inputs": {
-    "sub": {
-      "uri": "my_topic",
-      "componentName": "msg",
-      "componentType": "pubsub.kafka"
+    "events": {
+      "componentName": "events",
+      "componentType": "bindings.kafka"
     }
   }
 }`
-
-	sub := &common.Subscription{
-		PubsubName: "msg",
-		Topic:      "my_topic",
+	fwk, err = createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
 	}
+	fwk.RegisterPlugins(nil)
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "/shop/async", product))
+}
+
+func TestHTTPFunctionSharedPrefixPatterns(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/store/orders/{id}"
+}`
 	ctx := context.Background()
 	fwk, err := createFramework(env)
 	if err != nil {
@@ -277,63 +416,4018 @@ func TestAsyncPubsubTopic(t *testing.T) {
 
 	fwk.RegisterPlugins(nil)
 
-	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
-		t.Fatalf("failed to register CloudEvents function: %v", err)
+	reply := func(name string) func(ofctx.Context, []byte) (ofctx.Out, error) {
+		return func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+			params := []string{name}
+			for _, param := range []string{"id", "item", ofctx.CatchAllPathParam} {
+				if v := ctx.GetPathParam(param); v != "" {
+					params = append(params, param+"="+v)
+				}
+			}
+			return ctx.ReturnOnSuccess().WithData([]byte(strings.Join(params, " "))), nil
+		}
 	}
-
-	if fwk.GetRuntime() == nil {
-		t.Fatal("failed to create runtime")
+	if err := fwk.Register(ctx, reply("order")); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v", err)
 	}
-
-	server := fwk.GetRuntime().GetHandler()
-	if server == nil {
-		t.Fatal("server is nil")
+	// the patterns sharing the prefix /store/orders/ are served side by side
+	for pattern, name := range map[string]string{
+		"/store/orders/{id}/items":        "items",
+		"/store/orders/{id}/items/{item}": "item",
+		"/store/orders/{id}/*":            "order-files",
+	} {
+		if err := fwk.RegisterWithPattern(ctx, pattern, reply(name)); err != nil {
+			t.Fatalf("failed to register pattern %s: %v", pattern, err)
+		}
 	}
-	s := server.(*async.FakeServer)
-	startTestServer(s)
 
-	t.Run("topic event without request", func(t *testing.T) {
-		_, err := s.OnTopicEvent(ctx, nil)
-		assert.Error(t, err)
-	})
+	// the pattern matching the same paths as a registered one is rejected
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "/store/orders/{order}/items/{sku}", reply("item")))
 
-	t.Run("topic event for wrong topic", func(t *testing.T) {
-		in := &runtime.TopicEventRequest{
-			Topic: "invalid",
-		}
-		_, err := s.OnTopicEvent(ctx, in)
-		assert.Error(t, err)
-	})
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
 
-	t.Run("topic event for valid topic", func(t *testing.T) {
-		in := &runtime.TopicEventRequest{
-			Id:              "a123",
-			Source:          "test",
-			Type:            "test",
-			SpecVersion:     "v1.0",
-			DataContentType: "text/plain",
-			Data:            []byte("test"),
-			Topic:           sub.Topic,
-			PubsubName:      sub.PubsubName,
+	for path, want := range map[string]string{
+		"/store/orders/42":               "order id=42",
+		"/store/orders/42/items":         "items id=42",
+		"/store/orders/42/items/7":       "item id=42 item=7",
+		"/store/orders/42/invoice.pdf":   "order-files id=42 *=invoice.pdf",
+		"/store/orders/42/items/7/notes": "order-files id=42 *=items/7/notes",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
 		}
-		_, err := s.OnTopicEvent(ctx, in)
-		assert.NoError(t, err)
-	})
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, want, string(body), path)
+	}
 
-	stopTestServer(t, s)
+	resp, err := http.Get(srv.URL + "/store/orders/")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
-func createFramework(env string) (Framework, error) {
-	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
-	os.Setenv(ofctx.TestModeEnvName, ofctx.TestModeOn)
-	os.Setenv(ofctx.FunctionContextEnvName, env)
-	fwk, err := NewFramework()
+func TestHTTPFunctionSchema(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/schema",
+  "httpSchema": "{\"type\": \"object\", \"required\": [\"id\"]}"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
 	if err != nil {
-		return nil, err
-	} else {
-		return fwk, nil
+		t.Fatalf("failed to create framework: %v", err)
 	}
-}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	for body, want := range map[string]int{
+		`{"id": "1"}`: http.StatusOK,
+		`{"name": 1}`: http.StatusBadRequest,
+		`not json`:    http.StatusBadRequest,
+	} {
+		resp, err := http.Post(srv.URL+"/schema", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Fatalf("TestHTTPFunctionSchema: %s got status %v; want %v", body, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestHTTPOpenFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/open"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if string(in) == "fail" {
+			return ctx.ReturnOnInternalError(), fmt.Errorf("function failed")
+		}
+		return ctx.ReturnOnSuccess().WithData(bytes.ToUpper(in)), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	for body, want := range map[string]struct {
+		code int
+		data string
+	}{
+		"hello": {http.StatusOK, "HELLO"},
+		"fail":  {http.StatusInternalServerError, "function failed"},
+	} {
+		resp, err := http.Post(srv.URL+"/open", "text/plain", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, want.code, resp.StatusCode)
+		assert.Equal(t, want.data, string(data))
+	}
+}
+
+func TestHTTPOpenFunctionStatusAndHeaders(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/open-status"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		return ctx.ReturnOnSuccess().
+			WithCode(http.StatusAccepted).
+			WithMetadata(map[string]string{"Location": "/jobs/1", "X-Job-Id": "1"}).
+			WithData([]byte("accepted")), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/open-status", "text/plain", bytes.NewBufferString("hello"))
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "/jobs/1", resp.Header.Get("Location"))
+	assert.Equal(t, "1", resp.Header.Get("X-Job-Id"))
+	assert.Equal(t, "accepted", string(data))
+}
+
+func TestHTTPFunctionResponseHeader(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/response-header"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		ctx.SetResponseHeader("X-Greeting", "hello")
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/response-header", "text/plain", bytes.NewBufferString("hello"))
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", resp.Header.Get("X-Greeting"))
+}
+
+func TestHTTPOpenFunctionMultipart(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/upload"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		form, err := ctx.ParseMultipart(1 << 20)
+		if err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		f, err := form.File["file"][0].Open()
+		if err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		return ctx.ReturnOnSuccess().WithData([]byte(form.Value["name"][0] + ":" + string(data))), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "alice")
+	fw, _ := mw.CreateFormFile("file", "hello.txt")
+	fw.Write([]byte("hello"))
+	mw.Close()
+
+	resp, err := http.Post(srv.URL+"/upload", mw.FormDataContentType(), body)
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "alice:hello", string(data))
+}
+
+func TestHTTPFunctionTLS(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18443",
+  "runtime": "Knative",
+  "httpPattern": "/tls"
+}`
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, pool := writeSelfSignedCert(t, dir, "server")
+	os.Setenv(knative.TLSCertFileEnvName, certFile)
+	os.Setenv(knative.TLSKeyFileEnvName, keyFile)
+	defer os.Unsetenv(knative.TLSCertFileEnvName)
+	defer os.Unsetenv(knative.TLSKeyFileEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("https://127.0.0.1:18443/tls"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to do https request: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	t.Run("missing certificate file", func(t *testing.T) {
+		os.Setenv(knative.TLSCertFileEnvName, filepath.Join(dir, "absent.crt"))
+		fwk, err := createFramework(env)
+		if err != nil {
+			t.Fatalf("failed to create framework: %v", err)
+		}
+		assert.Error(t, fwk.Start(context.Background()))
+	})
+}
+
+func TestHTTPFunctionReadTimeout(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18088",
+  "runtime": "Knative",
+  "httpPattern": "/timeout"
+}`
+	os.Setenv(knative.ReadTimeoutEnvName, "200ms")
+	defer os.Unsetenv(knative.ReadTimeoutEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", "127.0.0.1:18088"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// the client never completes the headers of the request
+	if _, err := conn.Write([]byte("GET /timeout HTTP/1.1\r\nHost: 127.0.0.1\r\n")); err != nil {
+		t.Fatalf("failed to write the request: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	start := time.Now()
+	_, err = ioutil.ReadAll(conn)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("the slow client is not timed out by the server")
+	}
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestHTTPFunctionH2C(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18089",
+  "runtime": "Knative",
+  "httpPattern": "/h2c"
+}`
+	os.Setenv(knative.EnableH2CEnvName, "true")
+	defer os.Unsetenv(knative.EnableH2CEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	// the prior knowledge client speaks http/2 over the plaintext connection
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("http://127.0.0.1:18089/h2c"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to do h2c request: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "HTTP/2.0", string(data))
+
+	// the http/1 requests are still served
+	resp, err = http.Get("http://127.0.0.1:18089/h2c")
+	if err != nil {
+		t.Fatalf("failed to do http request: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, 1, resp.ProtoMajor)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWarmUp(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18090",
+  "runtime": "Knative",
+  "httpPattern": "/warmup"
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var warmed int32
+	fwk.WarmUp(func(ctx ofctx.Context) error {
+		// the port is not bound until the warm-up is done
+		if conn, err := net.Dial("tcp", "127.0.0.1:18090"); err == nil {
+			conn.Close()
+			return errors.New("the function is served before the warm-up")
+		}
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&warmed, 1)
+		return nil
+	})
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(int(atomic.LoadInt32(&warmed)))))
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://127.0.0.1:18090/warmup"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to do http request: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "1", string(data))
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	t.Run("failed warm-up", func(t *testing.T) {
+		fwk, err := createFramework(env)
+		if err != nil {
+			t.Fatalf("failed to create framework: %v", err)
+		}
+		fwk.WarmUp(func(ctx ofctx.Context) error {
+			return errors.New("model not found")
+		})
+		assert.Error(t, fwk.Start(context.Background()))
+	})
+}
+
+func TestApplicationMetrics(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18092",
+  "runtime": "Knative",
+  "httpPattern": "/orders"
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		ctx.Counter("orders_total", map[string]string{"kind": "book"}).Inc()
+		ctx.Summary("order_amount", nil).Observe(2.5)
+		// the invalid metrics are discarded
+		ctx.Counter("orders-total", nil).Inc()
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Post("http://127.0.0.1:18092/orders", "application/json", strings.NewReader("{}")); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to do http request: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get("http://127.0.0.1:18092/metrics")
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(data), "# TYPE orders_total counter\norders_total{kind=\"book\"} 1\n")
+	assert.Contains(t, string(data), "order_amount_sum 2.5\norder_amount_count 1\n")
+	assert.NotContains(t, string(data), "orders-total")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestHTTPFunctionDrain(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18091",
+  "runtime": "Knative",
+  "httpPattern": "/drain"
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	entered := make(chan struct{}, 1)
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			entered <- struct{}{}
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Write([]byte("done"))
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://127.0.0.1:18091/drain"); err == nil {
+			resp.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to do http request: %v", err)
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:18091/drain?slow=1")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		slow <- result{body: string(data), err: err}
+	}()
+	<-entered
+
+	// the long invocation completes while the runtime drains
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("runtime stopped before the in-flight request completed: %v", err)
+	case r := <-slow:
+		assert.NoError(t, r.err)
+		assert.Equal(t, "done", r.body)
+	}
+	assert.NoError(t, <-done)
+}
+
+func TestHTTPFunctionClientCert(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18444",
+  "runtime": "Knative",
+  "httpPattern": "/mtls"
+}`
+	dir, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, pool := writeSelfSignedCert(t, dir, "server")
+	clientCert, clientKey, _ := writeSelfSignedCert(t, dir, "client")
+	untrustedCert, untrustedKey, _ := writeSelfSignedCert(t, dir, "untrusted")
+	os.Setenv(knative.TLSCertFileEnvName, certFile)
+	os.Setenv(knative.TLSKeyFileEnvName, keyFile)
+	os.Setenv(knative.ClientCAFileEnvName, clientCert)
+	defer os.Unsetenv(knative.TLSCertFileEnvName)
+	defer os.Unsetenv(knative.TLSKeyFileEnvName)
+	defer os.Unsetenv(knative.ClientCAFileEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	newClient := func(cert, key string) *http.Client {
+		config := &tls.Config{RootCAs: pool}
+		if cert != "" {
+			pair, err := tls.LoadX509KeyPair(cert, key)
+			if err != nil {
+				t.Fatalf("failed to load client certificate: %v", err)
+			}
+			config.Certificates = []tls.Certificate{pair}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	}
+
+	for name, tc := range map[string]struct {
+		client *http.Client
+		want   int
+	}{
+		"trusted client certificate":   {newClient(clientCert, clientKey), http.StatusOK},
+		"untrusted client certificate": {newClient(untrustedCert, untrustedKey), http.StatusUnauthorized},
+		"no client certificate":        {newClient("", ""), http.StatusUnauthorized},
+	} {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = tc.client.Get("https://127.0.0.1:18444/mtls"); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to do https request: %v", name, err)
+		}
+		resp.Body.Close()
+		assert.Equalf(t, tc.want, resp.StatusCode, name)
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its key into dir,
+// and returns their paths along with a pool trusting the certificate.
+func writeSelfSignedCert(t *testing.T, dir string, name string) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	return certFile, keyFile, pool
+}
+
+func TestInterceptors(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/intercepted"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	upper := func(next HandlerFunc) HandlerFunc {
+		return func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+			out, err := next(ctx, in)
+			if err == nil {
+				out.GetOut().WithData(bytes.ToUpper(out.GetData()))
+			}
+			return out, err
+		}
+	}
+	exclaim := func(next HandlerFunc) HandlerFunc {
+		return func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+			return next(ctx, append(in, '!'))
+		}
+	}
+	fwk.Use(upper, exclaim)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/intercepted", "text/plain", bytes.NewBufferString("hello"))
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HELLO!", string(data))
+}
+
+func TestHTTPFunctionRequestID(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/requestid"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		return ctx.ReturnOnSuccess().WithData([]byte(ctx.GetRequestID())), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	do := func(id string) (string, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/requestid", nil)
+		if err != nil {
+			t.Fatalf("error creating HTTP request for test: %v", err)
+		}
+		if id != "" {
+			req.Header.Set(knative.RequestIDHeader, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.Header.Get(knative.RequestIDHeader), string(data)
+	}
+
+	t.Run("request id is generated when absent", func(t *testing.T) {
+		header, data := do("")
+		assert.NotEmpty(t, header)
+		assert.Equal(t, header, data)
+	})
+
+	t.Run("request id is passed through when present", func(t *testing.T) {
+		header, data := do("request-1")
+		assert.Equal(t, "request-1", header)
+		assert.Equal(t, "request-1", data)
+	})
+
+	t.Run("error response carries the id of its own request", func(t *testing.T) {
+		slowEntered := make(chan struct{})
+		fastDone := make(chan struct{})
+		crash := func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(knative.RequestIDHeader) == "slow" {
+				close(slowEntered)
+				// another request is served before this one fails
+				<-fastDone
+				panic("slow request failed")
+			}
+			<-slowEntered
+		}
+		if err := fwk.RegisterWithPattern(context.Background(), "/requestid-crash", crash); err != nil {
+			t.Fatalf("failed to register HTTP function: %v\n", err)
+		}
+
+		slow := make(chan []byte)
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/requestid-crash", nil)
+			req.Header.Set(knative.RequestIDHeader, "slow")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("failed to do client.Do: %v", err)
+				slow <- nil
+				return
+			}
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			slow <- data
+		}()
+
+		<-slowEntered
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/requestid-crash", nil)
+		req.Header.Set(knative.RequestIDHeader, "fast")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+		close(fastDone)
+
+		var body struct {
+			RequestID string `json:"requestId"`
+		}
+		if err := json.Unmarshal(<-slow, &body); err != nil {
+			t.Fatalf("failed to decode error response: %v", err)
+		}
+		assert.Equal(t, "slow", body.RequestID)
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestHTTPStreamFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/stream"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in io.Reader) (ofctx.Out, error) {
+		if _, buffered := in.(*bytes.Reader); buffered {
+			return ctx.ReturnOnInternalError(), fmt.Errorf("request body is buffered")
+		}
+		n, err := io.Copy(ioutil.Discard, in)
+		if err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess().WithData([]byte(fmt.Sprint(n))), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register stream function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	size := int64(64 << 20)
+	resp, err := http.Post(srv.URL+"/stream", "application/octet-stream", io.LimitReader(zeroReader{}, size))
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fmt.Sprint(size), string(data))
+}
+
+func TestAsyncBindingsStreamFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "files": {
+      "uri": "files",
+      "componentName": "files",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in io.Reader) (ofctx.Out, error) {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess().WithData(bytes.ToUpper(data)), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register stream function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	in := &runtime.BindingEventRequest{Name: "files", Data: []byte("hello there")}
+	out, err := s.OnBindingEvent(ctx, in)
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO THERE", string(out.Data))
+
+	stopTestServer(t, s)
+}
+
+// closeTracker records if the reader of the function output is closed
+type closeTracker struct {
+	io.Reader
+	closed int32
+}
+
+func (c *closeTracker) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func TestHTTPOpenFunctionReader(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/reader"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	// the second chunk is only written once the client has received the first one,
+	// so the function blocks unless the output is streamed
+	received := make(chan struct{})
+	var tracker *closeTracker
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.Write([]byte("first\n"))
+			select {
+			case <-received:
+				pw.Write([]byte("second\n"))
+				pw.Close()
+			case <-time.After(time.Second):
+				pw.CloseWithError(errors.New("the output is buffered"))
+			}
+		}()
+		tracker = &closeTracker{Reader: pr}
+		return ctx.ReturnOnSuccess().WithReader(tracker), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/reader")
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	first := make([]byte, len("first\n"))
+	if _, err := io.ReadFull(resp.Body, first); err != nil {
+		t.Fatalf("failed to read the first chunk: %v", err)
+	}
+	close(received)
+	rest, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(first)+string(rest))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tracker.closed))
+}
+
+func TestAsyncBindingsReaderFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50026",
+  "inputs": {
+    "files": {
+      "uri": "files",
+      "componentName": "files",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var tracker *closeTracker
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		tracker = &closeTracker{Reader: bytes.NewReader(bytes.ToUpper(in))}
+		return ctx.ReturnOnSuccess().WithReader(tracker), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	in := &runtime.BindingEventRequest{Name: "files", Data: []byte("hello there")}
+	out, err := s.OnBindingEvent(ctx, in)
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO THERE", string(out.Data))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tracker.closed))
+
+	stopTestServer(t, s)
+}
+
+func TestHTTPOpenFunctionContentNegotiation(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/negotiated"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	type greeting struct {
+		XMLName struct{} `json:"-" xml:"greeting"`
+		Message string   `json:"message" xml:"message"`
+	}
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		return ctx.ReturnOnSuccess().WithResult(greeting{Message: "hello"}), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	for accept, want := range map[string][2]string{
+		"application/json": {"application/json", `{"message":"hello"}`},
+		"application/xml":  {"application/xml", `<greeting><message>hello</message></greeting>`},
+		"text/csv":         {"application/json", `{"message":"hello"}`},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/negotiated", nil)
+		if err != nil {
+			t.Fatalf("error creating HTTP request for test: %v", err)
+		}
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want[0], resp.Header.Get("Content-Type"))
+		assert.Equal(t, want[1], string(data))
+	}
+}
+
+func TestHTTPFunctionCache(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/cached",
+  "httpCacheTTL": "200ms"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var calls int32
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte(r.URL.Query().Get("q")), body...))
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	do := func(method, path, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+
+	resp, body := do(http.MethodGet, "/cached?q=a", "")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "a", body)
+	assert.Equal(t, "miss", resp.Header.Get(knative.CacheStatusHeader))
+
+	// the cache hit skips the handler and returns the stored response
+	resp, body = do(http.MethodGet, "/cached?q=a", "")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "a", body)
+	assert.Equal(t, "1", resp.Header.Get("X-Call"))
+	assert.Equal(t, "hit", resp.Header.Get(knative.CacheStatusHeader))
+	assert.NotEmpty(t, resp.Header.Get(knative.RequestIDHeader))
+
+	// the method, the url and the body are all part of the key
+	_, body = do(http.MethodGet, "/cached?q=b", "")
+	assert.Equal(t, "b", body)
+	_, body = do(http.MethodPost, "/cached?q=a", "x")
+	assert.Equal(t, "ax", body)
+	_, body = do(http.MethodPost, "/cached?q=a", "y")
+	assert.Equal(t, "ay", body)
+	_, body = do(http.MethodPost, "/cached?q=a", "x")
+	assert.Equal(t, "ax", body)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// the failed responses are not cached
+	do(http.MethodGet, "/cached?fail=1", "")
+	resp, _ = do(http.MethodGet, "/cached?fail=1", "")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+
+	// the response expires after the TTL
+	time.Sleep(250 * time.Millisecond)
+	resp, body = do(http.MethodGet, "/cached?q=a", "")
+	assert.Equal(t, "a", body)
+	assert.Equal(t, "miss", resp.Header.Get(knative.CacheStatusHeader))
+	assert.Equal(t, int32(7), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPFunctionCORS(t *testing.T) {
+	os.Setenv(knative.CORSAllowOriginsEnvName, "https://app.example.com, https://admin.example.com")
+	os.Setenv(knative.CORSExposeHeadersEnvName, "X-Total")
+	os.Setenv(knative.CORSMaxAgeEnvName, "600")
+	defer os.Unsetenv(knative.CORSAllowOriginsEnvName)
+	defer os.Unsetenv(knative.CORSExposeHeadersEnvName)
+	defer os.Unsetenv(knative.CORSMaxAgeEnvName)
+
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/cors",
+  "httpMethods": ["GET", "POST"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var calls int32
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Total", "1")
+		w.Write([]byte("hello"))
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	do := func(method, origin string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+"/cors", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		resp := do(http.MethodOptions, "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "Content-Type",
+		})
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST", resp.Header.Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type", resp.Header.Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("preflight of method not allowed", func(t *testing.T) {
+		resp := do(http.MethodOptions, "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method": "DELETE",
+		})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("preflight from denied origin", func(t *testing.T) {
+		resp := do(http.MethodOptions, "https://evil.example.com", map[string]string{
+			"Access-Control-Request-Method": "GET",
+		})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("request from allowed origin", func(t *testing.T) {
+		resp := do(http.MethodGet, "https://admin.example.com", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "https://admin.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "X-Total", resp.Header.Get("Access-Control-Expose-Headers"))
+		assert.Contains(t, resp.Header.Values("Vary"), "Origin")
+	})
+
+	t.Run("request from denied origin", func(t *testing.T) {
+		resp := do(http.MethodGet, "https://evil.example.com", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("request without origin", func(t *testing.T) {
+		resp := do(http.MethodGet, "", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, resp.Header.Get("Vary"))
+	})
+
+	t.Run("options without preflight", func(t *testing.T) {
+		resp := do(http.MethodOptions, "https://app.example.com", nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPFunctionMaxInflight(t *testing.T) {
+	os.Setenv(knative.MaxInflightEnvName, "2")
+	defer os.Unsetenv(knative.MaxInflightEnvName)
+
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/inflight"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	get := func() int {
+		resp, err := http.Get(srv.URL + "/inflight")
+		if err != nil {
+			t.Errorf("failed to do client.Do: %v", err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			codes <- get()
+		}()
+		<-entered
+	}
+
+	// the limit is reached while both requests are in flight
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusServiceUnavailable, get())
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-codes)
+	assert.Equal(t, http.StatusOK, <-codes)
+
+	go func() {
+		<-entered
+	}()
+	assert.Equal(t, http.StatusOK, get())
+}
+
+func TestHTTPFunctionRateLimit(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ratelimit",
+  "prePlugins": ["plugin-ratelimit"],
+  "pluginsConfig": {
+    "plugin-ratelimit": {"rate": 0.001, "burst": 1}
+  }
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := http.Get(srv.URL + "/ratelimit")
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, want, resp.StatusCode)
+	}
+}
+
+func TestPluginsHookTimeout(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/slow",
+  "prePlugins": ["plugin-slow"],
+  "postPlugins": ["plugin-slow"],
+  "pluginsHookTimeout": "50ms"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	slow := &slowPlugin{delay: 2 * time.Second, returned: make(chan struct{}, 2)}
+	fwk.RegisterPlugins(map[string]plugin.Plugin{
+		"plugin-slow": slow,
+	})
+
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("TestPluginsHookTimeout: slow plugin was not abandoned, request took %v", elapsed)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll: %v", err)
+	}
+
+	if got, want := string(body), "Hello World!"; got != want {
+		t.Fatalf("TestPluginsHookTimeout: got %v; want %v", got, want)
+	}
+	// the abandoned hooks log once they return, which must not outlive the test
+	<-slow.returned
+	<-slow.returned
+}
+
+// deadlinePlugin waits in its pre-hook until the context of the hook is done
+type deadlinePlugin struct {
+	done chan error
+}
+
+func (p *deadlinePlugin) Name() string {
+	return "plugin-deadline"
+}
+
+func (p *deadlinePlugin) Version() string {
+	return "v1"
+}
+
+func (p *deadlinePlugin) Init() plugin.Plugin {
+	return p
+}
+
+func (p *deadlinePlugin) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	c := ctx.GetHookContext()
+	select {
+	case <-c.Done():
+		p.done <- c.Err()
+	case <-time.After(2 * time.Second):
+		p.done <- nil
+	}
+	return nil
+}
+
+func (p *deadlinePlugin) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	return nil
+}
+
+func (p *deadlinePlugin) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+func TestPluginHookTimeouts(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/deadline",
+  "prePlugins": ["plugin-deadline"],
+  "pluginsHookTimeout": "10s",
+  "pluginHookTimeouts": {"plugin-deadline": "50ms"}
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	p := &deadlinePlugin{done: make(chan error, 1)}
+	fwk.RegisterPlugins(map[string]plugin.Plugin{
+		"plugin-deadline": p,
+	})
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/deadline")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("TestPluginHookTimeouts: plugin was not abandoned after its own timeout, request took %v", elapsed)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the hook is told that its deadline is exceeded through the context of the hook
+	select {
+	case err := <-p.done:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("TestPluginHookTimeouts: hook was not told about its deadline")
+	}
+}
+
+func TestCloudEventFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ce"
+}`
+	var ceDemo = struct {
+		message map[string]string
+		headers map[string]string
+	}{
+		message: map[string]string{
+			"msg": "Hello World!",
+		},
+		headers: map[string]string{
+			"Ce-Specversion": "1.0",
+			"Ce-Type":        "cloudevents.openfunction.samples.helloworld",
+			"Ce-Source":      "cloudevents.openfunction.samples/helloworldsource",
+			"Ce-Id":          "536808d3-88be-4077-9d7a-a3f162705f79",
+		},
+	}
+
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeCloudEventsFunction); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	if fwk.GetRuntime() == nil {
+		t.Fatal("failed to create runtime")
+	}
+
+	handler := fwk.GetRuntime().GetHandler()
+	if handler == nil {
+		t.Fatal("handler is nil")
+	}
+
+	srv := httptest.NewServer(handler.(http.Handler))
+	defer srv.Close()
+
+	messageByte, err := json.Marshal(ceDemo.message)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", srv.URL+"/ce", bytes.NewBuffer(messageByte))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ceDemo.headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to test cloudevents function: response status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestCloudEventFunctionNativeContext(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ce-context"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	received := make(chan context.Context, 1)
+	fn := func(ctx context.Context, ce cloudevents.Event) error {
+		received <- ctx
+		return nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/ce-context", bytes.NewBufferString(`{"msg":"Hello World!"}`))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Type", "cloudevents.openfunction.samples.helloworld")
+	req.Header.Set("Ce-Source", "cloudevents.openfunction.samples/helloworldsource")
+	req.Header.Set("Ce-Id", "536808d3")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case fnCtx := <-received:
+		if fnCtx == nil {
+			t.Fatal("failed to test cloudevents function: native context is nil")
+		}
+		if fnCtx.Done() == nil {
+			t.Fatal("failed to test cloudevents function: native context is not cancelable")
+		}
+		select {
+		case <-fnCtx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("failed to test cloudevents function: native context not tied to the request")
+		}
+	default:
+		t.Fatal("failed to test cloudevents function: event not received")
+	}
+}
+
+func TestCloudEventFunctionStructuredMode(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ce-structured"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	received := make(chan cloudevents.Event, 1)
+	fn := func(ctx context.Context, ce cloudevents.Event) error {
+		received <- ce
+		return nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	envelope := `{
+  "specversion": "1.0",
+  "type": "cloudevents.openfunction.samples.structured",
+  "source": "cloudevents.openfunction.samples/structuredsource",
+  "id": "4d5f2c8e-5a1b-4f0e-9b7d-6e1a3c2b1a00",
+  "subject": "sample",
+  "datacontenttype": "application/json",
+  "data": {"msg": "Hello World!"}
+}`
+	req, err := http.NewRequest("POST", srv.URL+"/ce-structured", bytes.NewBufferString(envelope))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to test cloudevents function: response status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	select {
+	case ce := <-received:
+		assert.Equal(t, "1.0", ce.SpecVersion())
+		assert.Equal(t, "cloudevents.openfunction.samples.structured", ce.Type())
+		assert.Equal(t, "cloudevents.openfunction.samples/structuredsource", ce.Source())
+		assert.Equal(t, "4d5f2c8e-5a1b-4f0e-9b7d-6e1a3c2b1a00", ce.ID())
+		assert.Equal(t, "sample", ce.Subject())
+		assert.Equal(t, cloudevents.ApplicationJSON, ce.DataContentType())
+		assert.JSONEq(t, `{"msg":"Hello World!"}`, string(ce.Data()))
+	default:
+		t.Fatal("failed to test cloudevents function: event not received")
+	}
+}
+
+func TestCloudEventFunctionProtobuf(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ce-protobuf"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	received := make(chan string, 1)
+	fn := func(ctx context.Context, ce cloudevents.Event) error {
+		var msg wrappers.StringValue
+		if err := ofctx.UnmarshalProtoData(ce, &msg); err != nil {
+			return err
+		}
+		received <- msg.GetValue()
+		return nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	data, err := proto.Marshal(&wrappers.StringValue{Value: "Hello World!"})
+	if err != nil {
+		t.Fatalf("failed to marshal proto message: %v", err)
+	}
+
+	binary, err := http.NewRequest("POST", srv.URL+"/ce-protobuf", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	binary.Header.Set("Content-Type", ofctx.ProtobufContentType)
+	binary.Header.Set("Ce-Specversion", "1.0")
+	binary.Header.Set("Ce-Type", "cloudevents.openfunction.samples.protobuf")
+	binary.Header.Set("Ce-Source", "cloudevents.openfunction.samples/protobufsource")
+	binary.Header.Set("Ce-Id", "536808d3-88be-4077-9d7a-a3f162705f79")
+
+	structured, err := http.NewRequest("POST", srv.URL+"/ce-protobuf", bytes.NewBufferString(fmt.Sprintf(`{
+  "specversion": "1.0",
+  "type": "cloudevents.openfunction.samples.protobuf",
+  "source": "cloudevents.openfunction.samples/protobufsource",
+  "id": "4d5f2c8e-5a1b-4f0e-9b7d-6e1a3c2b1a01",
+  "datacontenttype": "application/protobuf",
+  "data_base64": %q
+}`, base64.StdEncoding.EncodeToString(data))))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	structured.Header.Set("Content-Type", "application/cloudevents+json")
+
+	for mode, req := range map[string]*http.Request{"binary": binary, "structured": structured} {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to do client.Do: %v", err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, mode)
+
+		select {
+		case value := <-received:
+			assert.Equal(t, "Hello World!", value, mode)
+		default:
+			t.Fatalf("failed to test cloudevents function in %s mode: event not received", mode)
+		}
+	}
+
+	// the data of the other content types is not unmarshalled as protobuf
+	ce := cloudevents.NewEvent()
+	if err := ce.SetData(cloudevents.ApplicationJSON, map[string]string{"msg": "Hello World!"}); err != nil {
+		t.Fatalf("failed to set cloudevent data: %v", err)
+	}
+	assert.Error(t, ofctx.UnmarshalProtoData(ce, &wrappers.StringValue{}))
+}
+
+func TestCloudEventResponseFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/ce-response"
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx context.Context, ce cloudevents.Event) (*cloudevents.Event, error) {
+		resp := cloudevents.NewEvent()
+		resp.SetID("response-" + ce.ID())
+		resp.SetType("cloudevents.openfunction.samples.response")
+		resp.SetSource("function-demo")
+		if err := resp.SetData(cloudevents.ApplicationJSON, map[string]string{"msg": "Hello Back!"}); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/ce-response", bytes.NewBufferString(`{"msg":"Hello World!"}`))
+	if err != nil {
+		t.Fatalf("error creating HTTP request for test: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Type", "cloudevents.openfunction.samples.helloworld")
+	req.Header.Set("Ce-Source", "cloudevents.openfunction.samples/helloworldsource")
+	req.Header.Set("Ce-Id", "536808d3")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to test cloudevents response function: response status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	assert.Equal(t, "response-536808d3", resp.Header.Get("Ce-Id"))
+	assert.Equal(t, "cloudevents.openfunction.samples.response", resp.Header.Get("Ce-Type"))
+	assert.Equal(t, "function-demo", resp.Header.Get("Ce-Source"))
+	assert.Equal(t, "1.0", resp.Header.Get("Ce-Specversion"))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll: %v", err)
+	}
+	assert.JSONEq(t, `{"msg":"Hello Back!"}`, string(body))
+}
+
+func TestAsyncBindingsFunction(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "requestID": "a0f2ad8d-5062-4812-91e9-95416489fb01",
+  "port": "50003",
+  "inputs": {
+    "cron": {
+      "uri": "test",
+      "componentName": "test",
+      "componentType": "bindings.Kafka"
+    }
+  }
+}`
+	methodName := "test"
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	if fwk.GetRuntime() == nil {
+		t.Fatal("failed to create runtime")
+	}
+
+	server := fwk.GetRuntime().GetHandler()
+	if server == nil {
+		t.Fatal("server is nil")
+	}
+	s := server.(*async.FakeServer)
+	startTestServer(s)
+
+	t.Run("binding without event", func(t *testing.T) {
+		_, err := s.OnBindingEvent(ctx, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("binding event for wrong method", func(t *testing.T) {
+		in := &runtime.BindingEventRequest{Name: "invalid"}
+		_, err := s.OnBindingEvent(ctx, in)
+		assert.Error(t, err)
+	})
+
+	t.Run("binding event without data", func(t *testing.T) {
+		in := &runtime.BindingEventRequest{Name: methodName}
+		out, err := s.OnBindingEvent(ctx, in)
+		assert.NoError(t, err)
+		assert.NotNil(t, out)
+	})
+
+	t.Run("binding event with data", func(t *testing.T) {
+		data := "hello there"
+		in := &runtime.BindingEventRequest{
+			Name: methodName,
+			Data: []byte(data),
+		}
+		out, err := s.OnBindingEvent(ctx, in)
+		assert.NoError(t, err)
+		assert.NotNil(t, out)
+		assert.Equal(t, data, string(out.Data))
+	})
+
+	t.Run("binding event with metadata", func(t *testing.T) {
+		in := &runtime.BindingEventRequest{
+			Name:     methodName,
+			Metadata: map[string]string{"k1": "v1", "k2": "v2"},
+		}
+		out, err := s.OnBindingEvent(ctx, in)
+		assert.NoError(t, err)
+		assert.NotNil(t, out)
+	})
+
+	stopTestServer(t, s)
+
+}
+
+func TestAsyncPubsubTopic(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "requestID": "a0f2ad8d-5062-4812-91e9-95416489fb01",
+  "port": "50003",
+  "inputs": {
+    "sub": {
+      "uri": "my_topic",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`
+
+	sub := &common.Subscription{
+		PubsubName: "msg",
+		Topic:      "my_topic",
+	}
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
+		t.Fatalf("failed to register CloudEvents function: %v", err)
+	}
+
+	if fwk.GetRuntime() == nil {
+		t.Fatal("failed to create runtime")
+	}
+
+	server := fwk.GetRuntime().GetHandler()
+	if server == nil {
+		t.Fatal("server is nil")
+	}
+	s := server.(*async.FakeServer)
+	startTestServer(s)
+
+	t.Run("topic event without request", func(t *testing.T) {
+		_, err := s.OnTopicEvent(ctx, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("topic event for wrong topic", func(t *testing.T) {
+		in := &runtime.TopicEventRequest{
+			Topic: "invalid",
+		}
+		_, err := s.OnTopicEvent(ctx, in)
+		assert.Error(t, err)
+	})
+
+	t.Run("topic event for valid topic", func(t *testing.T) {
+		in := &runtime.TopicEventRequest{
+			Id:              "a123",
+			Source:          "test",
+			Type:            "test",
+			SpecVersion:     "v1.0",
+			DataContentType: "text/plain",
+			Data:            []byte("test"),
+			Topic:           sub.Topic,
+			PubsubName:      sub.PubsubName,
+		}
+		_, err := s.OnTopicEvent(ctx, in)
+		assert.NoError(t, err)
+	})
+
+	stopTestServer(t, s)
+}
+
+// routeSender records the payloads published to each topic
+type routeSender struct {
+	mu     sync.Mutex
+	topics map[string][]string
+	err    error
+}
+
+func (s *routeSender) SendOutput(c context.Context, output *ofctx.Output, data []byte) (*ofctx.BindingResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.topics[output.ComponentName+"/"+output.Uri] = append(s.topics[output.ComponentName+"/"+output.Uri], string(data))
+	return nil, nil
+}
+
+func TestAsyncPubsubRouteError(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50025",
+  "inputs": {
+    "sub": {
+      "uri": "orders",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`
+	sender := &routeSender{topics: map[string][]string{}}
+	ctx := context.Background()
+	fwk, err := createFramework(env, ofctx.WithOutputSender("pubsub.kafka", sender))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	routeFunction := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		switch string(in) {
+		case "malformed":
+			return ctx.ReturnOnInternalError(), &ofctx.RouteError{Topic: "orders-dead", Err: errors.New("malformed order")}
+		case "unavailable":
+			return ctx.ReturnOnInternalError(), &ofctx.RouteError{Topic: "orders-retry", Err: errors.New("stock unavailable")}
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, routeFunction); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+	defer stopTestServer(t, s)
+
+	publish := func(id, data string) (*runtime.TopicEventResponse, error) {
+		return s.OnTopicEvent(ctx, &runtime.TopicEventRequest{
+			Id:              id,
+			Source:          "test",
+			Type:            "test",
+			SpecVersion:     "v1.0",
+			DataContentType: "text/plain",
+			Data:            []byte(data),
+			Topic:           "orders",
+			PubsubName:      "msg",
+		})
+	}
+
+	for i, data := range []string{"malformed", "unavailable", "ok", "unavailable"} {
+		resp, err := publish(strconv.Itoa(i), data)
+		assert.NoError(t, err)
+		assert.Equal(t, runtime.TopicEventResponse_SUCCESS, resp.Status)
+	}
+	assert.Equal(t, map[string][]string{
+		"msg/orders-dead":  {"malformed"},
+		"msg/orders-retry": {"unavailable", "unavailable"},
+	}, sender.topics)
+
+	// the event is redelivered if it cannot be routed
+	sender.err = errors.New("broker unavailable")
+	resp, err := publish("4", "malformed")
+	assert.Error(t, err)
+	assert.Equal(t, runtime.TopicEventResponse_RETRY, resp.Status)
+}
+
+// requeueSender records the outputs sent with their metadata
+type requeueSender struct {
+	mu      sync.Mutex
+	outputs []*ofctx.Output
+	data    []string
+}
+
+func (s *requeueSender) SendOutput(c context.Context, output *ofctx.Output, data []byte) (*ofctx.BindingResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs = append(s.outputs, output)
+	s.data = append(s.data, string(data))
+	return nil, nil
+}
+
+func TestAsyncRequeueWithDelay(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50032",
+  "inputs": {
+    "sub": {
+      "uri": "orders",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`
+	sender := &requeueSender{}
+	ctx := context.Background()
+	fwk, err := createFramework(env, ofctx.WithOutputSender("pubsub.kafka", sender))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	requeueFunction := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if string(in) == "not ready" {
+			if err := ctx.RequeueWithDelay(time.Minute); err != nil {
+				return ctx.ReturnOnInternalError(), err
+			}
+			return ctx.ReturnOnInternalError(), errors.New("order not ready")
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, requeueFunction); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+	defer stopTestServer(t, s)
+
+	publish := func(id, data string) (*runtime.TopicEventResponse, error) {
+		return s.OnTopicEvent(ctx, &runtime.TopicEventRequest{
+			Id:              id,
+			Source:          "test",
+			Type:            "test",
+			SpecVersion:     "v1.0",
+			DataContentType: "text/plain",
+			Data:            []byte(data),
+			Topic:           "orders",
+			PubsubName:      "msg",
+		})
+	}
+
+	// the requeued event is acked even though the function failed
+	resp, err := publish("1", "not ready")
+	assert.NoError(t, err)
+	assert.Equal(t, runtime.TopicEventResponse_SUCCESS, resp.Status)
+
+	resp, err = publish("2", "ready")
+	assert.NoError(t, err)
+	assert.Equal(t, runtime.TopicEventResponse_SUCCESS, resp.Status)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	assert.Equal(t, []string{"not ready"}, sender.data)
+	output := sender.outputs[0]
+	assert.Equal(t, "msg", output.ComponentName)
+	assert.Equal(t, "orders", output.Uri)
+	deliverAt, err := time.Parse(http.TimeFormat, output.Metadata[ofctx.ScheduledDeliveryMetadataKey])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deliverAt, 5*time.Second)
+}
+
+func TestAsyncInputFilter(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50027",
+  "inputs": {
+    "events": {
+      "uri": "events",
+      "componentName": "events",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "filter": "kind!=heartbeat"
+      }
+    },
+    "orders": {
+      "uri": "orders",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka",
+      "metadata": {
+        "filter": "type=order.created, source=shop"
+      }
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var calls int32
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		atomic.AddInt32(&calls, 1)
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+	defer stopTestServer(t, s)
+
+	t.Run("binding events", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		out, err := s.OnBindingEvent(ctx, &runtime.BindingEventRequest{
+			Name:     "events",
+			Data:     []byte("ping"),
+			Metadata: map[string]string{"kind": "heartbeat"},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, out.Data)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+		out, err = s.OnBindingEvent(ctx, &runtime.BindingEventRequest{
+			Name:     "events",
+			Data:     []byte("hello"),
+			Metadata: map[string]string{"kind": "message"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(out.Data))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("topic events", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		for _, eventType := range []string{"order.deleted", "order.created"} {
+			resp, err := s.OnTopicEvent(ctx, &runtime.TopicEventRequest{
+				Id:              eventType,
+				Source:          "shop",
+				Type:            eventType,
+				SpecVersion:     "v1.0",
+				DataContentType: "text/plain",
+				Data:            []byte("order"),
+				Topic:           "orders",
+				PubsubName:      "msg",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, runtime.TopicEventResponse_SUCCESS, resp.Status)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		env := `{
+  "name": "function-demo",
+  "runtime": "Async",
+  "port": "50027",
+  "inputs": {
+    "events": {
+      "uri": "events",
+      "componentName": "events",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "filter": "heartbeat"
+      }
+    }
+  }
+}`
+		_, err := createFramework(env)
+		assert.Error(t, err)
+	})
+}
+
+func TestAsyncPubsubOrdered(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "sub": {
+      "uri": "ordered_topic",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka",
+      "metadata": {
+        "ordered": "true",
+        "orderingKey": "account"
+      }
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	type message struct {
+		Account string `json:"account"`
+		Seq     int    `json:"seq"`
+	}
+	var mu sync.Mutex
+	var processed []message
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		var m message
+		if err := json.Unmarshal(in, &m); err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		if m.Account == "a" && m.Seq == 1 {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		processed = append(processed, m)
+		mu.Unlock()
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	publish := func(account string, seq int) {
+		data, _ := json.Marshal(message{Account: account, Seq: seq})
+		_, err := s.OnTopicEvent(ctx, &runtime.TopicEventRequest{
+			Id:              fmt.Sprintf("%s-%d", account, seq),
+			Source:          "test",
+			Type:            "test",
+			SpecVersion:     "v1.0",
+			DataContentType: "application/json",
+			Data:            data,
+			Topic:           "ordered_topic",
+			PubsubName:      "msg",
+		})
+		assert.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		publish("a", 1)
+	}()
+	<-started
+
+	// the messages with the same key wait for the first one in order
+	for seq := 2; seq <= 3; seq++ {
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			publish("a", seq)
+		}(seq)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// the messages with another key are not blocked
+	publish("b", 1)
+	mu.Lock()
+	assert.Equal(t, []message{{"b", 1}}, processed)
+	mu.Unlock()
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []message{{"b", 1}, {"a", 1}, {"a", 2}, {"a", 3}}, processed)
+
+	stopTestServer(t, s)
+}
+
+func TestAsyncCronBinding(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "ticker": {
+      "uri": "ticker",
+      "componentName": "ticker",
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 5s"
+      }
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	triggers := make(chan *ofctx.CronTrigger, 1)
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		triggers <- ctx.GetCronTrigger()
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	before := time.Now()
+	_, err = s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "ticker"})
+	assert.NoError(t, err)
+
+	trigger := <-triggers
+	if assert.NotNil(t, trigger) {
+		assert.Equal(t, "ticker", trigger.Name)
+		assert.Equal(t, "@every 5s", trigger.Schedule)
+		assert.False(t, trigger.Time.Before(before))
+	}
+
+	stopTestServer(t, s)
+}
+
+func TestCronInputSchedule(t *testing.T) {
+	_, err := createFramework(`{
+  "name": "function-demo",
+  "runtime": "Async",
+  "port": "50043",
+  "inputs": {
+    "ticker": {
+      "componentName": "ticker",
+      "componentType": "bindings.cron"
+    }
+  }
+}`)
+	assert.Error(t, err)
+}
+
+func TestAsyncBindingsRetry(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "cron": {
+      "uri": "cron_input",
+      "componentName": "cron_input",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "retryMaxAttempts": "3",
+        "retryBackoff": "10ms"
+      }
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var attempts, failures int32
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if atomic.AddInt32(&attempts, 1) <= atomic.LoadInt32(&failures) {
+			return ctx.ReturnOnInternalError(), fmt.Errorf("transient failure")
+		}
+		return ctx.ReturnOnSuccess().WithData([]byte("done")), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	// succeeds after retry
+	atomic.StoreInt32(&failures, 2)
+	out, err := s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "cron_input", Data: []byte("hello")})
+	assert.NoError(t, err)
+	if assert.NotNil(t, out) {
+		assert.Equal(t, []byte("done"), out.Data)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// gives up once the retries are exhausted
+	atomic.StoreInt32(&attempts, 0)
+	atomic.StoreInt32(&failures, 5)
+	_, err = s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "cron_input", Data: []byte("hello")})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	stopTestServer(t, s)
+}
+
+// memoryInputAdapter delivers the messages of the inputs from memory.
+type memoryInputAdapter struct {
+	mu       sync.Mutex
+	bindings map[string]common.BindingInvocationHandler
+	topics   map[string]common.TopicEventHandler
+	stop     chan struct{}
+}
+
+func newMemoryInputAdapter() *memoryInputAdapter {
+	return &memoryInputAdapter{
+		bindings: map[string]common.BindingInvocationHandler{},
+		topics:   map[string]common.TopicEventHandler{},
+		stop:     make(chan struct{}),
+	}
+}
+
+func (a *memoryInputAdapter) AddBindingInvocationHandler(name string, fn common.BindingInvocationHandler) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bindings[name] = fn
+	return nil
+}
+
+func (a *memoryInputAdapter) AddTopicEventHandler(sub *common.Subscription, fn common.TopicEventHandler) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.topics[sub.PubsubName+"/"+sub.Topic] = fn
+	return nil
+}
+
+func (a *memoryInputAdapter) AddServiceInvocationHandler(name string, fn common.ServiceInvocationHandler) error {
+	return fmt.Errorf("service invocation is not supported")
+}
+
+func (a *memoryInputAdapter) Start() error {
+	<-a.stop
+	return nil
+}
+
+func (a *memoryInputAdapter) Stop() error {
+	close(a.stop)
+	return nil
+}
+
+func (a *memoryInputAdapter) deliverBinding(name string, data []byte) ([]byte, error) {
+	a.mu.Lock()
+	fn, ok := a.bindings[name]
+	a.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("binding %s not subscribed", name)
+	}
+	return fn(context.Background(), &common.BindingEvent{Data: data})
+}
+
+func (a *memoryInputAdapter) deliverTopic(pubsubName, topic string, data []byte) (bool, error) {
+	a.mu.Lock()
+	fn, ok := a.topics[pubsubName+"/"+topic]
+	a.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("topic %s/%s not subscribed", pubsubName, topic)
+	}
+	return fn(context.Background(), &common.TopicEvent{ID: "1", PubsubName: pubsubName, Topic: topic, Data: string(data), RawData: data})
+}
+
+func TestAsyncInputAdapter(t *testing.T) {
+	adapter := newMemoryInputAdapter()
+	async.SetInputAdapterFactory(func(port string) (async.InputAdapter, error) {
+		return adapter, nil
+	})
+	defer async.SetInputAdapterFactory(nil)
+
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50030",
+  "inputs": {
+    "queue": {
+      "uri": "queue",
+      "componentName": "queue",
+      "componentType": "bindings.kafka"
+    },
+    "sub": {
+      "uri": "orders",
+      "componentName": "broker",
+      "componentType": "pubsub.nats"
+    }
+  }
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var received []string
+	var mu sync.Mutex
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		mu.Lock()
+		received = append(received, string(in))
+		mu.Unlock()
+		return ctx.ReturnOnSuccess().WithData(append([]byte("got "), in...)), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+	assert.Equal(t, adapter, fwk.GetRuntime().GetHandler())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	out, err := adapter.deliverBinding("queue", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("got hello"), out)
+
+	retry, err := adapter.deliverTopic("broker", "orders", []byte("order"))
+	assert.NoError(t, err)
+	assert.False(t, retry)
+
+	mu.Lock()
+	assert.Equal(t, []string{"hello", "order"}, received)
+	mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("input adapter not stopped")
+	}
+}
+
+func TestAsyncBindingsRetryDeadline(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "cron": {
+      "uri": "cron_input",
+      "componentName": "cron_input",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "retryMaxAttempts": "10",
+        "retryBackoff": "40ms",
+        "retryDeadline": "100ms"
+      }
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var attempts int32
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		atomic.AddInt32(&attempts, 1)
+		return ctx.ReturnOnInternalError(), fmt.Errorf("transient failure")
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	// the retry after 40ms fits in the deadline, the next one after another 80ms does not
+	_, err = s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "cron_input", Data: []byte("hello")})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	stopTestServer(t, s)
+}
+
+func TestBindingsRetryPolicy(t *testing.T) {
+	for _, metadata := range []string{
+		`{"retryMaxAttempts": "0"}`,
+		`{"retryMaxAttempts": "3", "retryBackoff": "soon"}`,
+		`{"retryMaxAttempts": "3", "retryDeadline": "-1s"}`,
+	} {
+		_, err := createFramework(fmt.Sprintf(`{
+  "name": "function-demo",
+  "runtime": "Async",
+  "port": "50043",
+  "inputs": {
+    "cron": {
+      "componentName": "cron_input",
+      "componentType": "bindings.kafka",
+      "metadata": %s
+    }
+  }
+}`, metadata))
+		assert.Error(t, err, metadata)
+	}
+}
+
+func TestAsyncStatusCodes(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "cron": {
+      "uri": "cron_input",
+      "componentName": "cron_input",
+      "componentType": "bindings.kafka"
+    },
+    "sub": {
+      "uri": "my_topic",
+      "componentName": "msg",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	// the function responds with the code sent as the event data
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		code, err := strconv.Atoi(string(in))
+		if err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess().WithCode(code).WithData([]byte(http.StatusText(code))), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	for _, tc := range []struct {
+		code        int
+		ack         bool
+		topicStatus runtime.TopicEventResponse_TopicEventResponseStatus
+	}{
+		{code: http.StatusOK, ack: true, topicStatus: runtime.TopicEventResponse_SUCCESS},
+		{code: http.StatusBadRequest, topicStatus: runtime.TopicEventResponse_DROP},
+		{code: http.StatusNotFound, topicStatus: runtime.TopicEventResponse_DROP},
+		{code: http.StatusInternalServerError, topicStatus: runtime.TopicEventResponse_DROP},
+		{code: http.StatusServiceUnavailable, topicStatus: runtime.TopicEventResponse_RETRY},
+	} {
+		tc := tc
+		t.Run(strconv.Itoa(tc.code), func(t *testing.T) {
+			data := []byte(strconv.Itoa(tc.code))
+
+			out, err := s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "cron_input", Data: data})
+			if tc.ack {
+				assert.NoError(t, err)
+				if assert.NotNil(t, out) {
+					assert.Equal(t, []byte(http.StatusText(tc.code)), out.Data)
+				}
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), http.StatusText(tc.code))
+			}
+
+			resp, err := s.OnTopicEvent(ctx, &runtime.TopicEventRequest{
+				Id:              "a123",
+				Source:          "test",
+				Type:            "test",
+				SpecVersion:     "v1.0",
+				DataContentType: "text/plain",
+				Data:            data,
+				Topic:           "my_topic",
+				PubsubName:      "msg",
+			})
+			assert.Equal(t, tc.ack, err == nil)
+			if assert.NotNil(t, resp) {
+				assert.Equal(t, tc.topicStatus, resp.Status)
+			}
+		})
+	}
+
+	stopTestServer(t, s)
+}
+
+func TestAsyncServiceInvocation(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "invoke": {
+      "uri": "echo",
+      "componentName": "echo",
+      "componentType": "service.invocation"
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if ctx.GetInvocationEvent() == nil {
+			return ctx.ReturnOnInternalError(), fmt.Errorf("invocation event not found")
+		}
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	t.Run("invocation for wrong method", func(t *testing.T) {
+		in := &commonv1.InvokeRequest{Method: "invalid"}
+		_, err := s.OnInvoke(ctx, in)
+		assert.Error(t, err)
+	})
+
+	t.Run("invocation with data", func(t *testing.T) {
+		in := &commonv1.InvokeRequest{
+			Method:      "echo",
+			ContentType: "text/plain",
+			Data:        &any.Any{Value: []byte("hello there")},
+		}
+		out, err := s.OnInvoke(ctx, in)
+		assert.NoError(t, err)
+		assert.NotNil(t, out)
+		assert.Equal(t, "hello there", string(out.Data.Value))
+	})
+
+	stopTestServer(t, s)
+}
+
+func TestAsyncBindingsSchema(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50003",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "orders",
+      "componentType": "bindings.kafka",
+      "schema": "{\"type\": \"object\", \"required\": [\"id\"]}"
+    }
+  }
+}`
+	ctx := context.Background()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	startTestServer(s)
+
+	t.Run("binding event with valid data", func(t *testing.T) {
+		in := &runtime.BindingEventRequest{Name: "orders", Data: []byte(`{"id": "1"}`)}
+		out, err := s.OnBindingEvent(ctx, in)
+		assert.NoError(t, err)
+		assert.NotNil(t, out)
+	})
+
+	t.Run("binding event with invalid data", func(t *testing.T) {
+		in := &runtime.BindingEventRequest{Name: "orders", Data: []byte(`{"name": 1}`)}
+		_, err := s.OnBindingEvent(ctx, in)
+		assert.Error(t, err)
+	})
+
+	stopTestServer(t, s)
+}
+
+func TestAsyncHealthPort(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50023",
+  "inputs": {
+    "cron": {
+      "uri": "cron_job",
+      "componentName": "cron_job",
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 2s"
+      }
+    }
+  }
+}`
+	os.Setenv(async.HealthPortEnvName, "18086")
+	defer os.Unsetenv(async.HealthPortEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://127.0.0.1:18086/healthz"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to reach health port: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("runtime did not stop with the context")
+	}
+
+	_, err = http.Get("http://127.0.0.1:18086/healthz")
+	assert.Error(t, err)
+}
+
+func TestAsyncRecordReplay(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50029",
+  "inputs": {
+    "events": {
+      "uri": "events",
+      "componentName": "events",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+	dir := t.TempDir()
+	os.Setenv(ofruntime.RecordEventsDirEnvName, dir)
+	defer os.Unsetenv(ofruntime.RecordEventsDirEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var mu sync.Mutex
+	var received []string
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, string(in)+":"+ctx.GetBindingEvent().Metadata["key"])
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	_, err = s.OnBindingEvent(ctx, &runtime.BindingEventRequest{
+		Name:     "events",
+		Data:     []byte("hello"),
+		Metadata: map[string]string{"key": "value"},
+	})
+	assert.NoError(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	assert.NoError(t, fwk.GetRuntime().Replay(dir))
+
+	mu.Lock()
+	assert.Equal(t, []string{"hello:value", "hello:value"}, received)
+	mu.Unlock()
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestAsyncDedupWindow(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50030",
+  "inputs": {
+    "events": {
+      "uri": "events",
+      "componentName": "events",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+	os.Setenv(ofruntime.DedupWindowSizeEnvName, "2")
+	os.Setenv(ofruntime.DedupWindowTTLEnvName, "200ms")
+	os.Setenv(ofruntime.DedupKeyMetadataEnvName, "eventID")
+	defer os.Unsetenv(ofruntime.DedupWindowSizeEnvName)
+	defer os.Unsetenv(ofruntime.DedupWindowTTLEnvName)
+	defer os.Unsetenv(ofruntime.DedupKeyMetadataEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	var mu sync.Mutex
+	var received []string
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, ctx.GetBindingEvent().Metadata["eventID"])
+		if string(in) == "fail" {
+			return ctx.ReturnOnInternalError(), errors.New("failed")
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	send := func(id, data string) {
+		req := &runtime.BindingEventRequest{Name: "events", Data: []byte(data), Metadata: map[string]string{"eventID": id}}
+		s.OnBindingEvent(ctx, req)
+	}
+
+	// the duplicate within the window is dropped
+	send("1", "hello")
+	send("1", "hello")
+	// the event failed to be processed is not remembered
+	send("2", "fail")
+	send("2", "hello")
+	// the least recently seen id is evicted once the size is reached
+	send("3", "hello")
+	send("1", "hello")
+	send("1", "hello")
+
+	// the duplicate outside the window is processed
+	time.Sleep(250 * time.Millisecond)
+	send("1", "hello")
+
+	mu.Lock()
+	assert.Equal(t, []string{"1", "2", "2", "3", "1", "1"}, received)
+	mu.Unlock()
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestAsyncDrain(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50028",
+  "inputs": {
+    "events": {
+      "uri": "events",
+      "componentName": "events",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	entered := make(chan struct{}, 1)
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		entered <- struct{}{}
+		time.Sleep(300 * time.Millisecond)
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	type result struct {
+		data []byte
+		err  error
+	}
+	slow := make(chan result)
+	go func() {
+		out, err := s.OnBindingEvent(context.Background(), &runtime.BindingEventRequest{Name: "events", Data: []byte("hello")})
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		slow <- result{data: out.Data}
+	}()
+	<-entered
+
+	// the new events are rejected while the in-flight one completes
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	_, err = s.OnBindingEvent(context.Background(), &runtime.BindingEventRequest{Name: "events", Data: []byte("late")})
+	assert.Error(t, err)
+
+	select {
+	case err := <-done:
+		t.Fatalf("runtime stopped before the in-flight event completed: %v", err)
+	case r := <-slow:
+		assert.NoError(t, r.err)
+		assert.Equal(t, "hello", string(r.data))
+	}
+	assert.NoError(t, <-done)
+}
+
+func TestAsyncReadiness(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50024",
+  "inputs": {
+    "cron": {
+      "uri": "cron_job",
+      "componentName": "cron_job",
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 2s"
+      }
+    }
+  }
+}`
+	os.Setenv(async.HealthPortEnvName, "18087")
+	defer os.Unsetenv(async.HealthPortEnvName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	readyz := func() int {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://127.0.0.1:18087/readyz"); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("failed to reach health port: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// the runtime is serving but the handlers of the inputs are not registered yet
+	assert.Equal(t, http.StatusServiceUnavailable, readyz())
+
+	if err := fwk.Register(ctx, fakeBindingsFunction); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, readyz())
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("runtime did not stop with the context")
+	}
+}
+
+func TestBuiltFunctionContext(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	os.Setenv(ofctx.TestModeEnvName, ofctx.TestModeOn)
+	// the built context is used in place of FUNC_CONTEXT
+	os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	fc, err := ofctx.NewBuilder("function-demo", "v1").
+		WithRuntime(ofctx.Async).
+		WithPort("50033").
+		WithInput("events", &ofctx.Input{Uri: "events", ComponentName: "events", ComponentType: "bindings.kafka"}).
+		WithOutput("echo", &ofctx.Output{ComponentName: "echo", ComponentType: "bindings.kafka", Operation: "create"}).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build function context: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fwk, err := NewFramework(ofctx.WithFunctionContext(fc))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	assert.Equal(t, "50033", fwk.Port())
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if !ctx.HasOutput("echo") {
+			return ctx.ReturnOnInternalError(), errors.New("output echo not found")
+		}
+		return ctx.ReturnOnSuccess().WithData(in), nil
+	}
+	if err := fwk.Register(ctx, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	s := fwk.GetRuntime().GetHandler().(*async.FakeServer)
+	out, err := s.OnBindingEvent(ctx, &runtime.BindingEventRequest{Name: "events", Data: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(out.Data))
+
+	// the invalid configuration is rejected when the context is built
+	_, err = ofctx.NewBuilder("function-demo", "v1").
+		WithRuntime(ofctx.Async).
+		WithInput("events", &ofctx.Input{ComponentName: "events", ComponentType: "unknown.kafka"}).
+		Build()
+	assert.Error(t, err)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestPortAndHttpPattern(t *testing.T) {
+	for env, want := range map[string][2]string{
+		`{"name": "function-demo", "runtime": "Knative"}`:                                         {"8080", "/"},
+		`{"name": "function-demo", "runtime": "Knative", "port": "8081", "httpPattern": "/demo"}`: {"8081", "/demo"},
+	} {
+		fwk, err := createFramework(env)
+		if err != nil {
+			t.Fatalf("failed to create framework: %v", err)
+		}
+		assert.Equal(t, want[0], fwk.Port())
+		assert.Equal(t, want[1], fwk.HttpPattern())
+	}
+}
+
+func TestGetRuntime(t *testing.T) {
+	for env, want := range map[string]ofctx.Runtime{
+		`{"name": "function-demo", "runtime": "Knative"}`:                ofctx.Knative,
+		`{"name": "function-demo", "runtime": "Async", "port": "50033"}`: ofctx.Async,
+	} {
+		fwk, err := createFramework(env)
+		if err != nil {
+			t.Fatalf("failed to create framework: %v", err)
+		}
+		assert.NotNil(t, fwk.GetRuntime())
+		assert.Equal(t, want, fwk.GetRuntime().Name())
+		if s, ok := fwk.GetRuntime().GetHandler().(*async.FakeServer); ok {
+			stopTestServer(t, s)
+		}
+	}
+}
+
+func TestRegisterBeforeRegisterPlugins(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/unordered",
+  "prePlugins": ["plugin-example"],
+  "postPlugins": ["plugin-example"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	impl := fwk.(*functionsFrameworkImpl)
+	assert.Len(t, impl.prePlugins, 1)
+	assert.Len(t, impl.postPlugins, 1)
+
+	// registering the plugins again must not duplicate them
+	fwk.RegisterPlugins(nil)
+	assert.Len(t, impl.prePlugins, 1)
+	assert.Len(t, impl.postPlugins, 1)
+}
+
+func TestRegisterPluginsProblems(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "prePlugins": ["plugin-example", "plugin-exmaple", "plugin-example"],
+  "postPlugins": ["plugin-example"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	impl := fwk.(*functionsFrameworkImpl)
+	errs := impl.registerPlugins(map[string]plugin.Plugin{"plugin-example": &slowPlugin{}})
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		"plugin plugin-example is already registered, skipping",
+		"plugin plugin-exmaple configured for pre-hook stage is not registered",
+		"plugin plugin-example is configured more than once for pre-hook stage, skipping",
+	}, msgs)
+
+	assert.Len(t, impl.prePlugins, 1)
+	assert.Len(t, impl.postPlugins, 1)
+	assert.Equal(t, "plugin-example", impl.prePlugins[0].Name())
+}
+
+type countingPlugin struct {
+	pre  *int32
+	post *int32
+}
+
+func (p *countingPlugin) Name() string {
+	return "plugin-counting"
+}
+
+func (p *countingPlugin) Version() string {
+	return "v1"
+}
+
+func (p *countingPlugin) Init() plugin.Plugin {
+	return p
+}
+
+func (p *countingPlugin) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	atomic.AddInt32(p.pre, 1)
+	return nil
+}
+
+func (p *countingPlugin) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	atomic.AddInt32(p.post, 1)
+	return nil
+}
+
+func (p *countingPlugin) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+func TestPluginRegistry(t *testing.T) {
+	var pre, post int32
+	plugin.Register("plugin-counting", func() plugin.Plugin {
+		return &countingPlugin{pre: &pre, post: &post}
+	})
+
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/registry",
+  "prePlugins": ["plugin-counting"],
+  "postPlugins": ["plugin-counting"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/registry")
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pre))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&post))
+}
+
+func TestDisabledPlugins(t *testing.T) {
+	var pre, post int32
+	os.Setenv(ofctx.DisabledPluginsEnvName, " plugin-example , plugin-counting")
+	defer os.Unsetenv(ofctx.DisabledPluginsEnvName)
+
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/disabled",
+  "prePlugins": ["plugin-counting", "plugin-example"],
+  "postPlugins": ["plugin-counting"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(map[string]plugin.Plugin{
+		"plugin-counting": &countingPlugin{pre: &pre, post: &post},
+	})
+
+	impl := fwk.(*functionsFrameworkImpl)
+	assert.Empty(t, impl.prePlugins)
+	assert.Empty(t, impl.postPlugins)
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/disabled")
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pre))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&post))
+}
+
+func TestPluginsCancelled(t *testing.T) {
+	var pre, post int32
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/cancelled",
+  "prePlugins": ["plugin-slow", "plugin-counting"],
+  "postPlugins": ["plugin-counting"]
+}`
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := &slowPlugin{delay: 2 * time.Second, returned: make(chan struct{}, 1)}
+	fwk, err := createFramework(env, ofctx.WithBaseContext(c))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(map[string]plugin.Plugin{
+		"plugin-counting": &countingPlugin{pre: &pre, post: &post},
+		"plugin-slow":     slow,
+	})
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/cancelled")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("TestPluginsCancelled: slow plugin was not abandoned, request took %v", elapsed)
+	}
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pre))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&post))
+
+	// the abandoned hook logs once it returns, which must not outlive the test
+	<-slow.returned
+}
+
+// TestHTTPFunctionCancelled tests that cancelling a request cancels its invocation only,
+// while the other requests in flight run to completion.
+func TestHTTPFunctionCancelled(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/concurrent",
+  "port": "8080"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	const n = 4
+	started := make(chan string, n+1)
+	cancelled := make(chan string, n+1)
+	release := make(chan struct{})
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		id := ctx.GetRequestHeader("X-Id")
+		started <- id
+		select {
+		case <-ctx.GetNativeContext().Done():
+			cancelled <- id
+			return ctx.ReturnOnInternalError(), ctx.GetNativeContext().Err()
+		case <-release:
+		}
+		return ctx.ReturnOnSuccess().WithData([]byte(id)), nil
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register OpenFunction function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	do := func(c context.Context, id string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(c, http.MethodPost, srv.URL+"/concurrent", strings.NewReader(id))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Id", id)
+		return http.DefaultClient.Do(req)
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if resp, err := do(c, "cancelled"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	codes := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := do(context.Background(), fmt.Sprintf("request-%d", i))
+			if err != nil {
+				t.Errorf("failed to do request %d: %v", i, err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			codes[i], bodies[i] = resp.StatusCode, string(body)
+		}(i)
+	}
+
+	for i := 0; i < n+1; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("TestHTTPFunctionCancelled: requests not in flight")
+		}
+	}
+
+	cancel()
+	select {
+	case id := <-cancelled:
+		assert.Equal(t, "cancelled", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("TestHTTPFunctionCancelled: invocation not cancelled with its request")
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Len(t, cancelled, 0)
+	for i := 0; i < n; i++ {
+		assert.Equal(t, http.StatusOK, codes[i])
+		assert.Equal(t, fmt.Sprintf("request-%d", i), bodies[i])
+	}
+}
+
+func TestPluginTimings(t *testing.T) {
+	var pre, post int32
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/timings",
+  "prePlugins": ["plugin-counting", "plugin-slow"],
+  "postPlugins": ["plugin-slow", "plugin-counting"]
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(map[string]plugin.Plugin{
+		"plugin-counting": &countingPlugin{pre: &pre, post: &post},
+		"plugin-slow":     &slowPlugin{delay: 20 * time.Millisecond},
+	})
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/timings")
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	timings := fwk.(*functionsFrameworkImpl).funcContext.GetPluginTimings()
+	if assert.Len(t, timings, 4) {
+		expected := []struct{ plugin, phase string }{
+			{"plugin-counting", ofctx.PhasePre},
+			{"plugin-slow", ofctx.PhasePre},
+			{"plugin-slow", ofctx.PhasePost},
+			{"plugin-counting", ofctx.PhasePost},
+		}
+		for i, e := range expected {
+			assert.Equal(t, e.plugin, timings[i].Plugin)
+			assert.Equal(t, e.phase, timings[i].Phase)
+		}
+		assert.GreaterOrEqual(t, int64(timings[1].Duration), int64(20*time.Millisecond))
+		assert.GreaterOrEqual(t, int64(timings[2].Duration), int64(20*time.Millisecond))
+	}
+}
+
+type configurablePlugin struct {
+	Greeting string `json:"greeting"`
+	greeted  chan string
+}
+
+func (p *configurablePlugin) Name() string {
+	return "plugin-configurable"
+}
+
+func (p *configurablePlugin) Version() string {
+	return "v1"
+}
+
+func (p *configurablePlugin) Init() plugin.Plugin {
+	return &configurablePlugin{greeted: p.greeted}
+}
+
+func (p *configurablePlugin) Configure(config json.RawMessage) error {
+	return json.Unmarshal(config, p)
+}
+
+func (p *configurablePlugin) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	p.greeted <- p.Greeting
+	return nil
+}
+
+func (p *configurablePlugin) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	return nil
+}
+
+func (p *configurablePlugin) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+func TestPluginsConfig(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/configured",
+  "prePlugins": ["plugin-configurable"],
+  "pluginsConfig": {
+    "plugin-configurable": {"greeting": "hello"}
+  }
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	greeted := make(chan string, 1)
+	fwk.RegisterPlugins(map[string]plugin.Plugin{"plugin-configurable": &configurablePlugin{greeted: greeted}})
+
+	if err := fwk.Register(context.Background(), fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/configured")
+	if err != nil {
+		t.Fatalf("failed to do client.Do: %v", err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, "hello", <-greeted)
+}
+
+func TestNoPluginsConfigured(t *testing.T) {
+	fwk, err := createFramework(`{"name": "function-demo", "runtime": "Knative", "httpPattern": "/noplugins"}`)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	impl := fwk.(*functionsFrameworkImpl)
+	assert.Empty(t, impl.pluginMap)
+	assert.Empty(t, impl.prePlugins)
+	assert.Empty(t, impl.postPlugins)
+}
+
+func TestValidate(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+
+	t.Run("invalid context", func(t *testing.T) {
+		os.Setenv(ofctx.FunctionContextEnvName, `{"name": "function-demo", "runtime": "invalid"}`)
+		assert.Error(t, Validate(nil))
+	})
+
+	t.Run("async runtime without inputs", func(t *testing.T) {
+		os.Setenv(ofctx.FunctionContextEnvName, `{"name": "function-demo", "runtime": "Async"}`)
+		assert.Error(t, Validate(nil))
+	})
+
+	t.Run("unknown plugin", func(t *testing.T) {
+		os.Setenv(ofctx.FunctionContextEnvName, `{"name": "function-demo", "runtime": "Knative", "prePlugins": ["plugin-absent"]}`)
+		assert.Error(t, Validate(nil))
+	})
+
+	t.Run("valid context", func(t *testing.T) {
+		os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-demo",
+  "runtime": "Async",
+  "port": "50013",
+  "prePlugins": ["plugin-example", "plugin-slow"],
+  "inputs": {
+    "cron": {
+      "uri": "cron_job",
+      "componentName": "cron_job",
+      "componentType": "bindings.cron",
+      "metadata": {
+        "schedule": "@every 2s"
+      }
+    }
+  }
+}`)
+		assert.NoError(t, Validate(map[string]plugin.Plugin{"plugin-slow": &slowPlugin{}}))
+
+		// the port must not be bound by the validation
+		l, err := net.Listen("tcp", ":50013")
+		assert.NoError(t, err)
+		if l != nil {
+			l.Close()
+		}
+	})
+}
+
+func TestAvroDecoderRegistration(t *testing.T) {
+	os.Setenv(ofctx.SchemaRegistryURLEnvName, "http://127.0.0.1:18081")
+	defer os.Unsetenv(ofctx.SchemaRegistryURLEnvName)
+
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "httpPattern": "/avro"
+}`
+	if _, err := createFramework(env); err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	decoder := ofctx.SelectDecoder(ofctx.AvroContentType)
+	if _, ok := decoder.(*ofctx.AvroCodec); !ok {
+		t.Fatalf("TestAvroDecoderRegistration: got decoder %T; want *AvroCodec", decoder)
+	}
+
+	// the decoder caching the schemas is not replaced when the function context is parsed again
+	if _, err := ofctx.GetRuntimeContext(); err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	assert.True(t, decoder == ofctx.SelectDecoder(ofctx.AvroContentType))
+}
+
+func createFramework(env string, opts ...ofctx.RuntimeContextOption) (Framework, error) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	os.Setenv(ofctx.TestModeEnvName, ofctx.TestModeOn)
+	os.Setenv(ofctx.FunctionContextEnvName, env)
+	fwk, err := NewFramework(opts...)
+	if err != nil {
+		return nil, err
+	} else {
+		return fwk, nil
+	}
+}
 
 func startTestServer(server common.Service) {
 	go func() {
@@ -348,3 +4442,353 @@ func stopTestServer(t *testing.T, server common.Service) {
 	err := server.Stop()
 	assert.Nilf(t, err, "error stopping server")
 }
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggingConfiguration(t *testing.T) {
+	out := &lockedBuffer{}
+	logOutput = out
+	os.Setenv(ofctx.LogLevelEnvName, "debug")
+	os.Setenv(ofctx.LogFormatEnvName, "json")
+	defer func() {
+		logOutput = os.Stderr
+		os.Setenv(ofctx.LogLevelEnvName, "0")
+		os.Unsetenv(ofctx.LogFormatEnvName)
+		configureLogging()
+		os.Unsetenv(ofctx.LogLevelEnvName)
+	}()
+
+	env := `{
+  "name": "function-demo",
+  "runtime": "Knative",
+  "port": "8080"
+}`
+	if _, err := createFramework(env); err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	klog.Infof("hello %s", "json")
+	klog.V(4).Info("debug message")
+	klog.V(5).Info("trace message")
+	klog.Flush()
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not json: %q", line)
+		}
+		assert.Contains(t, entry, "ts")
+		if msg, ok := entry["msg"].(string); ok {
+			messages = append(messages, strings.TrimSpace(msg))
+		}
+	}
+	assert.Contains(t, messages, "hello json")
+	assert.Contains(t, messages, "debug message")
+	assert.NotContains(t, messages, "trace message")
+
+	os.Setenv(ofctx.LogFormatEnvName, "xml")
+	_, err := createFramework(env)
+	assert.Error(t, err)
+}
+
+func TestHTTPFunctionBodyLogging(t *testing.T) {
+	out := &lockedBuffer{}
+	logOutput = out
+	os.Setenv(ofctx.LogFormatEnvName, "json")
+	os.Setenv(knative.BodyLogLevelEnvName, "0")
+	os.Setenv(knative.BodyLogMaxBytesEnvName, "40")
+	defer func() {
+		logOutput = os.Stderr
+		os.Unsetenv(ofctx.LogFormatEnvName)
+		configureLogging()
+		os.Unsetenv(knative.BodyLogLevelEnvName)
+		os.Unsetenv(knative.BodyLogMaxBytesEnvName)
+	}()
+
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "8080",
+  "runtime": "Knative",
+  "httpPattern": "/audit"
+}`
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(body)
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}
+	if err := fwk.Register(context.Background(), fn); err != nil {
+		t.Fatalf("failed to register HTTP function: %v\n", err)
+	}
+
+	srv := httptest.NewServer(fwk.GetRuntime().GetHandler().(http.Handler))
+	defer srv.Close()
+
+	reqBody := `{"user":"bob","password":"hunter2"}`
+	resp, err := http.Post(srv.URL+"/audit?q=1", "application/json", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("failed to do http request: %v", err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	// the response is written in full whatever the cap
+	assert.Equal(t, reqBody+strings.Repeat("x", 100), string(data))
+	klog.Flush()
+
+	var logged string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if msg, ok := entry["msg"].(string); ok && strings.HasPrefix(msg, "http POST /audit?q=1") {
+			logged = msg
+		}
+	}
+	assert.Contains(t, logged, "status 202")
+	assert.Contains(t, logged, `request body: {"user":"bob","password":"[REDACTED]"},`)
+	assert.Contains(t, logged, `response body: {"user":"bob","password":"[REDACTED]"}xxxxx...(truncated 95 bytes)`)
+	assert.NotContains(t, logged, "hunter2")
+	assert.Contains(t, logged, "request id "+resp.Header.Get(knative.RequestIDHeader))
+}
+
+func TestHTTPFunctionCatchAll(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18093",
+  "runtime": "Knative",
+  "httpPattern": "/*"
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	fwk.RegisterPlugins(nil)
+
+	proxy := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		ctx.Counter("proxied_total", nil).Inc()
+		data := ctx.GetRequestPath() + " " + ctx.GetPathParam(ofctx.CatchAllPathParam)
+		return ctx.ReturnOnSuccess().WithData([]byte(data)), nil
+	}
+	health := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}
+	if err := fwk.Register(ctx, proxy); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+	if err := fwk.RegisterWithPattern(ctx, "/healthz", health); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+	// the catch-all pattern collides with the root pattern
+	assert.Error(t, fwk.RegisterWithPattern(ctx, "/", health))
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	get := func(path string) (int, string) {
+		var resp *http.Response
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://127.0.0.1:18093" + path); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("failed to do http request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// the unmatched paths reach the catch-all function
+	for path, want := range map[string]string{
+		"/":                    "/ ",
+		"/api/v1/orders":       "/api/v1/orders api/v1/orders",
+		"/api/v1/orders/":      "/api/v1/orders/ api/v1/orders/",
+		"/static/app.js":       "/static/app.js static/app.js",
+		"/healthz/deep/status": "/healthz/deep/status healthz/deep/status",
+	} {
+		code, body := get(path)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, want, body)
+	}
+
+	// the health and metrics endpoints take precedence
+	code, body := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body)
+	code, body = get("/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "# TYPE proxied_total counter\nproxied_total 5\n")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+type valuesPlugin struct {
+	id      string
+	arrived *sync.WaitGroup
+	seen    chan<- string
+}
+
+func (p *valuesPlugin) Name() string {
+	return "plugin-values"
+}
+
+func (p *valuesPlugin) Version() string {
+	return "v1"
+}
+
+func (p *valuesPlugin) Init() plugin.Plugin {
+	return p
+}
+
+func (p *valuesPlugin) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	if v, ok := ctx.Value("invocation"); ok {
+		p.seen <- fmt.Sprintf("%v", v)
+	}
+	ctx.Set("invocation", p.id)
+	// both invocations have stored their value before either reads it back
+	p.arrived.Done()
+	p.arrived.Wait()
+	return nil
+}
+
+func (p *valuesPlugin) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	v, _ := ctx.Value("invocation")
+	p.seen <- fmt.Sprintf("%v", v)
+	return nil
+}
+
+func (p *valuesPlugin) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+func TestInvocationValues(t *testing.T) {
+	fwk, err := createFramework(`{"name": "function-demo", "runtime": "Knative", "httpPattern": "/values"}`)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	funcContext := fwk.(*functionsFrameworkImpl).funcContext
+
+	var arrived, done sync.WaitGroup
+	arrived.Add(2)
+	seen := map[string]chan string{}
+	var managers []*ofruntime.RuntimeManager
+	for _, id := range []string{"first", "second"} {
+		ch := make(chan string, 2)
+		seen[id] = ch
+		p := &valuesPlugin{id: id, arrived: &arrived, seen: ch}
+		managers = append(managers, ofruntime.NewRuntimeManager(funcContext, []plugin.Plugin{p}, []plugin.Plugin{p}))
+	}
+
+	// the hooks of the two invocations run concurrently on the same function context
+	for _, rm := range managers {
+		done.Add(1)
+		go func(rm *ofruntime.RuntimeManager) {
+			defer done.Done()
+			rm.ProcessPreHooks()
+			rm.ProcessPostHooks()
+		}(rm)
+	}
+	done.Wait()
+
+	// each invocation only sees the value it stored itself
+	for id, ch := range seen {
+		close(ch)
+		var got []string
+		for v := range ch {
+			got = append(got, v)
+		}
+		assert.Equal(t, []string{id}, got)
+	}
+
+	_, ok := funcContext.Value("invocation")
+	assert.False(t, ok)
+}
+
+type stoppablePlugin struct {
+	countingPlugin
+	stopped chan struct{}
+}
+
+func (p *stoppablePlugin) Stop() {
+	close(p.stopped)
+}
+
+func TestStopPlugins(t *testing.T) {
+	env := `{
+  "name": "function-demo",
+  "version": "v1.0.0",
+  "port": "18094",
+  "runtime": "Knative",
+  "httpPattern": "/stopplugins",
+  "prePlugins": ["plugin-stoppable"]
+}`
+	ctx, cancel := context.WithCancel(context.Background())
+	fwk, err := createFramework(env)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+
+	var pre, post int32
+	p := &stoppablePlugin{countingPlugin: countingPlugin{pre: &pre, post: &post}, stopped: make(chan struct{})}
+	fwk.RegisterPlugins(map[string]plugin.Plugin{"plugin-stoppable": p})
+	if err := fwk.Register(ctx, fakeHTTPFunction); err != nil {
+		t.Fatalf("failed to register HTTP function: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- fwk.Start(ctx)
+	}()
+
+	// the plugins are stopped once the function stops serving
+	select {
+	case <-p.stopped:
+		t.Fatal("plugin stopped while serving")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("function not stopped")
+	}
+	select {
+	case <-p.stopped:
+	default:
+		t.Fatal("plugin not stopped")
+	}
+}
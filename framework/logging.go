@@ -0,0 +1,82 @@
+package framework
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logLevels maps the named log levels to the klog verbosity.
+var logLevels = map[string]int{
+	"info":  0,
+	"debug": 4,
+	"trace": 6,
+}
+
+// logOutput is where the json logs are written to.
+var logOutput io.Writer = os.Stderr
+
+var (
+	loggerMu sync.Mutex
+	// loggerSet tells whether the json logger is set on klog, the logger is only cleared then since klog
+	// does not synchronize the logger with the logs written concurrently.
+	loggerSet bool
+)
+
+// configureLogging applies the klog verbosity from the LOG_LEVEL env, either a number or one of info, debug and trace,
+// and the log format from the LOG_FORMAT env, either text (the klog default) or json.
+func configureLogging() error {
+	level := 0
+	if s := strings.TrimSpace(os.Getenv(ofctx.LogLevelEnvName)); s != "" {
+		if l, ok := logLevels[strings.ToLower(s)]; ok {
+			level = l
+		} else if l, err := strconv.Atoi(s); err == nil && l >= 0 {
+			level = l
+		} else {
+			return fmt.Errorf("invalid %s: %s", ofctx.LogLevelEnvName, s)
+		}
+
+		fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+		klog.InitFlags(fs)
+		if err := fs.Set("v", strconv.Itoa(level)); err != nil {
+			return err
+		}
+	}
+
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	switch format := strings.ToLower(strings.TrimSpace(os.Getenv(ofctx.LogFormatEnvName))); format {
+	case "", LogFormatText:
+		if loggerSet {
+			klog.ClearLogger()
+			loggerSet = false
+		}
+	case LogFormatJSON:
+		klog.SetLogger(funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(logOutput, obj)
+		}, funcr.Options{
+			LogCaller:    funcr.All,
+			LogTimestamp: true,
+			Verbosity:    level,
+		}))
+		loggerSet = true
+	default:
+		return fmt.Errorf("invalid %s: %s, must be %s or %s", ofctx.LogFormatEnvName, format, LogFormatText, LogFormatJSON)
+	}
+	return nil
+}
@@ -8,10 +8,13 @@ require (
 	github.com/dapr/dapr v1.6.0
 	github.com/dapr/go-sdk v1.3.1
 	github.com/fatih/structs v1.1.0
+	github.com/go-logr/logr v1.2.0
 	github.com/golang/protobuf v1.5.2
 	github.com/google/uuid v1.3.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.7.0
+	github.com/xeipuuv/gojsonschema v1.2.0
+	golang.org/x/net v0.0.0-20210825183410-e898025ed96a
 	google.golang.org/grpc v1.40.0
 	k8s.io/klog/v2 v2.30.0
 	skywalking.apache.org/repo/goapi v0.0.0-20220121092418-9c455d0dda3f
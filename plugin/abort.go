@@ -0,0 +1,29 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAbort is matched by the errors returned from the pre-hooks to stop the function from running,
+// the runtime then responds with the code of the AbortError or nacks the event.
+var ErrAbort = errors.New("function aborted by plugin")
+
+// AbortError aborts the function with the code the runtime responds with.
+type AbortError struct {
+	Code   int
+	Reason string
+}
+
+// Abort returns the error aborting the function with the code and the reason.
+func Abort(code int, reason string) error {
+	return &AbortError{Code: code, Reason: reason}
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAbort.Error(), e.Reason)
+}
+
+func (e *AbortError) Is(target error) bool {
+	return target == ErrAbort
+}
@@ -0,0 +1,190 @@
+package debug
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/plugin"
+)
+
+const (
+	Name    = "plugin-debug"
+	Version = "v1"
+
+	redacted = "******"
+)
+
+// defaultRedactKeys are the keys whose values are redacted when no redaction keys are configured.
+var defaultRedactKeys = []string{"password", "secret", "token", "apiKey", "authorization"}
+
+// PluginDebug logs the function context at the pre and post phases for troubleshooting.
+type PluginDebug struct {
+	// Level is the klog verbosity the context is logged at.
+	Level klog.Level `json:"level,omitempty"`
+	// RedactKeys are the keys whose values are redacted, case-insensitive.
+	RedactKeys []string `json:"redactKeys,omitempty"`
+}
+
+var _ plugin.Plugin = &PluginDebug{}
+var _ plugin.Configurable = &PluginDebug{}
+
+func init() {
+	plugin.Register(Name, func() plugin.Plugin {
+		return New()
+	})
+}
+
+func New() *PluginDebug {
+	return &PluginDebug{
+		RedactKeys: defaultRedactKeys,
+	}
+}
+
+func (p *PluginDebug) Name() string {
+	return Name
+}
+
+func (p *PluginDebug) Version() string {
+	return Version
+}
+
+func (p *PluginDebug) Init() plugin.Plugin {
+	return New()
+}
+
+func (p *PluginDebug) Configure(config json.RawMessage) error {
+	return json.Unmarshal(config, p)
+}
+
+func (p *PluginDebug) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	return p.log("pre", ctx, false)
+}
+
+func (p *PluginDebug) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	return p.log("post", ctx, true)
+}
+
+func (p *PluginDebug) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+func (p *PluginDebug) log(phase string, ctx ofctx.RuntimeContext, withOut bool) error {
+	if !klog.V(p.Level).Enabled() {
+		return nil
+	}
+	dump, err := p.render(ctx, withOut)
+	if err != nil {
+		return err
+	}
+	klog.V(p.Level).Infof("function context at %s phase:\n%s", phase, dump)
+	return nil
+}
+
+// render pretty-prints the relevant fields of the context with the sensitive values redacted.
+func (p *PluginDebug) render(ctx ofctx.RuntimeContext, withOut bool) (string, error) {
+	fields := map[string]interface{}{
+		"name":      ctx.GetName(),
+		"runtime":   ctx.GetRuntime(),
+		"requestID": ctx.GetRequestID(),
+		"inputs":    ctx.GetInputs(),
+		"outputs":   ctx.GetOutputs(),
+		"event":     eventMeta(ctx),
+	}
+	if tracing := ctx.GetContext().PluginsTracing; tracing != nil {
+		fields["tracing"] = tracing
+	}
+	if withOut {
+		if out := ctx.GetOut(); out != nil {
+			fields["out"] = map[string]interface{}{
+				"code":     out.GetCode(),
+				"metadata": out.GetMetadata(),
+			}
+		}
+		if err := ctx.GetError(); err != nil {
+			fields["error"] = err.Error()
+		}
+	}
+
+	// round-trip through json to redact the nested values uniformly
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+
+	data, err = json.MarshalIndent(p.redact(v), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func eventMeta(ctx ofctx.RuntimeContext) map[string]interface{} {
+	meta := map[string]interface{}{}
+	if be := ctx.GetBindingEvent(); be != nil {
+		meta["binding"] = map[string]interface{}{"metadata": be.Metadata}
+	}
+	if te := ctx.GetTopicEvent(); te != nil {
+		meta["topic"] = map[string]interface{}{
+			"id":         te.ID,
+			"source":     te.Source,
+			"type":       te.Type,
+			"topic":      te.Topic,
+			"pubsubName": te.PubsubName,
+		}
+	}
+	if ie := ctx.GetInvocationEvent(); ie != nil {
+		meta["invocation"] = map[string]interface{}{
+			"verb":        ie.Verb,
+			"contentType": ie.ContentType,
+			"queryString": ie.QueryString,
+		}
+	}
+	if ce := ctx.GetCloudEvent(); ce != nil {
+		meta["cloudevent"] = map[string]interface{}{
+			"id":     ce.ID(),
+			"source": ce.Source(),
+			"type":   ce.Type(),
+		}
+	}
+	if trigger := ctx.GetCronTrigger(); trigger != nil {
+		meta["cron"] = trigger
+	}
+	return meta
+}
+
+func (p *PluginDebug) redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if p.isSensitive(k) {
+				t[k] = redacted
+			} else {
+				t[k] = p.redact(val)
+			}
+		}
+		return t
+	case []interface{}:
+		for i, val := range t {
+			t[i] = p.redact(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func (p *PluginDebug) isSensitive(key string) bool {
+	for _, k := range p.RedactKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
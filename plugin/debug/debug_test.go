@@ -0,0 +1,63 @@
+package debug
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dapr/go-sdk/service/common"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+func TestRedaction(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-debug",
+  "runtime": "Async",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "orders",
+      "componentType": "bindings.kafka",
+      "metadata": {"password": "input-secret"}
+    }
+  },
+  "outputs": {
+    "db": {
+      "uri": "db",
+      "componentName": "db",
+      "componentType": "bindings.postgres",
+      "metadata": {"connectionString": "output-secret", "table": "orders"}
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ctx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	ctx.SetEvent("orders", &common.BindingEvent{Data: []byte("{}"), Metadata: map[string]string{"PASSWORD": "event-secret"}})
+
+	p := New()
+	if err := p.Configure([]byte(`{"redactKeys": ["password", "connectionString"]}`)); err != nil {
+		t.Fatalf("failed to configure plugin: %v", err)
+	}
+
+	dump, err := p.render(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to render context: %v", err)
+	}
+
+	for _, secret := range []string{"input-secret", "output-secret", "event-secret"} {
+		if strings.Contains(dump, secret) {
+			t.Fatalf("sensitive value %s is not redacted:\n%s", secret, dump)
+		}
+	}
+	for _, value := range []string{redacted, `"table": "orders"`, `"name": "function-debug"`} {
+		if !strings.Contains(dump, value) {
+			t.Fatalf("value %s is missing:\n%s", value, dump)
+		}
+	}
+}
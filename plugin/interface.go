@@ -1,6 +1,8 @@
 package plugin
 
 import (
+	"encoding/json"
+
 	ofctx "github.com/tpiperatgod/offf-go/context"
 )
 
@@ -9,6 +11,9 @@ type Metadata interface {
 	Version() string
 }
 
+// Plugin runs its hooks before and after the function. A hook is abandoned once it exceeds its timeout
+// or the invocation is cancelled, but it keeps running in the background: it is expected to return once
+// ctx.GetHookContext() is done, and to leave the context of the invocation unchanged from then on.
 type Plugin interface {
 	Metadata
 	Init() Plugin
@@ -16,3 +21,15 @@ type Plugin interface {
 	ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]Plugin) error
 	Get(fieldName string) (interface{}, bool)
 }
+
+// Configurable is implemented by the plugins accepting the configuration
+// set for them in the `pluginsConfig` of the function context.
+type Configurable interface {
+	Configure(config json.RawMessage) error
+}
+
+// Stoppable is implemented by the plugins running work in the background,
+// they are stopped once the function stops serving.
+type Stoppable interface {
+	Stop()
+}
@@ -25,6 +25,12 @@ type PluginExample struct {
 
 var _ plugin.Plugin = &PluginExample{}
 
+func init() {
+	plugin.Register(Name, func() plugin.Plugin {
+		return New()
+	})
+}
+
 func New() *PluginExample {
 	return &PluginExample{}
 }
@@ -0,0 +1,202 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/plugin"
+)
+
+const (
+	Name    = "plugin-ratelimit"
+	Version = "v1"
+
+	headerKeyPrefix   = "header:"
+	metadataKeyPrefix = "metadata:"
+	inputKey          = "input"
+)
+
+// Config configures the token buckets of the rate limiter.
+type Config struct {
+	// Rate is the number of requests allowed per second.
+	Rate float64 `json:"rate"`
+	// Burst is the maximum number of requests allowed at once, defaults to the rate.
+	Burst int `json:"burst,omitempty"`
+	// Key selects the bucket of the request: `header:<name>` for an http header,
+	// `metadata:<key>` for a binding event metadata, or `input` for the input name.
+	// All the requests of the function share a single bucket when it is empty.
+	Key string `json:"key,omitempty"`
+}
+
+// PluginRateLimit aborts the function with 429 in its pre-hook once the token bucket of the request is empty.
+type PluginRateLimit struct {
+	limiter *limiter
+}
+
+var _ plugin.Plugin = &PluginRateLimit{}
+var _ plugin.Configurable = &PluginRateLimit{}
+
+func init() {
+	plugin.Register(Name, func() plugin.Plugin {
+		return New()
+	})
+}
+
+func New() *PluginRateLimit {
+	return &PluginRateLimit{
+		limiter: &limiter{now: time.Now},
+	}
+}
+
+func (p *PluginRateLimit) Name() string {
+	return Name
+}
+
+func (p *PluginRateLimit) Version() string {
+	return Version
+}
+
+// Init shares the token buckets across the function executions.
+func (p *PluginRateLimit) Init() plugin.Plugin {
+	return &PluginRateLimit{limiter: p.limiter}
+}
+
+func (p *PluginRateLimit) Configure(config json.RawMessage) error {
+	return p.limiter.configure(config)
+}
+
+func (p *PluginRateLimit) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	if !p.limiter.allow(p.limiter.key(ctx)) {
+		return plugin.Abort(http.StatusTooManyRequests, "rate limit exceeded")
+	}
+	return nil
+}
+
+func (p *PluginRateLimit) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
+	return nil
+}
+
+func (p *PluginRateLimit) Get(fieldName string) (interface{}, bool) {
+	return nil, false
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	mu        sync.Mutex
+	raw       json.RawMessage
+	config    Config
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// configure applies the config, the buckets are kept as long as the config is unchanged.
+func (l *limiter) configure(raw json.RawMessage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.raw != nil && bytes.Equal(l.raw, raw) {
+		return nil
+	}
+
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return err
+	}
+	if config.Rate <= 0 {
+		return errors.New("rate must be positive")
+	}
+	if config.Burst <= 0 {
+		config.Burst = int(config.Rate)
+		if config.Burst < 1 {
+			config.Burst = 1
+		}
+	}
+
+	l.raw = append(json.RawMessage(nil), raw...)
+	l.config = config
+	l.buckets = map[string]*bucket{}
+	l.lastSweep = l.now()
+	return nil
+}
+
+// refillTime returns how long an empty bucket takes to be full again.
+func (l *limiter) refillTime() time.Duration {
+	return time.Duration(float64(l.config.Burst) / l.config.Rate * float64(time.Second))
+}
+
+// sweep evicts the buckets idle for the refill time, they are full again and no different from new buckets.
+// The buckets are swept at most once per refill time so that the requests do not pay for it.
+func (l *limiter) sweep(now time.Time) {
+	idle := l.refillTime()
+	if now.Sub(l.lastSweep) < idle {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// key extracts the key of the bucket from the context.
+func (l *limiter) key(ctx ofctx.RuntimeContext) string {
+	l.mu.Lock()
+	key := l.config.Key
+	l.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(key, headerKeyPrefix):
+		if r := ctx.GetSyncRequest().Request; r != nil {
+			return r.Header.Get(strings.TrimPrefix(key, headerKeyPrefix))
+		}
+	case strings.HasPrefix(key, metadataKeyPrefix):
+		if be := ctx.GetBindingEvent(); be != nil {
+			return be.Metadata[strings.TrimPrefix(key, metadataKeyPrefix)]
+		}
+	case key == inputKey:
+		return ctx.GetContext().Event.InputName
+	}
+	return ""
+}
+
+// allow takes a token from the bucket of the key, the requests are allowed until the plugin is configured.
+func (l *limiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		return true
+	}
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.config.Rate
+	if max := float64(l.config.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
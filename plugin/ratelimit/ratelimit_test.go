@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dapr/go-sdk/service/common"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/plugin"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	l := &limiter{now: func() time.Time { return now }}
+	if err := l.configure([]byte(`{"rate": 2, "burst": 2}`)); err != nil {
+		t.Fatalf("failed to configure limiter: %v", err)
+	}
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("a"); got != want {
+			t.Fatalf("request %d of key a: got %v, want %v", i, got, want)
+		}
+	}
+
+	// the buckets are separated by key
+	if !l.allow("b") {
+		t.Fatal("request of key b is denied")
+	}
+
+	// a token is refilled after 1/rate second
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("a") || l.allow("a") {
+		t.Fatal("refilled tokens of key a are wrong")
+	}
+
+	// reconfiguring with the same config keeps the buckets
+	if err := l.configure([]byte(`{"rate": 2, "burst": 2}`)); err != nil {
+		t.Fatalf("failed to configure limiter: %v", err)
+	}
+	if l.allow("a") {
+		t.Fatal("buckets are reset by the same config")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	now := time.Now()
+	l := &limiter{now: func() time.Time { return now }}
+	if err := l.configure([]byte(`{"rate": 2, "burst": 2}`)); err != nil {
+		t.Fatalf("failed to configure limiter: %v", err)
+	}
+
+	l.allow("a")
+	l.allow("a")
+	now = now.Add(500 * time.Millisecond)
+	l.allow("b")
+	if len(l.buckets) != 2 {
+		t.Fatalf("got %d buckets, want 2", len(l.buckets))
+	}
+
+	// the bucket of key a is full again after burst/rate seconds, and evicted
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("b") {
+		t.Fatal("request of key b is denied")
+	}
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 1 {
+		t.Fatalf("idle bucket of key a is not evicted: %d buckets", len(l.buckets))
+	}
+
+	// the evicted bucket starts full
+	if !l.allow("a") || !l.allow("a") || l.allow("a") {
+		t.Fatal("tokens of the evicted key a are wrong")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	l := &limiter{now: time.Now}
+	if err := l.configure([]byte(`{"rate": 0}`)); err == nil {
+		t.Fatal("invalid rate is accepted")
+	}
+	if !l.allow("") {
+		t.Fatal("requests are denied before the limiter is configured")
+	}
+}
+
+func TestExecPreHook(t *testing.T) {
+	p := New()
+	if err := p.Configure([]byte(`{"rate": 1, "key": "metadata:user"}`)); err != nil {
+		t.Fatalf("failed to configure plugin: %v", err)
+	}
+
+	ctx := newBindingContext(t, map[string]string{"user": "alice"})
+	if err := p.Init().ExecPreHook(ctx, nil); err != nil {
+		t.Fatalf("first request is denied: %v", err)
+	}
+
+	err := p.Init().ExecPreHook(ctx, nil)
+	var abortErr *plugin.AbortError
+	if !errors.Is(err, plugin.ErrAbort) || !errors.As(err, &abortErr) || abortErr.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request is not aborted with 429: %v", err)
+	}
+
+	ctx.SetEvent("orders", &common.BindingEvent{Metadata: map[string]string{"user": "bob"}})
+	if err := p.Init().ExecPreHook(ctx, nil); err != nil {
+		t.Fatalf("request of another key is denied: %v", err)
+	}
+}
+
+func newBindingContext(t *testing.T, metadata map[string]string) ofctx.RuntimeContext {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-ratelimit",
+  "runtime": "Async",
+  "inputs": {
+    "orders": {
+      "componentName": "orders",
+      "componentType": "bindings.kafka"
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ctx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	ctx.SetEvent("orders", &common.BindingEvent{Metadata: metadata})
+	return ctx
+}
@@ -0,0 +1,39 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory creates a new instance of a plugin.
+type Factory func() Plugin
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a plugin factory available by the plugin name,
+// so that plugins can register themselves in their init function.
+// It panics if the factory is nil or a factory is already registered with the name.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("plugin: factory of plugin %s is nil", name))
+	}
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("plugin: plugin %s is already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Lookup returns the plugin factory registered with the name.
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := registry[name]
+	return factory, ok
+}
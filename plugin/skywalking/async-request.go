@@ -20,11 +20,24 @@ func preAsyncRequestCommonLogic(ofCtx ofctx.RuntimeContext, tracer *go2sky.Trace
 	}
 	ofCtx.SetNativeContext(nCtx)
 	span.Tag(tagRuntime, string(ofctx.Async))
+	setInputAttrs(ofCtx, span)
 	setPublicAttrs(nCtx, ofCtx, span)
 
 	return span, err
 }
 
+// setInputAttrs tags the span with the input the event comes from.
+func setInputAttrs(ofCtx ofctx.RuntimeContext, span go2sky.Span) {
+	name := ofCtx.GetInputName()
+	if name == "" {
+		return
+	}
+	span.Tag(tagInputName, name)
+	if input, ok := ofCtx.GetInputs()[name]; ok {
+		span.Tag(tagInputComponent, input.ComponentName)
+	}
+}
+
 func preTopicEventLogic(ofCtx ofctx.RuntimeContext, tracer *go2sky.Tracer) error {
 	span, err := preAsyncRequestCommonLogic(ofCtx, tracer)
 	if err != nil {
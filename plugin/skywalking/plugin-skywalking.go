@@ -2,7 +2,12 @@ package skywalking
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/SkyAPM/go2sky"
 	"github.com/SkyAPM/go2sky/reporter"
@@ -19,13 +24,26 @@ const (
 	version = "v1"
 
 	componentIDOpenFunction = 5013 // https://github.com/apache/skywalking/blob/master/oap-server/server-starter/src/main/resources/component-libraries.yml#L515
+
+	traceparentHeader = "traceparent"
+
+	oapDialTimeout      = 3 * time.Second
+	reconnectMaxBackoff = 30 * time.Second
 )
 
 var (
 	initGo2skyOnce sync.Once
+	tracerMu       sync.RWMutex
 
-	tagComponentType go2sky.Tag = "component.type"
-	tagRuntime       go2sky.Tag = "runtime"
+	// reconnectBackoff is the wait before the first reconnection to the oap server.
+	reconnectBackoff = time.Second
+
+	tagComponentType   go2sky.Tag = "component.type"
+	tagRuntime         go2sky.Tag = "runtime"
+	tagOutputName      go2sky.Tag = "output.name"
+	tagOutputComponent go2sky.Tag = "output.component"
+	tagInputName       go2sky.Tag = "input.name"
+	tagInputComponent  go2sky.Tag = "input.component"
 )
 
 type klogWrapper struct {
@@ -57,59 +75,180 @@ func (k klogWrapper) Errorf(format string, args ...interface{}) {
 
 func initGo2sky(ofCtx ofctx.RuntimeContext, p *PluginSkywalking) {
 	initGo2skyOnce.Do(func() {
-		r, err := reporter.NewGRPCReporter(ofCtx.GetPluginsTracingCfg().ProviderOapServer(), reporter.WithLog(&klogWrapper{}))
-		if err != nil {
-			klog.Errorf("new go2sky grpc reporter error\n", err)
+		p.start(ofCtx)
+	})
+}
+
+// start connects the tracer to the oap server, and keeps reconnecting in the background
+// while the oap server is unavailable, the requests are not traced in the meantime.
+func (p *PluginSkywalking) start(ofCtx ofctx.RuntimeContext) {
+	if err := validateOapServer(ofCtx.GetPluginsTracingCfg().ProviderOapServer()); err != nil {
+		klog.Errorf("failed to init skywalking tracer: %v", err)
+		return
+	}
+
+	if err := p.connect(ofCtx); err != nil {
+		// a single reconnection loop runs at a time
+		if !atomic.CompareAndSwapInt32(&p.reconnecting, 0, 1) {
 			return
 		}
-		tracer, err := go2sky.NewTracer(ofCtx.GetName(), go2sky.WithReporter(r), go2sky.WithInstance(ofCtx.GetPluginsTracingCfg().GetTags()["instance"]))
-		if err != nil {
-			klog.Errorf("new go2sky tracer error\n", err)
+		klog.Warningf("failed to init skywalking tracer, reconnecting in %s: %v", reconnectBackoff, err)
+		go p.reconnect(ofCtx)
+	}
+}
+
+// reconnect retries to connect the tracer with an exponential backoff until it is connected or the plugin is stopped.
+func (p *PluginSkywalking) reconnect(ofCtx ofctx.RuntimeContext) {
+	defer atomic.StoreInt32(&p.reconnecting, 0)
+
+	backoff := reconnectBackoff
+	for {
+		select {
+		case <-p.stopped():
+			klog.Infof("stopped reconnecting skywalking tracer to %s", ofCtx.GetPluginsTracingCfg().ProviderOapServer())
 			return
+		case <-time.After(backoff):
 		}
-		go2sky.SetGlobalTracer(tracer)
+		err := p.connect(ofCtx)
+		if err == nil {
+			klog.Infof("skywalking tracer connected to %s", ofCtx.GetPluginsTracingCfg().ProviderOapServer())
+			return
+		}
+		if backoff *= 2; backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+		klog.Warningf("failed to reconnect skywalking tracer, retrying in %s: %v", backoff, err)
+	}
+}
 
-		p.tracer = tracer
-	})
+// connect creates the tracer once the oap server is reachable.
+func (p *PluginSkywalking) connect(ofCtx ofctx.RuntimeContext) error {
+	addr := ofCtx.GetPluginsTracingCfg().ProviderOapServer()
+	conn, err := net.DialTimeout("tcp", addr, oapDialTimeout)
+	if err != nil {
+		return fmt.Errorf("oap server %s is unavailable: %v", addr, err)
+	}
+	conn.Close()
+
+	tracer, err := newTracer(ofCtx)
+	if err != nil {
+		return err
+	}
+	go2sky.SetGlobalTracer(tracer)
+	p.setTracer(tracer)
+	return nil
+}
+
+func (p *PluginSkywalking) getTracer() *go2sky.Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return p.tracer
+}
+
+func (p *PluginSkywalking) setTracer(tracer *go2sky.Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	p.tracer = tracer
+}
+
+// newTracer creates the tracer reporting to the oap server, sampling the traces at the configured rate
+// and registering the tags as the properties of the instance.
+func newTracer(ofCtx ofctx.RuntimeContext) (*go2sky.Tracer, error) {
+	cfg := ofCtx.GetPluginsTracingCfg()
+	if err := validateOapServer(cfg.ProviderOapServer()); err != nil {
+		return nil, err
+	}
+
+	r, err := reporter.NewGRPCReporter(cfg.ProviderOapServer(), reporter.WithLog(&klogWrapper{}), reporter.WithInstanceProps(cfg.GetTags()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create grpc reporter: %v", err)
+	}
+	tracer, err := go2sky.NewTracer(ofCtx.GetName(),
+		go2sky.WithReporter(r),
+		go2sky.WithInstance(cfg.GetTags()["instance"]),
+		go2sky.WithSampler(cfg.GetSamplingRate()))
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to create tracer: %v", err)
+	}
+	return tracer, nil
+}
+
+// validateOapServer checks that the oap server address is formatted as `host:port`.
+func validateOapServer(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid oap server address %q: %v", addr, err)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid oap server address %q: missing host", addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid oap server address %q: invalid port %s", addr, port)
+	}
+	return nil
 }
 
 var _ plugin.Plugin = &PluginSkywalking{}
+var _ ofctx.SendTracer = &PluginSkywalking{}
+var _ plugin.Stoppable = &PluginSkywalking{}
 
 type PluginSkywalking struct {
-	tracer *go2sky.Tracer
+	tracer       *go2sky.Tracer
+	reconnecting int32
+	stop         chan struct{}
+	stopInit     sync.Once
+	stopOnce     sync.Once
+}
+
+// stopped returns the channel closed once the plugin is stopped.
+func (p *PluginSkywalking) stopped() chan struct{} {
+	p.stopInit.Do(func() {
+		p.stop = make(chan struct{})
+	})
+	return p.stop
+}
+
+// Stop stops the reconnection to the oap server when the function stops serving.
+func (p *PluginSkywalking) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopped())
+	})
 }
 
 func (p *PluginSkywalking) Init() plugin.Plugin {
 	return p
 }
 
-func (p PluginSkywalking) Name() string {
+func (p *PluginSkywalking) Name() string {
 	return name
 }
 
-func (p PluginSkywalking) Version() string {
+func (p *PluginSkywalking) Version() string {
 	return version
 
 }
 
 func (p *PluginSkywalking) ExecPreHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
 	initGo2sky(ctx, p)
-	if p.tracer == nil {
+	tracer := p.getTracer()
+	if tracer == nil {
 		return nil
 	}
+	ctx.SetSendTracer(p)
 
 	if ctx.GetSyncRequest().Request != nil {
-		return preSyncRequestLogic(ctx, p.tracer)
+		return preSyncRequestLogic(ctx, tracer)
 	} else if ctx.GetBindingEvent() != nil {
-		return preBindingEventLogic(ctx, p.tracer)
+		return preBindingEventLogic(ctx, tracer)
 	} else if ctx.GetTopicEvent() != nil {
-		return preTopicEventLogic(ctx, p.tracer)
+		return preTopicEventLogic(ctx, tracer)
 	}
 	return nil
 }
 
 func (p *PluginSkywalking) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[string]plugin.Plugin) error {
-	if p.tracer == nil {
+	if p.getTracer() == nil {
 		return nil
 	}
 
@@ -121,7 +260,30 @@ func (p *PluginSkywalking) ExecPostHook(ctx ofctx.RuntimeContext, plugins map[st
 	return nil
 }
 
-func (p PluginSkywalking) Get(fieldName string) (interface{}, bool) {
+// StartSend creates an exit span for the send to the output as a child of the entry span.
+func (p *PluginSkywalking) StartSend(ctx context.Context, outputName string, output *ofctx.Output) func(err error) {
+	span, err := p.getTracer().CreateExitSpan(ctx, outputName, output.ComponentName, func(key, value string) error {
+		return nil
+	})
+	if err != nil {
+		klog.Warningf("failed to create exit span for output %s: %v", outputName, err)
+		return func(err error) {}
+	}
+	span.SetSpanLayer(agentv3.SpanLayer_FAAS)
+	span.SetComponent(componentIDOpenFunction)
+	span.Tag(tagOutputName, outputName)
+	span.Tag(tagOutputComponent, output.ComponentName)
+	span.Tag(tagComponentType, string(output.GetType()))
+
+	return func(err error) {
+		if err != nil {
+			span.Error(time.Now(), err.Error())
+		}
+		span.End()
+	}
+}
+
+func (p *PluginSkywalking) Get(fieldName string) (interface{}, bool) {
 	return nil, false
 }
 
@@ -0,0 +1,367 @@
+package skywalking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SkyAPM/go2sky"
+	"github.com/dapr/go-sdk/service/common"
+	"github.com/stretchr/testify/assert"
+	agentv3 "skywalking.apache.org/repo/goapi/collect/language/agent/v3"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+// recordingReporter records the spans of the finished segments.
+type recordingReporter struct {
+	segments chan []go2sky.ReportedSpan
+}
+
+func (r *recordingReporter) Boot(service string, serviceInstance string, cdsWatchers []go2sky.AgentConfigChangeWatcher) {
+}
+
+func (r *recordingReporter) Send(spans []go2sky.ReportedSpan) {
+	r.segments <- spans
+}
+
+func (r *recordingReporter) Close() {}
+
+func tagValue(span go2sky.ReportedSpan, key go2sky.Tag) string {
+	for _, tag := range span.Tags() {
+		if tag.Key == string(key) {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+func TestStartSend(t *testing.T) {
+	r := &recordingReporter{segments: make(chan []go2sky.ReportedSpan, 1)}
+	tracer, err := go2sky.NewTracer("function-test", go2sky.WithReporter(r))
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	p := &PluginSkywalking{tracer: tracer}
+
+	entry, ctx, err := tracer.CreateEntrySpan(context.Background(), "function-test", func(key string) (string, error) {
+		return "", nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create entry span: %v", err)
+	}
+
+	output := &ofctx.Output{ComponentName: "kafka-server", ComponentType: "pubsub.kafka"}
+	p.StartSend(ctx, "topic-sent", output)(nil)
+	p.StartSend(ctx, "topic-failed", output)(errors.New("send failed"))
+	entry.End()
+
+	var spans []go2sky.ReportedSpan
+	select {
+	case spans = <-r.segments:
+	case <-time.After(5 * time.Second):
+		t.Fatal("segment not reported")
+	}
+
+	// the spans are not reported in the order they are finished, they are found by operation name
+	exits := map[string]go2sky.ReportedSpan{}
+	var entrySpanID int32
+	for _, span := range spans {
+		switch span.SpanType() {
+		case agentv3.SpanType_Exit:
+			exits[span.OperationName()] = span
+		case agentv3.SpanType_Entry:
+			entrySpanID = span.Context().SpanID
+		}
+	}
+	if assert.Len(t, exits, 2) {
+		for name, span := range exits {
+			assert.Equal(t, entrySpanID, span.Context().ParentSpanID)
+			assert.Equal(t, "kafka-server", span.Peer())
+			assert.Equal(t, name, tagValue(span, tagOutputName))
+			assert.Equal(t, "kafka-server", tagValue(span, tagOutputComponent))
+			assert.Equal(t, string(ofctx.OpenFuncTopic), tagValue(span, tagComponentType))
+		}
+		if assert.Contains(t, exits, "topic-sent") {
+			assert.False(t, exits["topic-sent"].IsError())
+		}
+		if assert.Contains(t, exits, "topic-failed") {
+			assert.True(t, exits["topic-failed"].IsError())
+		}
+	}
+}
+
+func TestTopicEventInputTags(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Async",
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost:11800"
+    }
+  },
+  "inputs": {
+    "sub": {
+      "uri": "sample",
+      "componentName": "kafka-server",
+      "componentType": "pubsub.kafka"
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ofCtx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	ofCtx.SetEvent("sub", &common.TopicEvent{ID: "a123", Topic: "sample", PubsubName: "kafka-server", Data: "hello"})
+
+	r := &recordingReporter{segments: make(chan []go2sky.ReportedSpan, 1)}
+	tracer, err := go2sky.NewTracer("function-test", go2sky.WithReporter(r))
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	if err := preTopicEventLogic(ofCtx, tracer); err != nil {
+		t.Fatalf("failed to create entry span: %v", err)
+	}
+	go2sky.ActiveSpan(ofCtx.GetNativeContext()).End()
+
+	var spans []go2sky.ReportedSpan
+	select {
+	case spans = <-r.segments:
+	case <-time.After(5 * time.Second):
+		t.Fatal("segment not reported")
+	}
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "sub", tagValue(spans[0], tagInputName))
+		assert.Equal(t, "kafka-server", tagValue(spans[0], tagInputComponent))
+		assert.Equal(t, string(ofctx.OpenFuncTopic), tagValue(spans[0], tagComponentType))
+	}
+}
+
+func TestSyncRequestTraceparent(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Knative",
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost:11800"
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ofCtx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ofCtx.SetSyncRequest(httptest.NewRecorder(), req)
+
+	r := &recordingReporter{segments: make(chan []go2sky.ReportedSpan, 1)}
+	tracer, err := go2sky.NewTracer("function-test", go2sky.WithReporter(r))
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	if err := preSyncRequestLogic(ofCtx, tracer); err != nil {
+		t.Fatalf("failed to create entry span: %v", err)
+	}
+	go2sky.ActiveSpan(ofCtx.GetNativeContext()).End()
+
+	var spans []go2sky.ReportedSpan
+	select {
+	case spans = <-r.segments:
+	case <-time.After(5 * time.Second):
+		t.Fatal("segment not reported")
+	}
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].Context().TraceID)
+		if assert.Len(t, spans[0].Refs(), 1) {
+			assert.Equal(t, "00f067aa0ba902b7", spans[0].Refs()[0].ParentSegmentID)
+		}
+	}
+}
+
+func TestTraceparentToSW8(t *testing.T) {
+	for traceparent, valid := range map[string]bool{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00": true,
+		"": false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":    false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01": false,
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01": false,
+	} {
+		assert.Equal(t, valid, traceparentToSW8(traceparent, "localhost") != "", traceparent)
+	}
+}
+
+func TestValidateOapServer(t *testing.T) {
+	for addr, valid := range map[string]bool{
+		"localhost:11800":            true,
+		"oap.skywalking.svc:11800":   true,
+		"[::1]:11800":                true,
+		"localhost":                  false,
+		"localhost:xxx":              false,
+		"localhost:0":                false,
+		":11800":                     false,
+		"http://localhost:11800/api": false,
+		"":                           false,
+	} {
+		err := validateOapServer(addr)
+		assert.Equal(t, valid, err == nil, "address %q: %v", addr, err)
+	}
+}
+
+func TestNewTracerMalformedOapServer(t *testing.T) {
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Knative",
+  "pluginsTracing": {
+    "enable": true,
+    "samplingRate": 0.5,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "localhost"
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ofCtx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+	assert.Equal(t, 0.5, ofCtx.GetPluginsTracingCfg().GetSamplingRate())
+
+	_, err = newTracer(ofCtx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid oap server address")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	// reserve a port on which the oap server is not listening yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, fmt.Sprintf(`{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Knative",
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "%s"
+    }
+  }
+}`, addr))
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ofCtx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+
+	backoff := reconnectBackoff
+	reconnectBackoff = 10 * time.Millisecond
+	defer func() { reconnectBackoff = backoff }()
+
+	p := &PluginSkywalking{}
+	p.start(ofCtx)
+	assert.Nil(t, p.getTracer())
+
+	// the oap server becomes available
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.getTracer() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("tracer not reconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReconnectStop(t *testing.T) {
+	// reserve a port on which the oap server is not listening
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, fmt.Sprintf(`{
+  "name": "function-test",
+  "version": "v1.0.0",
+  "runtime": "Knative",
+  "pluginsTracing": {
+    "enable": true,
+    "provider": {
+      "name": "skywalking",
+      "oapServer": "%s"
+    }
+  }
+}`, addr))
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	ofCtx, err := ofctx.GetRuntimeContext()
+	if err != nil {
+		t.Fatalf("failed to parse function context: %v", err)
+	}
+
+	backoff := reconnectBackoff
+	reconnectBackoff = 10 * time.Millisecond
+	defer func() { reconnectBackoff = backoff }()
+
+	p := &PluginSkywalking{}
+	p.start(ofCtx)
+	// a single reconnection loop runs while the oap server is unavailable
+	p.start(ofCtx)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&p.reconnecting))
+
+	p.Stop()
+	p.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&p.reconnecting) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("reconnection not stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, p.getTracer())
+}
@@ -1,10 +1,15 @@
 package skywalking
 
 import (
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/SkyAPM/go2sky"
+	"github.com/SkyAPM/go2sky/propagation"
 
 	ofctx "github.com/tpiperatgod/offf-go/context"
 )
@@ -12,9 +17,7 @@ import (
 func preSyncRequestLogic(ofCtx ofctx.RuntimeContext, tracer *go2sky.Tracer) error {
 	request := ofCtx.GetSyncRequest().Request
 
-	span, nCtx, err := tracer.CreateEntrySpan(ofCtx.GetSyncRequest().Request.Context(), ofCtx.GetName(), func(key string) (string, error) {
-		return request.Header.Get(key), nil
-	})
+	span, nCtx, err := tracer.CreateEntrySpan(ofCtx.GetSyncRequest().Request.Context(), ofCtx.GetName(), extractTraceContext(request))
 	if err != nil {
 		return err
 	}
@@ -45,3 +48,44 @@ func postSyncRequestLogic(ctx ofctx.RuntimeContext) error {
 	}
 	return nil
 }
+
+// extractTraceContext returns the extractor of the trace context of the request, which continues
+// the trace of the sw8 header, or else the W3C trace of the traceparent header.
+func extractTraceContext(request *http.Request) propagation.Extractor {
+	return func(key string) (string, error) {
+		value := request.Header.Get(key)
+		if value == "" && key == propagation.Header {
+			return traceparentToSW8(request.Header.Get(traceparentHeader), request.Host), nil
+		}
+		return value, nil
+	}
+}
+
+// traceparentToSW8 converts the W3C traceparent header `version-traceid-parentid-flags` into a sw8 header
+// continuing the same trace, empty if the traceparent header is missing or malformed.
+func traceparentToSW8(traceparent string, host string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ""
+	}
+	for _, part := range parts[:3] {
+		if _, err := hex.DecodeString(part); err != nil {
+			return ""
+		}
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return ""
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return ""
+	}
+
+	sc := &propagation.SpanContext{
+		Sample:              int8(flags & 1),
+		TraceID:             parts[1],
+		ParentSegmentID:     parts[2],
+		AddressUsedAtClient: host,
+	}
+	return sc.EncodeSW8()
+}
@@ -0,0 +1,64 @@
+package async
+
+import (
+	"fmt"
+	"sync"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+	daprd "github.com/dapr/go-sdk/service/grpc"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+// InputAdapter subscribes to the inputs of the function and delivers their messages into the pipeline.
+// The Dapr service is the default adapter, the brokers reached without Dapr can be served by their own adapters.
+type InputAdapter interface {
+	// AddBindingInvocationHandler delivers the events of the binding input with the name to the handler.
+	AddBindingInvocationHandler(name string, fn dapr.BindingInvocationHandler) error
+	// AddTopicEventHandler delivers the events of the subscribed topic to the handler.
+	AddTopicEventHandler(sub *dapr.Subscription, fn dapr.TopicEventHandler) error
+	// AddServiceInvocationHandler delivers the invocations of the service method with the name to the handler.
+	AddServiceInvocationHandler(name string, fn dapr.ServiceInvocationHandler) error
+	// Start starts delivering the messages, it blocks until the adapter is stopped.
+	Start() error
+	// Stop stops delivering the messages.
+	Stop() error
+}
+
+// InputConfigurable is implemented by the input adapters configured by the inputs they subscribe to,
+// the input is passed to the adapter before its handler is added.
+type InputConfigurable interface {
+	ConfigureInput(name string, input *ofctx.Input) error
+}
+
+// InputAdapterFactory creates the input adapter of the function serving on the port.
+type InputAdapterFactory func(port string) (InputAdapter, error)
+
+var (
+	inputAdapterMu      sync.RWMutex
+	inputAdapterFactory InputAdapterFactory
+)
+
+var _ InputAdapter = dapr.Service(nil)
+
+// SetInputAdapterFactory sets the factory of the input adapter of the async runtimes created afterwards,
+// nil restores the default Dapr adapter.
+func SetInputAdapterFactory(factory InputAdapterFactory) {
+	inputAdapterMu.Lock()
+	defer inputAdapterMu.Unlock()
+
+	inputAdapterFactory = factory
+}
+
+// getInputAdapterFactory returns the factory of the input adapter, nil if the default Dapr adapter is used.
+func getInputAdapterFactory() InputAdapterFactory {
+	inputAdapterMu.RLock()
+	defer inputAdapterMu.RUnlock()
+
+	return inputAdapterFactory
+}
+
+// NewDaprInputAdapter creates the default input adapter based on the Dapr grpc service.
+func NewDaprInputAdapter(port string) (InputAdapter, error) {
+	return daprd.NewService(fmt.Sprintf(":%s", port))
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cpb "github.com/dapr/dapr/pkg/proto/common/v1"
+	pb "github.com/dapr/dapr/pkg/proto/runtime/v1"
 	dapr "github.com/dapr/go-sdk/service/common"
-	daprd "github.com/dapr/go-sdk/service/grpc"
+	"github.com/golang/protobuf/ptypes/any"
 	"k8s.io/klog/v2"
 
 	ofctx "github.com/tpiperatgod/offf-go/context"
@@ -18,40 +24,160 @@ import (
 	"github.com/tpiperatgod/offf-go/runtime"
 )
 
+const (
+	HealthPortEnvName = "HEALTH_PORT"
+	healthPath        = "/healthz"
+	readyPath         = "/readyz"
+	metricsPath       = "/metrics"
+
+	// OrderedMetadataKey enables the processing of the topic events in order when set to "true".
+	OrderedMetadataKey = "ordered"
+	// OrderingKeyMetadataKey names the partition key of the ordered topic events: `source`, `type`,
+	// or a field of the JSON event data. The events without a partition key are processed in order altogether.
+	OrderingKeyMetadataKey = "orderingKey"
+)
+
 type Runtime struct {
-	port       string
-	handler    dapr.Service
-	grpcHander *FakeServer
+	port         string
+	handler      InputAdapter
+	daprAdapter  bool
+	grpcHander   *FakeServer
+	healthServer *http.Server
+	// registered is set once the handlers of all the inputs are registered
+	registered int32
+	// serving is set while the input adapter is serving
+	serving  int32
+	tracker  runtime.InflightTracker
+	recorder *runtime.Recorder
+	dedup    *runtime.DedupWindow
+	pool     *workerPool
 }
 
 func NewAsyncRuntime(port string) (*Runtime, error) {
+	if factory := getInputAdapterFactory(); factory != nil {
+		handler, err := factory(port)
+		if err != nil {
+			klog.Errorf("failed to create input adapter: %v\n", err)
+			return nil, err
+		}
+		r := &Runtime{
+			port:     port,
+			handler:  handler,
+			recorder: runtime.NewRecorder(),
+			dedup:    runtime.NewDedupWindowFromEnv(),
+			pool:     newWorkerPoolFromEnv(),
+		}
+		r.healthServer = r.newHealthServer()
+		return r, nil
+	}
 	if testMode := os.Getenv(ofctx.TestModeEnvName); testMode == ofctx.TestModeOn {
 		handler, grpcHandler, err := NewFakeService(fmt.Sprintf(":%s", port))
 		if err != nil {
 			klog.Errorf("failed to create dapr grpc service: %v\n", err)
 			return nil, err
 		}
-		return &Runtime{
-			port:       port,
-			handler:    handler,
-			grpcHander: grpcHandler,
-		}, nil
+		r := &Runtime{
+			port:        port,
+			handler:     handler,
+			daprAdapter: true,
+			grpcHander:  grpcHandler,
+			recorder:    runtime.NewRecorder(),
+			dedup:       runtime.NewDedupWindowFromEnv(),
+			pool:        newWorkerPoolFromEnv(),
+		}
+		r.healthServer = r.newHealthServer()
+		return r, nil
 	}
-	handler, err := daprd.NewService(fmt.Sprintf(":%s", port))
+	handler, err := NewDaprInputAdapter(port)
 	if err != nil {
 		klog.Errorf("failed to create dapr grpc service: %v\n", err)
 		return nil, err
 	}
-	return &Runtime{
-		port:       port,
-		handler:    handler,
-		grpcHander: nil,
-	}, nil
+	r := &Runtime{
+		port:        port,
+		handler:     handler,
+		daprAdapter: true,
+		grpcHander:  nil,
+		recorder:    runtime.NewRecorder(),
+		dedup:       runtime.NewDedupWindowFromEnv(),
+		pool:        newWorkerPoolFromEnv(),
+	}
+	r.healthServer = r.newHealthServer()
+	return r, nil
+}
+
+// newHealthServer creates the auxiliary http server for the probes and the metrics if the health port is set,
+// the function is ready once the handlers of all the inputs are registered and the input adapter is serving.
+func (r *Runtime) newHealthServer() *http.Server {
+	port := os.Getenv(HealthPortEnvName)
+	if port == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(readyPath, func(w http.ResponseWriter, req *http.Request) {
+		if !r.ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.Handle(metricsPath, ofctx.DefaultMetrics)
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%s", port),
+		Handler: mux,
+	}
+}
+
+// ready detects if the handlers of all the inputs are registered and the input adapter is serving.
+func (r *Runtime) ready() bool {
+	return atomic.LoadInt32(&r.registered) == 1 && atomic.LoadInt32(&r.serving) == 1
 }
 
 func (r *Runtime) Start(ctx context.Context) error {
+	if r.healthServer != nil {
+		go func() {
+			klog.Infof("Async Function serving health checks: listening on %s", r.healthServer.Addr)
+			if err := r.healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				klog.Errorf("failed to serve health checks: %v", err)
+			}
+		}()
+	}
+
+	// Stop serving once the context is done and the in-flight invocations are finished within the grace period
+	go func() {
+		<-ctx.Done()
+		grace := runtime.GracePeriod()
+		c, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		if err := r.tracker.Drain(c); err != nil {
+			klog.Warningf("forcing the stop of dapr grpc service after the grace period %s: %v", grace, err)
+		}
+		r.pool.Stop()
+		if err := r.handler.Stop(); err != nil {
+			klog.Errorf("failed to stop dapr grpc service: %v", err)
+		}
+	}()
+
 	klog.Infof("Async Function serving grpc: listening on port %s", r.port)
-	klog.Fatal(r.handler.Start())
+	atomic.StoreInt32(&r.serving, 1)
+	err := r.handler.Start()
+	atomic.StoreInt32(&r.serving, 0)
+	if r.healthServer != nil {
+		if err := r.healthServer.Close(); err != nil {
+			klog.Errorf("failed to stop health server: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	klog.Fatal(err)
 	return nil
 }
 
@@ -74,39 +200,114 @@ func (r *Runtime) RegisterCloudEventFunction(
 	return errors.New("async runtime cannot register cloudevent function")
 }
 
+func (r *Runtime) RegisterCloudEventResponseFunction(
+	ctx context.Context,
+	funcContext ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error),
+) error {
+	return errors.New("async runtime cannot register cloudevent function")
+}
+
 func (r *Runtime) RegisterOpenFunction(
 	ctx ofctx.RuntimeContext,
 	prePlugins []plugin.Plugin,
 	postPlugins []plugin.Plugin,
 	fn func(ofctx.Context, []byte) (ofctx.Out, error),
+) error {
+	return r.registerOpenFunction(ctx, prePlugins, postPlugins, fn)
+}
+
+// RegisterStreamFunction registers the function reading the event data as a stream,
+// the dapr sdk delivers the data in full so that it is read from memory.
+func (r *Runtime) RegisterStreamFunction(
+	ctx ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn func(ofctx.Context, io.Reader) (ofctx.Out, error),
+) error {
+	return r.registerOpenFunction(ctx, prePlugins, postPlugins, fn)
+}
+
+func (r *Runtime) registerOpenFunction(
+	ctx ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn interface{},
 ) error {
 	// Register the asynchronous functions (based on the Dapr runtime)
-	return func(f func(ofctx.Context, []byte) (ofctx.Out, error)) error {
+	return func(f interface{}) error {
 		var funcErr error
 
-		// Initialize dapr client if it is nil
-		ctx.InitDaprClientIfNil()
+		// Initialize dapr client if it is nil, the inputs served without dapr do not wait for the sidecar
+		if r.daprAdapter {
+			if err := runtime.InitDaprClientWithBackoff(ctx); err != nil {
+				klog.Errorf("failed to register function: %v\n", err)
+				return err
+			}
+		}
 
 		// Serving function with inputs
 		if ctx.HasInputs() {
 			for name, input := range ctx.GetInputs() {
+				name, input := name, input
+				if c, ok := r.handler.(InputConfigurable); ok {
+					if err := c.ConfigureInput(name, input); err != nil {
+						klog.Errorf("failed to configure input %s: %v\n", name, err)
+						return err
+					}
+				}
 				switch input.GetType() {
 				case ofctx.OpenFuncBinding:
 					input.Uri = input.ComponentName
-					funcErr = r.handler.AddBindingInvocationHandler(input.Uri, func(c context.Context, in *dapr.BindingEvent) (out []byte, err error) {
+					funcErr = r.handler.AddBindingInvocationHandler(input.Uri, r.pool.bindingHandler(func(c context.Context, in *dapr.BindingEvent) (out []byte, err error) {
+						if !r.tracker.Begin() {
+							return nil, runtime.ErrDraining
+						}
+						defer r.tracker.End()
+						r.recorder.Record(&runtime.RecordedEvent{
+							Kind:     runtime.RecordedBinding,
+							Name:     input.Uri,
+							Metadata: in.Metadata,
+							Data:     in.Data,
+						})
 						rm := runtime.NewRuntimeManager(ctx, prePlugins, postPlugins)
 						rm.FuncContext.SetEvent(name, in)
-						rm.FunctionRunWrapperWithHooks(fn)
+						if !input.MatchEvent(rm.FuncContext) {
+							klog.V(4).Infof("dropped the event not matching the filter of input %s", name)
+							return nil, nil
+						}
+						if err := input.ValidatePayload(rm.FuncContext.GetInnerEvent().GetUserData()); err != nil {
+							klog.Errorf("invalid payload for input %s: %v", name, err)
+							return nil, err
+						}
+						id := r.dedup.BindingKey(in.Metadata)
+						if r.dedup.Seen(name, id) {
+							klog.V(4).Infof("dropped the duplicate event %s of input %s", id, name)
+							return nil, nil
+						}
+						defer func() {
+							if err != nil {
+								r.dedup.Forget(name, id)
+							}
+						}()
+						key, processed := checkProcessed(rm)
+						if processed {
+							return nil, nil
+						}
+						runWithRetry(c, rm, name, input.GetRetryPolicy(), fn)
 
-						switch rm.FuncOut.GetCode() {
-						case ofctx.Success:
+						switch ofctx.GetStatusClass(rm.FuncOut.GetCode()) {
+						case ofctx.StatusOK:
+							markProcessed(rm, key)
+							return rm.FuncOut.GetData(), nil
+						case ofctx.StatusUnset:
 							return rm.FuncOut.GetData(), nil
-						case ofctx.InternalError:
-							return nil, rm.FuncContext.GetError()
 						default:
-							return nil, nil
+							return rm.FuncOut.GetData(), statusError(rm)
 						}
-					})
+					}))
 					if funcErr == nil {
 						klog.Infof("registered bindings handler: %s", input.Uri)
 					}
@@ -115,33 +316,125 @@ func (r *Runtime) RegisterOpenFunction(
 						PubsubName: input.ComponentName,
 						Topic:      input.Uri,
 					}
-					funcErr = r.handler.AddTopicEventHandler(sub, func(c context.Context, e *dapr.TopicEvent) (retry bool, err error) {
+					var locks *keyedMutex
+					if strings.EqualFold(input.Metadata[OrderedMetadataKey], "true") {
+						locks = newKeyedMutex()
+					}
+					funcErr = r.handler.AddTopicEventHandler(sub, r.pool.topicHandler(func(c context.Context, e *dapr.TopicEvent) (retry bool, err error) {
+						if !r.tracker.Begin() {
+							return true, runtime.ErrDraining
+						}
+						defer r.tracker.End()
+						r.recorder.Record(&runtime.RecordedEvent{
+							Kind:        runtime.RecordedTopic,
+							Name:        e.PubsubName,
+							Topic:       e.Topic,
+							ContentType: e.DataContentType,
+							Metadata: map[string]string{
+								"id":      e.ID,
+								"source":  e.Source,
+								"type":    e.Type,
+								"subject": e.Subject,
+							},
+							Data: e.RawData,
+						})
+						if locks != nil {
+							unlock := locks.Lock(orderingKey(input, e))
+							defer unlock()
+						}
 						rm := runtime.NewRuntimeManager(ctx, prePlugins, postPlugins)
 						rm.FuncContext.SetEvent(name, e)
-						rm.FunctionRunWrapperWithHooks(fn)
+						rm.FuncContext.SetTopicEventMetadata(ofctx.TopicEventMetadataFromContext(c))
+						if rm.FuncContext.DeliverReply() {
+							return false, nil
+						}
+						if !input.MatchEvent(rm.FuncContext) {
+							klog.V(4).Infof("dropped the event not matching the filter of input %s", name)
+							return false, nil
+						}
+						if err := input.ValidatePayload(rm.FuncContext.GetInnerEvent().GetUserData()); err != nil {
+							klog.Errorf("invalid payload for input %s: %v", name, err)
+							return false, err
+						}
+						if r.dedup.Seen(name, e.ID) {
+							klog.V(4).Infof("dropped the duplicate event %s of input %s", e.ID, name)
+							return false, nil
+						}
+						defer func() {
+							if err != nil {
+								r.dedup.Forget(name, e.ID)
+							}
+						}()
+						key, processed := checkProcessed(rm)
+						if processed {
+							return false, nil
+						}
+						runFunction(rm, fn)
+
+						if rm.FuncContext.IsRequeued() {
+							klog.V(4).Infof("acked the event %s requeued by the function", e.ID)
+							return false, nil
+						}
 
-						switch rm.FuncOut.GetCode() {
-						case ofctx.Success:
+						var routeErr *ofctx.RouteError
+						if errors.As(rm.FuncContext.GetError(), &routeErr) {
+							return routeEvent(rm, input, routeErr, e)
+						}
+
+						switch class := ofctx.GetStatusClass(rm.FuncOut.GetCode()); class {
+						case ofctx.StatusOK:
+							markProcessed(rm, key)
+							return false, nil
+						case ofctx.StatusUnset:
 							return false, nil
-						case ofctx.InternalError:
-							err = rm.FuncContext.GetError()
+						default:
+							err = statusError(rm)
 							if retry, ok := rm.FuncOut.GetMetadata()["retry"]; ok {
-								if strings.EqualFold(retry, "true") {
-									return true, err
-								} else if strings.EqualFold(retry, "false") {
-									return false, err
-								} else {
-									return false, err
-								}
+								return strings.EqualFold(retry, "true"), err
 							}
-							return false, err
-						default:
-							return false, nil
+							// nack the event aborted by a plugin or failed transiently so that it is redelivered
+							return errors.Is(err, plugin.ErrAbort) || class == ofctx.StatusRetryable, err
 						}
-					})
+					}))
 					if funcErr == nil {
 						klog.Infof("registered pubsub handler: %s, %s", input.ComponentName, input.Uri)
 					}
+				case ofctx.OpenFuncService:
+					funcErr = r.handler.AddServiceInvocationHandler(input.Uri, r.pool.serviceHandler(func(c context.Context, in *dapr.InvocationEvent) (out *dapr.Content, err error) {
+						if !r.tracker.Begin() {
+							return nil, runtime.ErrDraining
+						}
+						defer r.tracker.End()
+						r.recorder.Record(&runtime.RecordedEvent{
+							Kind:        runtime.RecordedService,
+							Name:        input.Uri,
+							Method:      in.Verb,
+							URL:         in.QueryString,
+							ContentType: in.ContentType,
+							Data:        in.Data,
+						})
+						rm := runtime.NewRuntimeManager(ctx, prePlugins, postPlugins)
+						rm.FuncContext.SetEvent(name, in)
+						if err := input.ValidatePayload(rm.FuncContext.GetInnerEvent().GetUserData()); err != nil {
+							klog.Errorf("invalid payload for input %s: %v", name, err)
+							return nil, err
+						}
+						runFunction(rm, fn)
+
+						content := &dapr.Content{
+							ContentType: in.ContentType,
+							Data:        rm.FuncOut.GetData(),
+						}
+						switch ofctx.GetStatusClass(rm.FuncOut.GetCode()) {
+						case ofctx.StatusOK, ofctx.StatusUnset:
+							return content, nil
+						default:
+							return content, statusError(rm)
+						}
+					}))
+					if funcErr == nil {
+						klog.Infof("registered service invocation handler: %s", input.Uri)
+					}
 				default:
 					return fmt.Errorf("invalid input type: %s", input.GetType())
 				}
@@ -154,6 +447,8 @@ func (r *Runtime) RegisterOpenFunction(
 					return funcErr
 				}
 			}
+			atomic.StoreInt32(&r.registered, 1)
+			klog.Infof("registered the handlers of all the inputs")
 			// If a function has no input, just return it.
 			return nil
 		}
@@ -163,10 +458,204 @@ func (r *Runtime) RegisterOpenFunction(
 	}(fn)
 }
 
+// runFunction runs the function with the hooks, the output streamed by the function is read in full
+// since dapr takes the data as bytes.
+func runFunction(rm *runtime.RuntimeManager, fn interface{}) {
+	rm.FunctionRunWrapperWithHooks(fn)
+
+	out := rm.FuncOut
+	if out == nil || out.GetReader() == nil {
+		return
+	}
+	reader := out.GetReader()
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	out.WithReader(nil)
+	if len(out.GetData()) > 0 {
+		return
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		klog.Errorf("failed to read function output: %v", err)
+		out.WithCode(ofctx.InternalError)
+		rm.FuncContext.WithError(fmt.Errorf("failed to read function output: %w", err))
+		return
+	}
+	out.WithData(data)
+}
+
+// runWithRetry runs the function, and retries it with backoff while it fails with an internal error or a transient failure
+// until the attempts are exhausted or the next retry would exceed the deadline.
+func runWithRetry(c context.Context, rm *runtime.RuntimeManager, inputName string, policy *ofctx.RetryPolicy, fn interface{}) {
+	runFunction(rm, fn)
+	if policy == nil {
+		return
+	}
+
+	deadline := time.Now().Add(policy.Deadline)
+	backoff := policy.Backoff
+	for attempt := 1; retryable(rm.FuncOut.GetCode()); attempt++ {
+		if attempt >= policy.MaxAttempts {
+			klog.Warningf("function failed on input %s after %d attempts: %v", inputName, attempt, rm.FuncContext.GetError())
+			return
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			klog.Warningf("function failed on input %s, no retry within the deadline %s: %v", inputName, policy.Deadline, rm.FuncContext.GetError())
+			return
+		}
+		klog.Warningf("function failed on input %s, retrying in %s: %v", inputName, backoff, rm.FuncContext.GetError())
+		select {
+		case <-c.Done():
+			return
+		case <-rm.FuncContext.GetNativeContext().Done():
+			klog.Warningf("function cancelled on input %s, no more retries: %v", inputName, rm.FuncContext.GetNativeContext().Err())
+			return
+		case <-time.After(backoff):
+		}
+		runFunction(rm, fn)
+		backoff = policy.NextBackoff(backoff)
+	}
+}
+
+// retryable detects if the function failed with an internal error or a transient failure.
+func retryable(code int) bool {
+	return code == ofctx.InternalError || ofctx.GetStatusClass(code) == ofctx.StatusRetryable
+}
+
+// routeEvent publishes the payload of the failed topic event to the topic of the route error,
+// the event is acknowledged once published and redelivered otherwise.
+func routeEvent(rm *runtime.RuntimeManager, input *ofctx.Input, routeErr *ofctx.RouteError, e *dapr.TopicEvent) (bool, error) {
+	if err := rm.FuncContext.PublishToTopic(input, routeErr.Topic, e.RawData); err != nil {
+		klog.Errorf("failed to route event %s to topic %s: %v", e.ID, routeErr.Topic, err)
+		return true, fmt.Errorf("failed to route event to topic %s: %w", routeErr.Topic, err)
+	}
+	klog.Warningf("routed event %s to topic %s: %v", e.ID, routeErr.Topic, routeErr.Err)
+	return false, nil
+}
+
+// statusError returns the error of the function failing with an error code,
+// which carries the output data when the function returns no error.
+func statusError(rm *runtime.RuntimeManager) error {
+	if err := rm.FuncContext.GetError(); err != nil {
+		return err
+	}
+	if data := rm.FuncOut.GetData(); len(data) > 0 {
+		return fmt.Errorf("function failed with status %d: %s", rm.FuncOut.GetCode(), data)
+	}
+	return fmt.Errorf("function failed with status %d", rm.FuncOut.GetCode())
+}
+
+// orderingKey returns the partition key of the topic event.
+func orderingKey(input *ofctx.Input, e *dapr.TopicEvent) string {
+	switch field := input.Metadata[OrderingKeyMetadataKey]; field {
+	case "":
+		return ""
+	case "source":
+		return e.Source
+	case "type":
+		return e.Type
+	default:
+		if data, ok := e.Data.(map[string]interface{}); ok {
+			if v, ok := data[field]; ok {
+				return fmt.Sprint(v)
+			}
+		}
+		return ""
+	}
+}
+
+// checkProcessed returns the idempotency key of the event and detects if the event has already been processed.
+// The event is processed when its key cannot be checked.
+func checkProcessed(rm *runtime.RuntimeManager) (string, bool) {
+	key := rm.FuncContext.GetIdempotencyKey()
+	if key == "" {
+		return "", false
+	}
+
+	processed, err := rm.FuncContext.IsProcessed(key)
+	if err != nil {
+		klog.Warningf("failed to check idempotency key %s: %v", key, err)
+		return key, false
+	}
+	if processed {
+		klog.Infof("skipped the event with the processed idempotency key %s", key)
+	}
+	return key, processed
+}
+
+func markProcessed(rm *runtime.RuntimeManager, key string) {
+	if key == "" {
+		return
+	}
+	if err := rm.FuncContext.MarkProcessed(key); err != nil {
+		klog.Warningf("failed to record idempotency key %s: %v", key, err)
+	}
+}
+
+// Replay delivers the events recorded to the directory through the test runtime in the order they were received,
+// it stops at the first event failing to be processed.
+func (r *Runtime) Replay(dir string) error {
+	if r.grpcHander == nil {
+		return errors.New("replaying the recorded events requires the test mode")
+	}
+	events, err := runtime.ReadRecordedEvents(dir)
+	if err != nil {
+		return err
+	}
+	c := context.Background()
+	for _, e := range events {
+		switch e.Kind {
+		case runtime.RecordedBinding:
+			_, err = r.grpcHander.OnBindingEvent(c, &pb.BindingEventRequest{
+				Name:     e.Name,
+				Data:     e.Data,
+				Metadata: e.Metadata,
+			})
+		case runtime.RecordedTopic:
+			_, err = r.grpcHander.OnTopicEvent(c, &pb.TopicEventRequest{
+				Id:              e.Metadata["id"],
+				Source:          e.Metadata["source"],
+				Type:            e.Metadata["type"],
+				SpecVersion:     "1.0",
+				DataContentType: e.ContentType,
+				Data:            e.Data,
+				Topic:           e.Topic,
+				PubsubName:      e.Name,
+			})
+		case runtime.RecordedService:
+			req := &cpb.InvokeRequest{
+				Method:      e.Name,
+				ContentType: e.ContentType,
+				Data:        &any.Any{Value: e.Data},
+			}
+			if verb, ok := cpb.HTTPExtension_Verb_value[e.Method]; ok {
+				req.HttpExtension = &cpb.HTTPExtension{
+					Verb:        cpb.HTTPExtension_Verb(verb),
+					Querystring: e.URL,
+				}
+			}
+			_, err = r.grpcHander.OnInvoke(c, req)
+		default:
+			klog.Warningf("skipped the recorded %s event not served by the async runtime", e.Kind)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replay the recorded %s event %s: %w", e.Kind, e.Name, err)
+		}
+	}
+	return nil
+}
+
 func (r *Runtime) Name() ofctx.Runtime {
 	return ofctx.Async
 }
 
+// GetHandler returns the fake dapr server in test mode, or else the input adapter.
 func (r *Runtime) GetHandler() interface{} {
-	return r.grpcHander
+	if r.grpcHander != nil {
+		return r.grpcHander
+	}
+	return r.handler
 }
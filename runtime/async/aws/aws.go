@@ -0,0 +1,317 @@
+// Package aws polls the inputs of the function from AWS SQS and publishes its outputs to AWS SNS or SQS, without dapr.
+//
+// The adapter is backed by a Client wrapping the AWS SDK, and it is configured by the metadata of the inputs:
+// `queueUrl`, `region`, `visibilityTimeout`, `waitTime` and `maxMessages`, and of the outputs: `region`,
+// and `topicArn` to publish to SNS or `queueUrl` to send to SQS.
+//
+// A received message stays invisible to the other consumers for the visibility timeout. The processed messages
+// are deleted, and so are the failed messages the framework has already retried: the topic events not asking
+// for a retry, and the binding events whose input has a retry policy. The other failed messages are left
+// to reappear once their visibility timeout expires, so that they are redelivered.
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+const (
+	// SQSComponentType is the component type of the sqs outputs sent by the Sender.
+	SQSComponentType = "bindings.aws.sqs"
+	// SNSComponentType is the component type of the sns outputs sent by the Sender.
+	SNSComponentType = "bindings.aws.sns"
+
+	QueueURLMetadataKey          = "queueUrl"
+	TopicARNMetadataKey          = "topicArn"
+	RegionMetadataKey            = "region"
+	VisibilityTimeoutMetadataKey = "visibilityTimeout"
+	WaitTimeMetadataKey          = "waitTime"
+	MaxMessagesMetadataKey       = "maxMessages"
+
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultWaitTime          = 20 * time.Second
+	defaultMaxMessages       = 10
+	maxMaxMessages           = 10
+
+	// receiveBackoff is the delay before receiving again after failing to receive the messages.
+	receiveBackoff = time.Second
+)
+
+// Message is a message received from an sqs queue.
+type Message struct {
+	MessageID     string
+	ReceiptHandle string
+	Body          []byte
+	Attributes    map[string]string
+}
+
+// Client receives, deletes and sends the sqs messages and publishes the sns messages, it wraps the AWS SDK.
+type Client interface {
+	// ReceiveMessages long polls up to max messages of the queue for the wait time,
+	// hiding the received messages from the other consumers for the visibility timeout.
+	ReceiveMessages(ctx context.Context, region, queueURL string, max int, waitTime, visibilityTimeout time.Duration) ([]*Message, error)
+	// DeleteMessage deletes the received message from the queue.
+	DeleteMessage(ctx context.Context, region, queueURL, receiptHandle string) error
+	// SendMessage sends the message to the queue.
+	SendMessage(ctx context.Context, region, queueURL string, body []byte) error
+	// Publish publishes the message to the topic.
+	Publish(ctx context.Context, region, topicARN string, body []byte) error
+}
+
+// config is the sqs configuration of an input.
+type config struct {
+	region            string
+	queueURL          string
+	visibilityTimeout time.Duration
+	waitTime          time.Duration
+	maxMessages       int
+	// terminate deletes the failed binding events already retried by the framework
+	terminate bool
+}
+
+// subscription is a queue polled for the handler of an input, the handler reports if the message is to be deleted.
+type subscription struct {
+	config
+	handle func(context.Context, *Message) bool
+}
+
+// Adapter is the input adapter polling the inputs of the function from sqs.
+type Adapter struct {
+	client        Client
+	mu            sync.Mutex
+	configs       map[string]config
+	subscriptions []subscription
+	cancel        context.CancelFunc
+	stopped       bool
+}
+
+var _ async.InputAdapter = &Adapter{}
+var _ async.InputConfigurable = &Adapter{}
+var _ ofctx.OutputSender = &Sender{}
+
+// NewAdapter creates the input adapter polling with the client.
+func NewAdapter(client Client) *Adapter {
+	return &Adapter{
+		client:  client,
+		configs: map[string]config{},
+	}
+}
+
+// ConfigureInput reads the sqs configuration from the metadata of the input.
+func (a *Adapter) ConfigureInput(name string, input *ofctx.Input) error {
+	c := config{
+		region:            input.Metadata[RegionMetadataKey],
+		queueURL:          input.Metadata[QueueURLMetadataKey],
+		visibilityTimeout: defaultVisibilityTimeout,
+		waitTime:          defaultWaitTime,
+		maxMessages:       defaultMaxMessages,
+	}
+	if c.queueURL == "" {
+		return fmt.Errorf("metadata %s is required for sqs input %s", QueueURLMetadataKey, name)
+	}
+	if c.region == "" {
+		return fmt.Errorf("metadata %s is required for sqs input %s", RegionMetadataKey, name)
+	}
+	if v, ok := input.Metadata[VisibilityTimeoutMetadataKey]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid metadata %s of sqs input %s: %s", VisibilityTimeoutMetadataKey, name, v)
+		}
+		c.visibilityTimeout = d
+	}
+	if v, ok := input.Metadata[WaitTimeMetadataKey]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid metadata %s of sqs input %s: %s", WaitTimeMetadataKey, name, v)
+		}
+		c.waitTime = d
+	}
+	if v, ok := input.Metadata[MaxMessagesMetadataKey]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMaxMessages {
+			return fmt.Errorf("invalid metadata %s of sqs input %s: %s, it must be between 1 and %d", MaxMessagesMetadataKey, name, v, maxMaxMessages)
+		}
+		c.maxMessages = n
+	}
+
+	var key string
+	switch input.GetType() {
+	case ofctx.OpenFuncTopic:
+		key = topicKey(input.ComponentName, input.Uri)
+	case ofctx.OpenFuncBinding:
+		key = input.ComponentName
+		c.terminate = input.GetRetryPolicy() != nil
+	default:
+		return fmt.Errorf("sqs input %s must be a binding or a topic, got %s", name, input.ComponentType)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configs[key] = c
+	return nil
+}
+
+// AddBindingInvocationHandler polls the queue of the binding input.
+func (a *Adapter) AddBindingInvocationHandler(name string, fn dapr.BindingInvocationHandler) error {
+	return a.subscribe(name, func(c config) func(context.Context, *Message) bool {
+		return func(ctx context.Context, msg *Message) bool {
+			if _, err := fn(ctx, &dapr.BindingEvent{Data: msg.Body, Metadata: msg.Attributes}); err != nil {
+				if c.terminate {
+					klog.Warningf("deleted failed sqs message %s: %v", msg.MessageID, err)
+					return true
+				}
+				return false
+			}
+			return true
+		}
+	})
+}
+
+// AddTopicEventHandler polls the queue subscribed to the topic of the input.
+func (a *Adapter) AddTopicEventHandler(sub *dapr.Subscription, fn dapr.TopicEventHandler) error {
+	return a.subscribe(topicKey(sub.PubsubName, sub.Topic), func(c config) func(context.Context, *Message) bool {
+		return func(ctx context.Context, msg *Message) bool {
+			retry, err := fn(ctx, &dapr.TopicEvent{
+				ID:         msg.MessageID,
+				Topic:      sub.Topic,
+				PubsubName: sub.PubsubName,
+				Data:       msg.Body,
+				RawData:    msg.Body,
+			})
+			if err != nil && !retry {
+				klog.Warningf("deleted failed sqs message %s: %v", msg.MessageID, err)
+			}
+			return err == nil || !retry
+		}
+	})
+}
+
+// AddServiceInvocationHandler is not supported, the service invocations are only served through dapr.
+func (a *Adapter) AddServiceInvocationHandler(name string, fn dapr.ServiceInvocationHandler) error {
+	return errors.New("sqs adapter cannot serve service invocations")
+}
+
+func (a *Adapter) subscribe(key string, handler func(config) func(context.Context, *Message) bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.configs[key]
+	if !ok {
+		return fmt.Errorf("sqs input %s is not configured", key)
+	}
+	a.subscriptions = append(a.subscriptions, subscription{config: c, handle: handler(c)})
+	return nil
+}
+
+// Start polls the queues until the adapter is stopped.
+func (a *Adapter) Start() error {
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	subscriptions := a.subscriptions
+	a.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range subscriptions {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.poll(ctx, s)
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	return nil
+}
+
+// poll receives the messages of the queue and handles them one by one until the context is done.
+func (a *Adapter) poll(ctx context.Context, s subscription) {
+	for ctx.Err() == nil {
+		msgs, err := a.client.ReceiveMessages(ctx, s.region, s.queueURL, s.maxMessages, s.waitTime, s.visibilityTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			klog.Warningf("failed to receive sqs messages of queue %s, retrying in %s: %v", s.queueURL, receiveBackoff, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(receiveBackoff):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			// the message left in the queue is redelivered once its visibility timeout expires
+			if !s.handle(ctx, msg) {
+				continue
+			}
+			if err := a.client.DeleteMessage(ctx, s.region, s.queueURL, msg.ReceiptHandle); err != nil {
+				klog.Errorf("failed to delete sqs message %s: %v", msg.MessageID, err)
+			}
+		}
+	}
+}
+
+// Stop stops polling the queues.
+func (a *Adapter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopped = true
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return nil
+}
+
+// Sender is the output sender publishing the data sent to the outputs to sns, or sending it to sqs.
+type Sender struct {
+	client Client
+}
+
+// NewSender creates the output sender publishing and sending with the client.
+func NewSender(client Client) *Sender {
+	return &Sender{client: client}
+}
+
+// SendOutput publishes the data to the sns topic of the output if its topic arn is set,
+// or else sends it to the sqs queue of the output.
+func (s *Sender) SendOutput(c context.Context, output *ofctx.Output, data []byte) (*ofctx.BindingResult, error) {
+	region := output.Metadata[RegionMetadataKey]
+	if region == "" {
+		return nil, fmt.Errorf("metadata %s is required for aws output %s", RegionMetadataKey, output.ComponentName)
+	}
+
+	var err error
+	if topicARN := output.Metadata[TopicARNMetadataKey]; topicARN != "" {
+		err = s.client.Publish(c, region, topicARN, data)
+	} else if queueURL := output.Metadata[QueueURLMetadataKey]; queueURL != "" {
+		err = s.client.SendMessage(c, region, queueURL, data)
+	} else {
+		err = fmt.Errorf("metadata %s or %s is required for aws output %s", TopicARNMetadataKey, QueueURLMetadataKey, output.ComponentName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ofctx.BindingResult{}, nil
+}
+
+func topicKey(pubsubName, topic string) string {
+	return pubsubName + "/" + topic
+}
@@ -0,0 +1,276 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/framework"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+// mockQueue is an sqs queue in memory.
+type mockQueue struct {
+	messages []*Message
+	notify   chan struct{}
+	drained  chan struct{}
+}
+
+// mockClient records the received, deleted, sent and published messages.
+type mockClient struct {
+	mu        sync.Mutex
+	queues    map[string]*mockQueue
+	deleted   []string
+	sent      map[string][][]byte
+	published map[string][][]byte
+}
+
+func newMockClient(queueURLs ...string) *mockClient {
+	c := &mockClient{
+		queues:    map[string]*mockQueue{},
+		sent:      map[string][][]byte{},
+		published: map[string][][]byte{},
+	}
+	for _, url := range queueURLs {
+		c.queues[url] = &mockQueue{notify: make(chan struct{}, 1), drained: make(chan struct{}, 1)}
+	}
+	return c
+}
+
+func (c *mockClient) ReceiveMessages(ctx context.Context, region, queueURL string, max int, waitTime, visibilityTimeout time.Duration) ([]*Message, error) {
+	q, ok := c.queues[queueURL]
+	if !ok {
+		return nil, fmt.Errorf("queue %s not found", queueURL)
+	}
+	for {
+		c.mu.Lock()
+		if len(q.messages) > 0 {
+			n := len(q.messages)
+			if n > max {
+				n = max
+			}
+			batch := q.messages[:n]
+			q.messages = q.messages[n:]
+			c.mu.Unlock()
+			return batch, nil
+		}
+		c.mu.Unlock()
+
+		// the previous messages have been handled once the queue is polled again
+		select {
+		case q.drained <- struct{}{}:
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.notify:
+		}
+	}
+}
+
+func (c *mockClient) DeleteMessage(ctx context.Context, region, queueURL, receiptHandle string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, receiptHandle)
+	return nil
+}
+
+func (c *mockClient) SendMessage(ctx context.Context, region, queueURL string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent[queueURL] = append(c.sent[queueURL], body)
+	return nil
+}
+
+func (c *mockClient) Publish(ctx context.Context, region, topicARN string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published[topicARN] = append(c.published[topicARN], body)
+	return nil
+}
+
+// waitDrained waits until the queue is polled with no messages left.
+func (c *mockClient) waitDrained(t *testing.T, queueURL string) {
+	select {
+	case <-c.queues[queueURL].drained:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("queue %s not polled", queueURL)
+	}
+}
+
+// deliver enqueues the messages and waits until they are handled.
+func (c *mockClient) deliver(t *testing.T, queueURL string, msgs ...*Message) {
+	q := c.queues[queueURL]
+	c.mu.Lock()
+	q.messages = append(q.messages, msgs...)
+	c.mu.Unlock()
+	q.notify <- struct{}{}
+	c.waitDrained(t, queueURL)
+}
+
+func TestSQSPollAndPublish(t *testing.T) {
+	const (
+		ordersQueue = "https://sqs.us-east-1.amazonaws.com/123456789012/orders"
+		eventsQueue = "https://sqs.us-east-1.amazonaws.com/123456789012/events"
+		auditQueue  = "https://sqs.us-east-1.amazonaws.com/123456789012/audit"
+		topicARN    = "arn:aws:sns:us-east-1:123456789012:shipments"
+	)
+	client := newMockClient(ordersQueue, eventsQueue)
+	async.SetInputAdapterFactory(func(port string) (async.InputAdapter, error) {
+		return NewAdapter(client), nil
+	})
+	defer async.SetInputAdapterFactory(nil)
+
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, fmt.Sprintf(`{
+  "name": "function-sqs",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50042",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "snssqs",
+      "componentType": "pubsub.snssqs",
+      "metadata": {
+        "queueUrl": %q,
+        "region": "us-east-1",
+        "visibilityTimeout": "1m"
+      }
+    },
+    "events": {
+      "componentName": "events",
+      "componentType": "bindings.aws.sqs",
+      "metadata": {
+        "queueUrl": %q,
+        "region": "us-east-1",
+        "retryMaxAttempts": "2",
+        "retryBackoff": "1ms"
+      }
+    }
+  },
+  "outputs": {
+    "shipments": {
+      "componentName": "shipments",
+      "componentType": "bindings.aws.sns",
+      "metadata": {
+        "region": "us-east-1",
+        "topicArn": %q
+      }
+    },
+    "audit": {
+      "componentName": "audit",
+      "componentType": "bindings.aws.sqs",
+      "metadata": {
+        "region": "us-east-1",
+        "queueUrl": %q
+      }
+    }
+  }
+}`, ordersQueue, eventsQueue, topicARN, auditQueue))
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	sender := NewSender(client)
+	fwk, err := framework.NewFramework(
+		ofctx.WithOutputSender(SNSComponentType, sender),
+		ofctx.WithOutputSender(SQSComponentType, sender),
+	)
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		switch string(in) {
+		case "unavailable":
+			return ctx.ReturnOnInternalError().WithCode(503), errors.New("unavailable")
+		case "fail":
+			return ctx.ReturnOnInternalError(), errors.New("failed to process")
+		}
+		if _, err := ctx.Send("shipments", in); err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		if _, err := ctx.Send("audit", in); err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	c, cancel := context.WithCancel(context.Background())
+	if err := fwk.Register(c, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fwk.Start(c)
+	}()
+	client.waitDrained(t, ordersQueue)
+	client.waitDrained(t, eventsQueue)
+
+	client.deliver(t, ordersQueue,
+		&Message{MessageID: "1", ReceiptHandle: "order-ok", Body: []byte("order-1")},
+		// the transient failures are left to be redelivered after the visibility timeout
+		&Message{MessageID: "2", ReceiptHandle: "order-unavailable", Body: []byte("unavailable")},
+		&Message{MessageID: "3", ReceiptHandle: "order-fail", Body: []byte("fail")},
+	)
+	client.deliver(t, eventsQueue,
+		&Message{MessageID: "4", ReceiptHandle: "event-ok", Body: []byte("event-1")},
+		// the binding events are retried by the framework with the retry policy of the input
+		&Message{MessageID: "5", ReceiptHandle: "event-fail", Body: []byte("fail")},
+	)
+
+	client.mu.Lock()
+	assert.ElementsMatch(t, []string{"order-ok", "order-fail", "event-ok", "event-fail"}, client.deleted)
+	assert.Len(t, client.published[topicARN], 2)
+	assert.Len(t, client.sent[auditQueue], 2)
+	client.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sqs adapter not stopped")
+	}
+}
+
+func TestSQSInputConfig(t *testing.T) {
+	a := NewAdapter(newMockClient())
+	metadata := func(kv ...string) map[string]string {
+		m := map[string]string{QueueURLMetadataKey: "https://sqs/queue", RegionMetadataKey: "us-east-1"}
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+
+	for name, input := range map[string]*ofctx.Input{
+		"no-queue":           {ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: map[string]string{RegionMetadataKey: "us-east-1"}},
+		"no-region":          {ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: map[string]string{QueueURLMetadataKey: "https://sqs/queue"}},
+		"visibility-timeout": {ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: metadata(VisibilityTimeoutMetadataKey, "0s")},
+		"wait-time":          {ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: metadata(WaitTimeMetadataKey, "soon")},
+		"max-messages":       {ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: metadata(MaxMessagesMetadataKey, "11")},
+	} {
+		assert.Error(t, a.ConfigureInput(name, input), name)
+	}
+
+	if assert.NoError(t, a.ConfigureInput("sqs", &ofctx.Input{ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: metadata(MaxMessagesMetadataKey, "5")})) {
+		assert.Equal(t, 5, a.configs["sqs"].maxMessages)
+		assert.Equal(t, defaultVisibilityTimeout, a.configs["sqs"].visibilityTimeout)
+	}
+}
+
+func TestSQSSendWithoutTarget(t *testing.T) {
+	s := NewSender(newMockClient())
+	_, err := s.SendOutput(context.Background(), &ofctx.Output{ComponentName: "sqs", ComponentType: SQSComponentType, Metadata: map[string]string{RegionMetadataKey: "us-east-1"}}, []byte("hello"))
+	assert.Error(t, err)
+}
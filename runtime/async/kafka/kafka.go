@@ -0,0 +1,255 @@
+// Package kafka consumes the inputs and produces the outputs of the function directly from kafka, without dapr.
+//
+// The adapter is backed by a Client wrapping the kafka library of choice, and it is configured by the metadata
+// of the inputs and outputs: `brokers` (comma-separated), `consumerGroup` and `topic`.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+const (
+	// ComponentType is the component type of the kafka outputs sent by the Sender.
+	ComponentType = "pubsub.kafka"
+
+	BrokersMetadataKey       = "brokers"
+	ConsumerGroupMetadataKey = "consumerGroup"
+	TopicMetadataKey         = "topic"
+	KeyMetadataKey           = "key"
+)
+
+// Message is a message consumed from or produced to a kafka topic.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// Client consumes and produces the kafka messages, it wraps the kafka library of choice.
+type Client interface {
+	// Consume consumes the topic as a member of the consumer group until the context is done,
+	// the message is committed once the handler returns nil, and redelivered otherwise.
+	Consume(ctx context.Context, brokers []string, groupID string, topic string, handler func(context.Context, *Message) error) error
+	// Produce produces the message to its topic.
+	Produce(ctx context.Context, brokers []string, msg *Message) error
+}
+
+// config is the kafka configuration of an input.
+type config struct {
+	brokers []string
+	groupID string
+	topic   string
+}
+
+// subscription is a topic consumed for the handler of an input.
+type subscription struct {
+	config
+	handle func(context.Context, *Message) error
+}
+
+// Adapter is the input adapter consuming the inputs of the function from kafka.
+type Adapter struct {
+	client        Client
+	mu            sync.Mutex
+	configs       map[string]config
+	subscriptions []subscription
+	cancel        context.CancelFunc
+	stopped       bool
+}
+
+var _ async.InputAdapter = &Adapter{}
+var _ async.InputConfigurable = &Adapter{}
+var _ ofctx.OutputSender = &Sender{}
+
+// NewAdapter creates the input adapter consuming with the client.
+func NewAdapter(client Client) *Adapter {
+	return &Adapter{
+		client:  client,
+		configs: map[string]config{},
+	}
+}
+
+// ConfigureInput reads the kafka configuration from the metadata of the input.
+func (a *Adapter) ConfigureInput(name string, input *ofctx.Input) error {
+	brokers := parseBrokers(input.Metadata)
+	if len(brokers) == 0 {
+		return fmt.Errorf("metadata %s is required for kafka input %s", BrokersMetadataKey, name)
+	}
+	groupID := input.Metadata[ConsumerGroupMetadataKey]
+	if groupID == "" {
+		return fmt.Errorf("metadata %s is required for kafka input %s", ConsumerGroupMetadataKey, name)
+	}
+
+	c := config{brokers: brokers, groupID: groupID}
+	var key string
+	switch input.GetType() {
+	case ofctx.OpenFuncTopic:
+		key = topicKey(input.ComponentName, input.Uri)
+		c.topic = input.Uri
+	case ofctx.OpenFuncBinding:
+		key = input.ComponentName
+		if c.topic = input.Metadata[TopicMetadataKey]; c.topic == "" {
+			c.topic = input.ComponentName
+		}
+	default:
+		return fmt.Errorf("kafka input %s must be a binding or a topic, got %s", name, input.ComponentType)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configs[key] = c
+	return nil
+}
+
+// AddBindingInvocationHandler consumes the topic of the binding input, the failed events are redelivered.
+func (a *Adapter) AddBindingInvocationHandler(name string, fn dapr.BindingInvocationHandler) error {
+	return a.subscribe(name, func(c context.Context, msg *Message) error {
+		_, err := fn(c, &dapr.BindingEvent{Data: msg.Value, Metadata: msg.Headers})
+		return err
+	})
+}
+
+// AddTopicEventHandler consumes the topic, the failed events are redelivered if the handler asks for a retry.
+func (a *Adapter) AddTopicEventHandler(sub *dapr.Subscription, fn dapr.TopicEventHandler) error {
+	return a.subscribe(topicKey(sub.PubsubName, sub.Topic), func(c context.Context, msg *Message) error {
+		c = ofctx.WithTopicEventMetadata(c, map[string]string{
+			ofctx.TopicPartitionMetadataKey: strconv.Itoa(int(msg.Partition)),
+			ofctx.TopicOffsetMetadataKey:    strconv.FormatInt(msg.Offset, 10),
+		})
+		retry, err := fn(c, &dapr.TopicEvent{
+			ID:         fmt.Sprintf("%s-%d-%d", msg.Topic, msg.Partition, msg.Offset),
+			Topic:      msg.Topic,
+			PubsubName: sub.PubsubName,
+			Data:       msg.Value,
+			RawData:    msg.Value,
+		})
+		if err != nil && !retry {
+			klog.Warningf("dropped kafka message of topic %s at offset %d: %v", msg.Topic, msg.Offset, err)
+			return nil
+		}
+		return err
+	})
+}
+
+// AddServiceInvocationHandler is not supported, the service invocations are only served through dapr.
+func (a *Adapter) AddServiceInvocationHandler(name string, fn dapr.ServiceInvocationHandler) error {
+	return errors.New("kafka adapter cannot serve service invocations")
+}
+
+func (a *Adapter) subscribe(key string, handle func(context.Context, *Message) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.configs[key]
+	if !ok {
+		return fmt.Errorf("kafka input %s is not configured", key)
+	}
+	a.subscriptions = append(a.subscriptions, subscription{config: c, handle: handle})
+	return nil
+}
+
+// Start consumes the subscribed topics until the adapter is stopped or a consumer fails.
+func (a *Adapter) Start() error {
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	subscriptions := a.subscriptions
+	a.mu.Unlock()
+
+	errs := make(chan error, len(subscriptions))
+	var wg sync.WaitGroup
+	for _, s := range subscriptions {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.client.Consume(ctx, s.brokers, s.groupID, s.topic, s.handle); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("failed to consume kafka topic %s: %v", s.topic, err)
+				cancel()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Stop stops consuming the topics.
+func (a *Adapter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopped = true
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return nil
+}
+
+// Sender is the output sender producing the data sent to the kafka outputs.
+type Sender struct {
+	client Client
+}
+
+// NewSender creates the output sender producing with the client.
+func NewSender(client Client) *Sender {
+	return &Sender{client: client}
+}
+
+// SendOutput produces the data to the topic of the output, the uri of the output unless the topic metadata is set.
+func (s *Sender) SendOutput(c context.Context, output *ofctx.Output, data []byte) (*ofctx.BindingResult, error) {
+	brokers := parseBrokers(output.Metadata)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("metadata %s is required for kafka output %s", BrokersMetadataKey, output.ComponentName)
+	}
+
+	msg := &Message{Topic: output.Metadata[TopicMetadataKey], Value: data}
+	if msg.Topic == "" {
+		msg.Topic = output.Uri
+	}
+	if key := output.Metadata[KeyMetadataKey]; key != "" {
+		msg.Key = []byte(key)
+	}
+	if err := s.client.Produce(c, brokers, msg); err != nil {
+		return nil, err
+	}
+	return &ofctx.BindingResult{}, nil
+}
+
+func parseBrokers(metadata map[string]string) []string {
+	var brokers []string
+	for _, broker := range strings.Split(metadata[BrokersMetadataKey], ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return brokers
+}
+
+func topicKey(pubsubName, topic string) string {
+	return pubsubName + "/" + topic
+}
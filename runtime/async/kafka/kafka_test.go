@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/framework"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+// mockClient records the consumers and the produced messages.
+type mockClient struct {
+	mu        sync.Mutex
+	handlers  map[string]func(context.Context, *Message) error
+	groups    map[string]string
+	consuming chan string
+	produced  []*Message
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{
+		handlers:  map[string]func(context.Context, *Message) error{},
+		groups:    map[string]string{},
+		consuming: make(chan string, 10),
+	}
+}
+
+func (c *mockClient) Consume(ctx context.Context, brokers []string, groupID string, topic string, handler func(context.Context, *Message) error) error {
+	c.mu.Lock()
+	c.handlers[topic] = handler
+	c.groups[topic] = groupID
+	c.mu.Unlock()
+	c.consuming <- topic
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *mockClient) Produce(ctx context.Context, brokers []string, msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.produced = append(c.produced, msg)
+	return nil
+}
+
+// deliver delivers the message to the consumer of its topic, and returns nil if the message is committed.
+func (c *mockClient) deliver(msg *Message) error {
+	c.mu.Lock()
+	handler, ok := c.handlers[msg.Topic]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("topic %s not consumed", msg.Topic)
+	}
+	return handler(context.Background(), msg)
+}
+
+func (c *mockClient) waitConsuming(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.consuming:
+		case <-time.After(5 * time.Second):
+			t.Fatal("topic not consumed")
+		}
+	}
+}
+
+// userData returns the user data of the cloudevent produced to a pubsub output.
+func userData(t *testing.T, value []byte) string {
+	event := cloudevents.NewEvent()
+	if err := json.Unmarshal(value, &event); err != nil {
+		t.Fatalf("failed to unmarshal produced event: %v", err)
+	}
+	var data struct {
+		UserData []byte `json:"userData"`
+	}
+	if err := json.Unmarshal(event.Data(), &data); err != nil {
+		t.Fatalf("failed to unmarshal produced event data: %v", err)
+	}
+	return string(data.UserData)
+}
+
+func TestKafkaConsumeAndProduce(t *testing.T) {
+	client := newMockClient()
+	async.SetInputAdapterFactory(func(port string) (async.InputAdapter, error) {
+		return NewAdapter(client), nil
+	})
+	defer async.SetInputAdapterFactory(nil)
+
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-kafka",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50040",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "kafka",
+      "componentType": "pubsub.kafka",
+      "metadata": {
+        "brokers": "localhost:9092, localhost:9093",
+        "consumerGroup": "function-kafka"
+      }
+    },
+    "events": {
+      "componentName": "events",
+      "componentType": "bindings.kafka",
+      "metadata": {
+        "brokers": "localhost:9092",
+        "consumerGroup": "function-kafka",
+        "topic": "raw-events"
+      }
+    }
+  },
+  "outputs": {
+    "shipments": {
+      "uri": "shipments",
+      "componentName": "kafka",
+      "componentType": "pubsub.kafka",
+      "metadata": {
+        "brokers": "localhost:9092",
+        "key": "order"
+      }
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	fwk, err := framework.NewFramework(ofctx.WithOutputSender(ComponentType, NewSender(client)))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	fwk.RegisterPlugins(nil)
+
+	var positions []string
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		if ctx.GetTopicEvent() != nil {
+			partition, _ := ctx.GetTopicPartition()
+			offset, _ := ctx.GetTopicOffset()
+			positions = append(positions, fmt.Sprintf("%d/%d", partition, offset))
+		}
+		if string(in) == "fail" {
+			return ctx.ReturnOnInternalError(), errors.New("failed to process")
+		}
+		if _, err := ctx.Send("shipments", in); err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	c, cancel := context.WithCancel(context.Background())
+	if err := fwk.Register(c, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fwk.Start(c)
+	}()
+	client.waitConsuming(t, 2)
+	assert.Equal(t, "function-kafka", client.groups["orders"])
+
+	// consumed messages are committed once processed, and produced to the output
+	assert.NoError(t, client.deliver(&Message{Topic: "orders", Partition: 2, Offset: 1, Value: []byte("order-1")}))
+	assert.NoError(t, client.deliver(&Message{Topic: "raw-events", Offset: 1, Value: []byte("event-1")}))
+
+	// failed binding events are not committed so that they are redelivered
+	assert.Error(t, client.deliver(&Message{Topic: "raw-events", Offset: 2, Value: []byte("fail")}))
+
+	// the partition and the offset of the topic events are surfaced on the context
+	assert.Equal(t, []string{"2/1"}, positions)
+
+	client.mu.Lock()
+	if assert.Len(t, client.produced, 2) {
+		assert.Equal(t, "shipments", client.produced[0].Topic)
+		assert.Equal(t, []byte("order"), client.produced[0].Key)
+		assert.Equal(t, "order-1", userData(t, client.produced[0].Value))
+		assert.Equal(t, "event-1", userData(t, client.produced[1].Value))
+	}
+	client.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("kafka adapter not stopped")
+	}
+}
+
+func TestKafkaInputConfig(t *testing.T) {
+	a := NewAdapter(newMockClient())
+
+	for name, input := range map[string]*ofctx.Input{
+		"no-brokers": {ComponentName: "kafka", ComponentType: "pubsub.kafka", Metadata: map[string]string{ConsumerGroupMetadataKey: "group"}},
+		"no-group":   {ComponentName: "kafka", ComponentType: "pubsub.kafka", Metadata: map[string]string{BrokersMetadataKey: "localhost:9092"}},
+		"service":    {ComponentName: "kafka", ComponentType: "service", Metadata: map[string]string{BrokersMetadataKey: "localhost:9092", ConsumerGroupMetadataKey: "group"}},
+	} {
+		assert.Error(t, a.ConfigureInput(name, input), name)
+	}
+
+	err := a.AddBindingInvocationHandler("absent", nil)
+	assert.Error(t, err)
+}
+
+func TestKafkaSendWithoutBrokers(t *testing.T) {
+	s := NewSender(newMockClient())
+	_, err := s.SendOutput(context.Background(), &ofctx.Output{Uri: "shipments", ComponentName: "kafka", ComponentType: ComponentType}, []byte("hello"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package async
+
+import (
+	"sync"
+)
+
+// keyedMutex serializes the holders of the same key in the order they call Lock,
+// while the holders of different keys run in parallel.
+type keyedMutex struct {
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{
+		waiters: map[string][]chan struct{}{},
+	}
+}
+
+// Lock blocks until the key is released by the previous holders, and returns the function releasing it.
+func (m *keyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	queue, held := m.waiters[key]
+	ch := make(chan struct{})
+	m.waiters[key] = append(queue, ch)
+	m.mu.Unlock()
+
+	if held {
+		<-ch
+	}
+	return func() {
+		m.unlock(key)
+	}
+}
+
+func (m *keyedMutex) unlock(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := m.waiters[key][1:]
+	if len(queue) == 0 {
+		delete(m.waiters, key)
+		return
+	}
+	m.waiters[key] = queue
+	// hand the key over to the next holder
+	close(queue[0])
+}
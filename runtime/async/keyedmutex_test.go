@@ -0,0 +1,80 @@
+package async
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutexOrder(t *testing.T) {
+	m := newKeyedMutex()
+
+	var mu sync.Mutex
+	var order []int
+	unlock := m.Lock("orders")
+
+	// the holders of the same key run in the order they called Lock
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			release := m.Lock("orders")
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		}(i)
+		// wait for the holder to queue before starting the next one
+		for {
+			m.mu.Lock()
+			n := len(m.waiters["orders"])
+			m.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	mu.Lock()
+	if len(order) != 0 {
+		t.Fatalf("got holders %v running while the key is held", order)
+	}
+	mu.Unlock()
+
+	unlock()
+	wg.Wait()
+	for i, got := range order {
+		if got != i+1 {
+			t.Fatalf("got holders in order %v, want them in the order of Lock", order)
+		}
+	}
+
+	// the key is forgotten once it is released by its last holder
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.waiters) != 0 {
+		t.Fatalf("got %d keys left after release, want none", len(m.waiters))
+	}
+}
+
+func TestKeyedMutexParallel(t *testing.T) {
+	m := newKeyedMutex()
+
+	unlock := m.Lock("orders")
+	defer unlock()
+
+	// the holders of another key are not blocked
+	locked := make(chan struct{})
+	go func() {
+		release := m.Lock("payments")
+		release()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("the holder of another key was blocked")
+	}
+}
@@ -0,0 +1,284 @@
+// Package nats subscribes to the inputs and publishes the outputs of the function directly with nats, without dapr.
+//
+// The adapter is backed by a Client wrapping the nats library, and it is configured by the metadata of the inputs
+// and outputs: `servers` (comma-separated), `subject`, `queueGroup` and `jetStream`.
+//
+// With JetStream, the processed messages are acked, and the failed messages are nacked to be redelivered,
+// or terminated when the framework has already retried them: the topic events not asking for a retry,
+// and the binding events whose input has a retry policy.
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+const (
+	// ComponentType is the component type of the nats outputs sent by the Sender.
+	ComponentType = "pubsub.nats"
+
+	ServersMetadataKey    = "servers"
+	SubjectMetadataKey    = "subject"
+	QueueGroupMetadataKey = "queueGroup"
+	JetStreamMetadataKey  = "jetStream"
+)
+
+// Ack is the acknowledgement of a JetStream message, the core nats messages are not acknowledged.
+type Ack int
+
+const (
+	// AckOK acknowledges the processed message.
+	AckOK Ack = iota
+	// AckNak negatively acknowledges the failed message so that it is redelivered.
+	AckNak
+	// AckTerm terminates the failed message so that it is not redelivered.
+	AckTerm
+)
+
+// Msg is a message received from or published to a nats subject.
+type Msg struct {
+	Subject  string
+	Sequence uint64
+	Data     []byte
+	Header   map[string]string
+}
+
+// Client subscribes and publishes the nats messages, it wraps the nats library.
+type Client interface {
+	// Subscribe receives the messages of the subject, load balanced within the optional queue group,
+	// until the context is done. The JetStream messages are acknowledged with the ack returned by the handler.
+	Subscribe(ctx context.Context, servers []string, subject string, queueGroup string, jetStream bool, handler func(context.Context, *Msg) Ack) error
+	// Publish publishes the message to its subject.
+	Publish(ctx context.Context, servers []string, msg *Msg) error
+}
+
+// config is the nats configuration of an input.
+type config struct {
+	servers    []string
+	subject    string
+	queueGroup string
+	jetStream  bool
+	// terminate terminates the failed binding events already retried by the framework
+	terminate bool
+}
+
+// subscription is a subject subscribed for the handler of an input.
+type subscription struct {
+	config
+	handle func(context.Context, *Msg) Ack
+}
+
+// Adapter is the input adapter subscribing to the inputs of the function with nats.
+type Adapter struct {
+	client        Client
+	mu            sync.Mutex
+	configs       map[string]config
+	subscriptions []subscription
+	cancel        context.CancelFunc
+	stopped       bool
+}
+
+var _ async.InputAdapter = &Adapter{}
+var _ async.InputConfigurable = &Adapter{}
+var _ ofctx.OutputSender = &Sender{}
+
+// NewAdapter creates the input adapter subscribing with the client.
+func NewAdapter(client Client) *Adapter {
+	return &Adapter{
+		client:  client,
+		configs: map[string]config{},
+	}
+}
+
+// ConfigureInput reads the nats configuration from the metadata of the input.
+func (a *Adapter) ConfigureInput(name string, input *ofctx.Input) error {
+	servers := parseServers(input.Metadata)
+	if len(servers) == 0 {
+		return fmt.Errorf("metadata %s is required for nats input %s", ServersMetadataKey, name)
+	}
+
+	c := config{servers: servers, queueGroup: input.Metadata[QueueGroupMetadataKey]}
+	if v, ok := input.Metadata[JetStreamMetadataKey]; ok {
+		jetStream, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid metadata %s of nats input %s: %v", JetStreamMetadataKey, name, err)
+		}
+		c.jetStream = jetStream
+	}
+
+	var key string
+	switch input.GetType() {
+	case ofctx.OpenFuncTopic:
+		key = subjectKey(input.ComponentName, input.Uri)
+		c.subject = input.Uri
+	case ofctx.OpenFuncBinding:
+		key = input.ComponentName
+		if c.subject = input.Metadata[SubjectMetadataKey]; c.subject == "" {
+			c.subject = input.ComponentName
+		}
+		c.terminate = input.GetRetryPolicy() != nil
+	default:
+		return fmt.Errorf("nats input %s must be a binding or a topic, got %s", name, input.ComponentType)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configs[key] = c
+	return nil
+}
+
+// AddBindingInvocationHandler subscribes to the subject of the binding input.
+func (a *Adapter) AddBindingInvocationHandler(name string, fn dapr.BindingInvocationHandler) error {
+	return a.subscribe(name, func(c config) func(context.Context, *Msg) Ack {
+		return func(ctx context.Context, msg *Msg) Ack {
+			if _, err := fn(ctx, &dapr.BindingEvent{Data: msg.Data, Metadata: msg.Header}); err != nil {
+				if c.terminate {
+					klog.Warningf("terminated nats message of subject %s: %v", msg.Subject, err)
+					return AckTerm
+				}
+				return AckNak
+			}
+			return AckOK
+		}
+	})
+}
+
+// AddTopicEventHandler subscribes to the subject of the topic input.
+func (a *Adapter) AddTopicEventHandler(sub *dapr.Subscription, fn dapr.TopicEventHandler) error {
+	return a.subscribe(subjectKey(sub.PubsubName, sub.Topic), func(c config) func(context.Context, *Msg) Ack {
+		return func(ctx context.Context, msg *Msg) Ack {
+			retry, err := fn(ctx, &dapr.TopicEvent{
+				ID:         fmt.Sprintf("%s-%d", msg.Subject, msg.Sequence),
+				Topic:      msg.Subject,
+				PubsubName: sub.PubsubName,
+				Data:       msg.Data,
+				RawData:    msg.Data,
+			})
+			switch {
+			case err == nil:
+				return AckOK
+			case retry:
+				return AckNak
+			default:
+				klog.Warningf("terminated nats message of subject %s: %v", msg.Subject, err)
+				return AckTerm
+			}
+		}
+	})
+}
+
+// AddServiceInvocationHandler is not supported, the service invocations are only served through dapr.
+func (a *Adapter) AddServiceInvocationHandler(name string, fn dapr.ServiceInvocationHandler) error {
+	return errors.New("nats adapter cannot serve service invocations")
+}
+
+func (a *Adapter) subscribe(key string, handler func(config) func(context.Context, *Msg) Ack) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.configs[key]
+	if !ok {
+		return fmt.Errorf("nats input %s is not configured", key)
+	}
+	a.subscriptions = append(a.subscriptions, subscription{config: c, handle: handler(c)})
+	return nil
+}
+
+// Start subscribes to the subjects until the adapter is stopped or a subscription fails.
+func (a *Adapter) Start() error {
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	subscriptions := a.subscriptions
+	a.mu.Unlock()
+
+	errs := make(chan error, len(subscriptions))
+	var wg sync.WaitGroup
+	for _, s := range subscriptions {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.client.Subscribe(ctx, s.servers, s.subject, s.queueGroup, s.jetStream, s.handle); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("failed to subscribe to nats subject %s: %v", s.subject, err)
+				cancel()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Stop stops the subscriptions.
+func (a *Adapter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopped = true
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return nil
+}
+
+// Sender is the output sender publishing the data sent to the nats outputs.
+type Sender struct {
+	client Client
+}
+
+// NewSender creates the output sender publishing with the client.
+func NewSender(client Client) *Sender {
+	return &Sender{client: client}
+}
+
+// SendOutput publishes the data to the subject of the output, the uri of the output unless the subject metadata is set.
+func (s *Sender) SendOutput(c context.Context, output *ofctx.Output, data []byte) (*ofctx.BindingResult, error) {
+	servers := parseServers(output.Metadata)
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("metadata %s is required for nats output %s", ServersMetadataKey, output.ComponentName)
+	}
+
+	msg := &Msg{Subject: output.Metadata[SubjectMetadataKey], Data: data}
+	if msg.Subject == "" {
+		msg.Subject = output.Uri
+	}
+	if err := s.client.Publish(c, servers, msg); err != nil {
+		return nil, err
+	}
+	return &ofctx.BindingResult{}, nil
+}
+
+func parseServers(metadata map[string]string) []string {
+	var servers []string
+	for _, server := range strings.Split(metadata[ServersMetadataKey], ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+func subjectKey(pubsubName, subject string) string {
+	return pubsubName + "/" + subject
+}
@@ -0,0 +1,243 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	dapr "github.com/dapr/go-sdk/service/common"
+	"github.com/stretchr/testify/assert"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/framework"
+	"github.com/tpiperatgod/offf-go/runtime/async"
+)
+
+// mockClient records the subscriptions and the published messages.
+type mockClient struct {
+	mu          sync.Mutex
+	handlers    map[string]func(context.Context, *Msg) Ack
+	queueGroups map[string]string
+	jetStream   map[string]bool
+	subscribed  chan string
+	published   []*Msg
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{
+		handlers:    map[string]func(context.Context, *Msg) Ack{},
+		queueGroups: map[string]string{},
+		jetStream:   map[string]bool{},
+		subscribed:  make(chan string, 10),
+	}
+}
+
+func (c *mockClient) Subscribe(ctx context.Context, servers []string, subject string, queueGroup string, jetStream bool, handler func(context.Context, *Msg) Ack) error {
+	c.mu.Lock()
+	c.handlers[subject] = handler
+	c.queueGroups[subject] = queueGroup
+	c.jetStream[subject] = jetStream
+	c.mu.Unlock()
+	c.subscribed <- subject
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *mockClient) Publish(ctx context.Context, servers []string, msg *Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, msg)
+	return nil
+}
+
+// deliver delivers the message to the subscription of its subject, and returns its ack.
+func (c *mockClient) deliver(t *testing.T, msg *Msg) Ack {
+	c.mu.Lock()
+	handler, ok := c.handlers[msg.Subject]
+	c.mu.Unlock()
+	if !ok {
+		t.Fatalf("subject %s not subscribed", msg.Subject)
+	}
+	return handler(context.Background(), msg)
+}
+
+func (c *mockClient) waitSubscribed(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("subject not subscribed")
+		}
+	}
+}
+
+// userData returns the user data of the cloudevent published to a pubsub output.
+func userData(t *testing.T, value []byte) string {
+	event := cloudevents.NewEvent()
+	if err := json.Unmarshal(value, &event); err != nil {
+		t.Fatalf("failed to unmarshal published event: %v", err)
+	}
+	var data struct {
+		UserData []byte `json:"userData"`
+	}
+	if err := json.Unmarshal(event.Data(), &data); err != nil {
+		t.Fatalf("failed to unmarshal published event data: %v", err)
+	}
+	return string(data.UserData)
+}
+
+func TestNatsSubscribeAndPublish(t *testing.T) {
+	client := newMockClient()
+	async.SetInputAdapterFactory(func(port string) (async.InputAdapter, error) {
+		return NewAdapter(client), nil
+	})
+	defer async.SetInputAdapterFactory(nil)
+
+	os.Setenv(ofctx.ModeEnvName, ofctx.SelfHostMode)
+	defer os.Unsetenv(ofctx.ModeEnvName)
+	os.Setenv(ofctx.FunctionContextEnvName, `{
+  "name": "function-nats",
+  "version": "v1",
+  "runtime": "Async",
+  "port": "50041",
+  "inputs": {
+    "orders": {
+      "uri": "orders",
+      "componentName": "nats",
+      "componentType": "pubsub.jetstream",
+      "metadata": {
+        "servers": "nats://localhost:4222",
+        "queueGroup": "function-nats",
+        "jetStream": "true"
+      }
+    },
+    "events": {
+      "componentName": "events",
+      "componentType": "bindings.nats",
+      "metadata": {
+        "servers": "nats://localhost:4222",
+        "subject": "raw.events",
+        "jetStream": "true",
+        "retryMaxAttempts": "2",
+        "retryBackoff": "1ms"
+      }
+    }
+  },
+  "outputs": {
+    "shipments": {
+      "uri": "shipments",
+      "componentName": "nats",
+      "componentType": "pubsub.nats",
+      "metadata": {
+        "servers": "nats://localhost:4222"
+      }
+    }
+  }
+}`)
+	defer os.Unsetenv(ofctx.FunctionContextEnvName)
+
+	fwk, err := framework.NewFramework(ofctx.WithOutputSender(ComponentType, NewSender(client)))
+	if err != nil {
+		t.Fatalf("failed to create framework: %v", err)
+	}
+	fwk.RegisterPlugins(nil)
+
+	fn := func(ctx ofctx.Context, in []byte) (ofctx.Out, error) {
+		switch string(in) {
+		case "unavailable":
+			return ctx.ReturnOnInternalError().WithCode(503), errors.New("unavailable")
+		case "fail":
+			return ctx.ReturnOnInternalError(), errors.New("failed to process")
+		}
+		if _, err := ctx.Send("shipments", in); err != nil {
+			return ctx.ReturnOnInternalError(), err
+		}
+		return ctx.ReturnOnSuccess(), nil
+	}
+	c, cancel := context.WithCancel(context.Background())
+	if err := fwk.Register(c, fn); err != nil {
+		t.Fatalf("failed to register function: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fwk.Start(c)
+	}()
+	client.waitSubscribed(t, 2)
+	assert.Equal(t, "function-nats", client.queueGroups["orders"])
+	assert.True(t, client.jetStream["orders"])
+
+	for i, tc := range []struct {
+		msg *Msg
+		ack Ack
+	}{
+		{&Msg{Subject: "orders", Data: []byte("order-1")}, AckOK},
+		{&Msg{Subject: "orders", Data: []byte("unavailable")}, AckNak},
+		{&Msg{Subject: "orders", Data: []byte("fail")}, AckTerm},
+		{&Msg{Subject: "raw.events", Data: []byte("event-1")}, AckOK},
+		// the binding events are retried by the framework with the retry policy of the input
+		{&Msg{Subject: "raw.events", Data: []byte("fail")}, AckTerm},
+	} {
+		tc.msg.Sequence = uint64(i)
+		assert.Equal(t, tc.ack, client.deliver(t, tc.msg), fmt.Sprintf("%s: %s", tc.msg.Subject, tc.msg.Data))
+	}
+
+	client.mu.Lock()
+	if assert.Len(t, client.published, 2) {
+		assert.Equal(t, "shipments", client.published[0].Subject)
+		assert.Equal(t, "order-1", userData(t, client.published[0].Data))
+		assert.Equal(t, "event-1", userData(t, client.published[1].Data))
+	}
+	client.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("nats adapter not stopped")
+	}
+}
+
+func TestNatsBindingWithoutRetryPolicy(t *testing.T) {
+	a := NewAdapter(newMockClient())
+	err := a.ConfigureInput("events", &ofctx.Input{
+		ComponentName: "events",
+		ComponentType: "bindings.nats",
+		Metadata:      map[string]string{ServersMetadataKey: "nats://localhost:4222"},
+	})
+	if err != nil {
+		t.Fatalf("failed to configure input: %v", err)
+	}
+	err = a.AddBindingInvocationHandler("events", func(ctx context.Context, in *dapr.BindingEvent) ([]byte, error) {
+		return nil, errors.New("failed to process")
+	})
+	if err != nil {
+		t.Fatalf("failed to add binding handler: %v", err)
+	}
+
+	// the failed events are redelivered without a retry policy
+	if assert.Len(t, a.subscriptions, 1) {
+		assert.Equal(t, "events", a.subscriptions[0].subject)
+		assert.Equal(t, AckNak, a.subscriptions[0].handle(context.Background(), &Msg{Subject: "events", Data: []byte("hello")}))
+	}
+}
+
+func TestNatsInputConfig(t *testing.T) {
+	a := NewAdapter(newMockClient())
+
+	for name, input := range map[string]*ofctx.Input{
+		"no-servers": {ComponentName: "nats", ComponentType: "pubsub.nats"},
+		"jetstream":  {ComponentName: "nats", ComponentType: "pubsub.nats", Metadata: map[string]string{ServersMetadataKey: "nats://localhost:4222", JetStreamMetadataKey: "maybe"}},
+	} {
+		assert.Error(t, a.ConfigureInput(name, input), name)
+	}
+	assert.Error(t, a.AddTopicEventHandler(&dapr.Subscription{PubsubName: "nats", Topic: "absent"}, nil))
+}
@@ -0,0 +1,172 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+	"k8s.io/klog/v2"
+
+	"github.com/tpiperatgod/offf-go/runtime"
+)
+
+const (
+	// WorkerPoolSizeEnvName sets how many events are processed concurrently, the events are dispatched
+	// as soon as they are received when unset.
+	WorkerPoolSizeEnvName = "WORKER_POOL_SIZE"
+	// WorkerQueueDepthEnvName sets how many events wait for a worker, the pool size by default.
+	// The events received when the queue is full are rejected so that the broker backs off.
+	WorkerQueueDepthEnvName = "WORKER_QUEUE_DEPTH"
+)
+
+const (
+	taskQueued int32 = iota
+	taskStarted
+	taskAbandoned
+)
+
+// ErrQueueFull is returned for the events rejected while all the workers are busy and the queue is full.
+var ErrQueueFull = errors.New("the worker queue is full")
+
+// workerPool processes the events on a bounded number of workers with a bounded queue.
+type workerPool struct {
+	tasks    chan *task
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type task struct {
+	fn    func()
+	state int32
+	done  chan struct{}
+}
+
+// newWorkerPoolFromEnv starts the worker pool configured by the env, or returns nil if it is not enabled.
+func newWorkerPoolFromEnv() *workerPool {
+	value := os.Getenv(WorkerPoolSizeEnvName)
+	if value == "" {
+		return nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		klog.Warningf("invalid %s: %s, the events are dispatched without a worker pool", WorkerPoolSizeEnvName, value)
+		return nil
+	}
+
+	depth := size
+	if value := os.Getenv(WorkerQueueDepthEnvName); value != "" {
+		if d, err := strconv.Atoi(value); err != nil || d < 0 {
+			klog.Warningf("invalid %s: %s, using the pool size %d", WorkerQueueDepthEnvName, value, size)
+		} else {
+			depth = d
+		}
+	}
+	return newWorkerPool(size, depth)
+}
+
+func newWorkerPool(size, depth int) *workerPool {
+	p := &workerPool{
+		tasks: make(chan *task, depth),
+		stop:  make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	for {
+		select {
+		case t := <-p.tasks:
+			if atomic.CompareAndSwapInt32(&t.state, taskQueued, taskStarted) {
+				t.fn()
+			}
+			close(t.done)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// run runs fn on a worker and waits for it, it fails with ErrQueueFull at once if there is no room in the queue.
+// The event waiting in the queue is abandoned once the invocation is cancelled or the pool is stopped.
+func (p *workerPool) run(c context.Context, fn func()) error {
+	t := &task{fn: fn, done: make(chan struct{})}
+	select {
+	case p.tasks <- t:
+	default:
+		return ErrQueueFull
+	}
+
+	select {
+	case <-t.done:
+		return nil
+	case <-c.Done():
+		if atomic.CompareAndSwapInt32(&t.state, taskQueued, taskAbandoned) {
+			return c.Err()
+		}
+	case <-p.stop:
+		if atomic.CompareAndSwapInt32(&t.state, taskQueued, taskAbandoned) {
+			return runtime.ErrDraining
+		}
+	}
+	// fn has been started, it is waited for since it sets the results of the handler
+	<-t.done
+	return nil
+}
+
+// Stop stops the workers once they finish their current event.
+func (p *workerPool) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// bindingHandler runs the binding handler on the worker pool, the event is rejected with an error
+// when the queue is full.
+func (p *workerPool) bindingHandler(fn dapr.BindingInvocationHandler) dapr.BindingInvocationHandler {
+	if p == nil {
+		return fn
+	}
+	return func(c context.Context, in *dapr.BindingEvent) (out []byte, err error) {
+		if perr := p.run(c, func() { out, err = fn(c, in) }); perr != nil {
+			return nil, perr
+		}
+		return out, err
+	}
+}
+
+// topicHandler runs the topic handler on the worker pool, the event is retried when the queue is full.
+func (p *workerPool) topicHandler(fn dapr.TopicEventHandler) dapr.TopicEventHandler {
+	if p == nil {
+		return fn
+	}
+	return func(c context.Context, e *dapr.TopicEvent) (retry bool, err error) {
+		if perr := p.run(c, func() { retry, err = fn(c, e) }); perr != nil {
+			return true, perr
+		}
+		return retry, err
+	}
+}
+
+// serviceHandler runs the service invocation handler on the worker pool, the invocation is rejected
+// with an error when the queue is full.
+func (p *workerPool) serviceHandler(fn dapr.ServiceInvocationHandler) dapr.ServiceInvocationHandler {
+	if p == nil {
+		return fn
+	}
+	return func(c context.Context, in *dapr.InvocationEvent) (out *dapr.Content, err error) {
+		if perr := p.run(c, func() { out, err = fn(c, in) }); perr != nil {
+			return nil, perr
+		}
+		return out, err
+	}
+}
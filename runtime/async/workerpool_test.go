@@ -0,0 +1,175 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/service/common"
+
+	"github.com/tpiperatgod/offf-go/runtime"
+)
+
+// blockWorker occupies the single worker of the pool until the returned function is called.
+func blockWorker(t *testing.T, p *workerPool) func() {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.run(context.Background(), func() {
+			close(entered)
+			<-release
+		})
+	}()
+	<-entered
+	return func() {
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("failed to run the blocking task: %v", err)
+		}
+	}
+}
+
+// enqueue runs fn on the pool in the background and waits until it is queued.
+func enqueue(c context.Context, p *workerPool, fn func()) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.run(c, fn)
+	}()
+	for len(p.tasks) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	p := newWorkerPool(1, 1)
+	defer p.Stop()
+
+	release := blockWorker(t, p)
+	queued := enqueue(context.Background(), p, func() {})
+
+	// the worker is busy and the queue is full
+	if err := p.run(context.Background(), func() {}); err != ErrQueueFull {
+		t.Fatalf("got error %v with a full queue, want %v", err, ErrQueueFull)
+	}
+	binding := p.bindingHandler(func(c context.Context, in *dapr.BindingEvent) ([]byte, error) {
+		return in.Data, nil
+	})
+	if _, err := binding(context.Background(), &dapr.BindingEvent{Data: []byte("rejected")}); err != ErrQueueFull {
+		t.Fatalf("got binding error %v with a full queue, want %v", err, ErrQueueFull)
+	}
+	topic := p.topicHandler(func(c context.Context, e *dapr.TopicEvent) (bool, error) {
+		return false, nil
+	})
+	if retry, err := topic(context.Background(), &dapr.TopicEvent{}); !retry || err != ErrQueueFull {
+		t.Fatalf("got topic retry %v and error %v with a full queue, want a retry and %v", retry, err, ErrQueueFull)
+	}
+	service := p.serviceHandler(func(c context.Context, in *dapr.InvocationEvent) (*dapr.Content, error) {
+		return &dapr.Content{}, nil
+	})
+	if _, err := service(context.Background(), &dapr.InvocationEvent{}); err != ErrQueueFull {
+		t.Fatalf("got service error %v with a full queue, want %v", err, ErrQueueFull)
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Fatalf("failed to run the queued task: %v", err)
+	}
+
+	// the events are accepted again once the worker is free
+	out, err := binding(context.Background(), &dapr.BindingEvent{Data: []byte("accepted")})
+	if err != nil {
+		t.Fatalf("failed to run the binding handler: %v", err)
+	}
+	if string(out) != "accepted" {
+		t.Fatalf("got binding output %q, want %q", out, "accepted")
+	}
+}
+
+func TestWorkerPoolCancelled(t *testing.T) {
+	p := newWorkerPool(1, 1)
+	defer p.Stop()
+
+	release := blockWorker(t, p)
+
+	c, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	queued := enqueue(c, p, func() { ran <- struct{}{} })
+
+	// the queued event is abandoned once its invocation is cancelled
+	cancel()
+	if err := <-queued; err != context.Canceled {
+		t.Fatalf("got error %v for the cancelled task, want %v", err, context.Canceled)
+	}
+	release()
+	select {
+	case <-ran:
+		t.Fatal("the abandoned task was run")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWorkerPoolStop(t *testing.T) {
+	p := newWorkerPool(1, 1)
+
+	release := blockWorker(t, p)
+	defer release()
+
+	queued := enqueue(context.Background(), p, func() {})
+
+	// the queued event is abandoned once the pool is stopped
+	p.Stop()
+	p.Stop()
+	if err := <-queued; !errors.Is(err, runtime.ErrDraining) {
+		t.Fatalf("got error %v for the task queued on stop, want %v", err, runtime.ErrDraining)
+	}
+}
+
+func TestWorkerPoolFromEnv(t *testing.T) {
+	defer os.Unsetenv(WorkerPoolSizeEnvName)
+	defer os.Unsetenv(WorkerQueueDepthEnvName)
+
+	// the pool is disabled unless its size is set
+	os.Unsetenv(WorkerPoolSizeEnvName)
+	if p := newWorkerPoolFromEnv(); p != nil {
+		t.Fatal("got a worker pool without its size set")
+	}
+	os.Setenv(WorkerPoolSizeEnvName, "none")
+	if p := newWorkerPoolFromEnv(); p != nil {
+		t.Fatal("got a worker pool with an invalid size")
+	}
+
+	for _, tc := range []struct {
+		depth string
+		want  int
+	}{
+		{"", 2},
+		{"5", 5},
+		{"0", 0},
+		{"-1", 2},
+	} {
+		os.Setenv(WorkerPoolSizeEnvName, "2")
+		os.Setenv(WorkerQueueDepthEnvName, tc.depth)
+		p := newWorkerPoolFromEnv()
+		if p == nil {
+			t.Fatalf("got no worker pool with the queue depth %q", tc.depth)
+		}
+		if got := cap(p.tasks); got != tc.want {
+			t.Fatalf("got queue depth %d with %q, want %d", got, tc.depth, tc.want)
+		}
+		p.Stop()
+	}
+
+	// the handlers are not wrapped without a pool
+	var p *workerPool
+	binding := p.bindingHandler(func(c context.Context, in *dapr.BindingEvent) ([]byte, error) {
+		return in.Data, nil
+	})
+	if out, err := binding(context.Background(), &dapr.BindingEvent{Data: []byte("direct")}); err != nil || string(out) != "direct" {
+		t.Fatalf("got binding output %q and error %v without a pool, want %q", out, err, "direct")
+	}
+	p.Stop()
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"container/list"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DedupWindowSizeEnvName sets how many event ids are remembered to drop the duplicates, the events are not
+	// deduplicated when unset.
+	DedupWindowSizeEnvName = "DEDUP_WINDOW_SIZE"
+	// DedupWindowTTLEnvName sets how long an event id is remembered, 10m by default.
+	DedupWindowTTLEnvName = "DEDUP_WINDOW_TTL"
+	// DedupKeyMetadataEnvName names the metadata field carrying the id of the binding events,
+	// the binding events are not deduplicated when unset. The topic events and the cloudevents are keyed by their id.
+	DedupKeyMetadataEnvName = "DEDUP_KEY_METADATA"
+
+	defaultDedupWindowTTL = 10 * time.Minute
+)
+
+// DedupWindow remembers the ids of the recent events in memory to drop the duplicates seen within the TTL,
+// the least recently seen ids are evicted once the size is reached. A nil DedupWindow drops nothing.
+type DedupWindow struct {
+	size        int
+	ttl         time.Duration
+	metadataKey string
+
+	mu    sync.Mutex
+	order *list.List
+	seen  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key  string
+	time time.Time
+}
+
+func NewDedupWindow(size int, ttl time.Duration, metadataKey string) *DedupWindow {
+	return &DedupWindow{
+		size:        size,
+		ttl:         ttl,
+		metadataKey: metadataKey,
+		order:       list.New(),
+		seen:        map[string]*list.Element{},
+	}
+}
+
+// NewDedupWindowFromEnv returns the dedup window configured by the env, or nil if it is not enabled.
+func NewDedupWindowFromEnv() *DedupWindow {
+	value := os.Getenv(DedupWindowSizeEnvName)
+	if value == "" {
+		return nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		klog.Warningf("invalid %s: %s, the events are not deduplicated", DedupWindowSizeEnvName, value)
+		return nil
+	}
+
+	ttl := defaultDedupWindowTTL
+	if value := os.Getenv(DedupWindowTTLEnvName); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			klog.Warningf("invalid %s: %s, using the default %s", DedupWindowTTLEnvName, value, defaultDedupWindowTTL)
+		} else {
+			ttl = d
+		}
+	}
+	return NewDedupWindow(size, ttl, os.Getenv(DedupKeyMetadataEnvName))
+}
+
+// BindingKey returns the id of the binding event from its metadata, empty if it carries none.
+func (w *DedupWindow) BindingKey(metadata map[string]string) string {
+	if w == nil || w.metadataKey == "" {
+		return ""
+	}
+	return metadata[w.metadataKey]
+}
+
+// Seen reports whether the id of the input was seen within the TTL, the id is remembered if it was not.
+// The empty ids are never seen.
+func (w *DedupWindow) Seen(input, id string) bool {
+	if w == nil || id == "" {
+		return false
+	}
+	key := input + "/" + id
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The ids are ordered by the time they were remembered, the expired ones are at the back
+	for e := w.order.Back(); e != nil && now.Sub(e.Value.(*dedupEntry).time) >= w.ttl; e = w.order.Back() {
+		w.remove(e)
+	}
+	if _, ok := w.seen[key]; ok {
+		return true
+	}
+
+	w.seen[key] = w.order.PushFront(&dedupEntry{key: key, time: now})
+	if w.order.Len() > w.size {
+		w.remove(w.order.Back())
+	}
+	return false
+}
+
+// Forget drops the id of the input so that the event failed to be processed is not dropped when redelivered.
+func (w *DedupWindow) Forget(input, id string) {
+	if w == nil || id == "" {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if e, ok := w.seen[input+"/"+id]; ok {
+		w.remove(e)
+	}
+}
+
+func (w *DedupWindow) remove(e *list.Element) {
+	w.order.Remove(e)
+	delete(w.seen, e.Value.(*dedupEntry).key)
+}
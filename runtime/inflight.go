@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// GracePeriodEnvName sets how long the in-flight invocations are waited for on shutdown, 30s by default.
+	GracePeriodEnvName = "SHUTDOWN_GRACE_PERIOD"
+	defaultGracePeriod = 30 * time.Second
+)
+
+// ErrDraining is returned for the invocations rejected while the runtime is shutting down.
+var ErrDraining = errors.New("function is draining")
+
+// InflightTracker tracks the in-flight invocations of a runtime so that they can finish on shutdown.
+type InflightTracker struct {
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	draining bool
+}
+
+// Begin tracks a new invocation, it returns false if the runtime is draining and the invocation must be rejected.
+func (t *InflightTracker) Begin() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// End marks the invocation tracked by Begin as finished.
+func (t *InflightTracker) End() {
+	t.wg.Done()
+}
+
+// Drain rejects the new invocations and waits for the in-flight ones until the context is done.
+func (t *InflightTracker) Drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GracePeriod returns the time the in-flight invocations are waited for on shutdown.
+func GracePeriod() time.Duration {
+	value := os.Getenv(GracePeriodEnvName)
+	if value == "" {
+		return defaultGracePeriod
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		klog.Warningf("invalid %s: %s, using the default %s", GracePeriodEnvName, value, defaultGracePeriod)
+		return defaultGracePeriod
+	}
+	return d
+}
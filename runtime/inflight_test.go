@@ -0,0 +1,72 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInflightTrackerDrain(t *testing.T) {
+	tracker := &InflightTracker{}
+	if !tracker.Begin() {
+		t.Fatal("the invocation was rejected before draining")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tracker.Drain(context.Background())
+	}()
+
+	// the new invocations are rejected while the in-flight one completes
+	for tracker.Begin() {
+		tracker.End()
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("drained before the in-flight invocation completed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tracker.End()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to drain: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not drained after the in-flight invocation completed")
+	}
+}
+
+func TestInflightTrackerDrainTimeout(t *testing.T) {
+	tracker := &InflightTracker{}
+	if !tracker.Begin() {
+		t.Fatal("the invocation was rejected before draining")
+	}
+	defer tracker.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tracker.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v draining past the deadline, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	defer os.Unsetenv(GracePeriodEnvName)
+
+	for value, want := range map[string]time.Duration{
+		"":        defaultGracePeriod,
+		"5s":      5 * time.Second,
+		"0s":      0,
+		"-1s":     defaultGracePeriod,
+		"forever": defaultGracePeriod,
+	} {
+		os.Setenv(GracePeriodEnvName, value)
+		if got := GracePeriod(); got != want {
+			t.Fatalf("got grace period %s with %q, want %s", got, value, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package knative
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+const (
+	// BodyLogLevelEnvName enables the logging of the request and response bodies at the klog verbosity level.
+	BodyLogLevelEnvName = "BODY_LOG_LEVEL"
+	// BodyLogMaxBytesEnvName caps the bytes of each body logged, 1024 by default.
+	BodyLogMaxBytesEnvName = "BODY_LOG_MAX_BYTES"
+	// BodyLogRedactEnvName lists the comma-separated JSON fields whose values are redacted from the logged bodies,
+	// `password,secret,token` by default.
+	BodyLogRedactEnvName = "BODY_LOG_REDACT"
+
+	defaultBodyLogMaxBytes = 1024
+	defaultBodyLogRedact   = "password,secret,token"
+	redacted               = "[REDACTED]"
+)
+
+// bodyLogger logs the request and response bodies of the http invocations for audit.
+type bodyLogger struct {
+	level    klog.Level
+	maxBytes int
+	redact   *regexp.Regexp
+}
+
+// newBodyLoggerFromEnv returns the body logger configured by the env, or nil if it is not enabled.
+func newBodyLoggerFromEnv() *bodyLogger {
+	value := os.Getenv(BodyLogLevelEnvName)
+	if value == "" {
+		return nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 {
+		klog.Warningf("invalid %s: %s, the bodies are not logged", BodyLogLevelEnvName, value)
+		return nil
+	}
+
+	maxBytes := defaultBodyLogMaxBytes
+	if value := os.Getenv(BodyLogMaxBytesEnvName); value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			klog.Warningf("invalid %s: %s, using the default %d", BodyLogMaxBytesEnvName, value, defaultBodyLogMaxBytes)
+		} else {
+			maxBytes = n
+		}
+	}
+
+	fields := defaultBodyLogRedact
+	if value, ok := os.LookupEnv(BodyLogRedactEnvName); ok {
+		fields = value
+	}
+	return &bodyLogger{
+		level:    klog.Level(level),
+		maxBytes: maxBytes,
+		redact:   redactPattern(fields),
+	}
+}
+
+// redactPattern matches the values of the JSON fields, nil if there is no field to redact.
+// The values are matched in the truncated bodies as well, which cannot be parsed as JSON.
+func redactPattern(fields string) *regexp.Regexp {
+	var names []string
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			names = append(names, regexp.QuoteMeta(f))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+}
+
+// format redacts and truncates the captured body, total is the size of the whole body.
+func (l *bodyLogger) format(body []byte, total int) string {
+	if l.redact != nil {
+		body = l.redact.ReplaceAll(body, []byte(`${1}"`+redacted+`"`))
+	}
+	s := string(body)
+	if total > l.maxBytes {
+		s += fmt.Sprintf("...(truncated %d bytes)", total-l.maxBytes)
+	}
+	return s
+}
+
+// cappedBuffer keeps the first max bytes written and counts the rest.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	b.total += len(p)
+	return len(p), nil
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// logBodies tees the request body as it is read by the function and captures the response,
+// both are logged once the request is served.
+func (r *Runtime) logBodies(h http.Handler) http.Handler {
+	l := r.bodyLogger
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !klog.V(l.level).Enabled() {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		reqBody := &cappedBuffer{max: l.maxBytes}
+		if req.Body != nil {
+			req.Body = &teeReadCloser{Reader: io.TeeReader(req.Body, reqBody), Closer: req.Body}
+		}
+		rww := ofctx.NewCapturingResponseWriterWrapper(w, http.StatusOK).WithCaptureLimit(l.maxBytes)
+		h.ServeHTTP(rww, req)
+
+		klog.V(l.level).Infof("http %s %s, request id %s, status %d, request body: %s, response body: %s",
+			req.Method, req.URL.RequestURI(), w.Header().Get(RequestIDHeader), rww.Status(),
+			l.format(reqBody.buf.Bytes(), reqBody.total), l.format(rww.Body(), rww.Written()))
+	})
+}
@@ -0,0 +1,127 @@
+package knative
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+const (
+	// CacheStatusHeader tells whether the response is served from the cache.
+	CacheStatusHeader = "X-OpenFunction-Cache"
+	cacheHit          = "hit"
+	cacheMiss         = "miss"
+	maxCacheEntries   = 1024
+)
+
+// responseCache caches the successful responses of the http function by method, url and body hash until their TTL expires.
+type responseCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: map[string]*cachedResponse{}}
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// set caches the response, it is dropped if the cache is still full once the expired responses are evicted.
+func (c *responseCache) set(key string, entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	entry.expires = time.Now().Add(c.ttl)
+	c.entries[key] = entry
+}
+
+// cacheKey identifies the request by its method, its url and the hash of its body.
+func cacheKey(req *http.Request, body []byte) string {
+	sum := sha256.Sum256(body)
+	return req.Method + " " + req.URL.RequestURI() + " " + hex.EncodeToString(sum[:])
+}
+
+// cacheResponse serves the cached response of the request if any, otherwise the response of the handler
+// is captured and cached if it succeeds. Nothing is cached unless the httpCacheTTL of the function is set.
+func cacheResponse(ctx ofctx.RuntimeContext, h http.Handler) http.Handler {
+	ttl := ctx.GetHttpCacheTTL()
+	if ttl <= 0 {
+		return h
+	}
+	cache := newResponseCache(ttl)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body []byte
+		if req.Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(req.Body); err != nil {
+				writeHTTPError(ctx, w, req, http.StatusBadRequest, "", err.Error())
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		key := cacheKey(req, body)
+
+		if entry := cache.get(key); entry != nil {
+			for k, v := range entry.header {
+				// the request id of the cached response is not the one of the request
+				if k == RequestIDHeader {
+					continue
+				}
+				w.Header()[k] = v
+			}
+			w.Header().Set(CacheStatusHeader, cacheHit)
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		w.Header().Set(CacheStatusHeader, cacheMiss)
+		rww := ofctx.NewCapturingResponseWriterWrapper(w, http.StatusOK)
+		h.ServeHTTP(rww, req)
+		if rww.Status() >= http.StatusOK && rww.Status() < http.StatusMultipleChoices {
+			cache.set(key, &cachedResponse{
+				status: rww.Status(),
+				header: rww.Header().Clone(),
+				body:   append([]byte(nil), rww.Body()...),
+			})
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package knative
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+const (
+	// CORSAllowOriginsEnvName lists the comma-separated origins allowed to call the function, `*` allows any origin.
+	// CORS is not handled when unset.
+	CORSAllowOriginsEnvName = "CORS_ALLOW_ORIGINS"
+	// CORSAllowMethodsEnvName lists the methods allowed by the preflight requests,
+	// the http methods of the function or the common methods by default.
+	CORSAllowMethodsEnvName = "CORS_ALLOW_METHODS"
+	// CORSAllowHeadersEnvName lists the headers allowed by the preflight requests, the requested headers by default.
+	CORSAllowHeadersEnvName = "CORS_ALLOW_HEADERS"
+	// CORSExposeHeadersEnvName lists the response headers exposed to the browser.
+	CORSExposeHeadersEnvName = "CORS_EXPOSE_HEADERS"
+	// CORSAllowCredentialsEnvName allows the requests with credentials when set to "true".
+	CORSAllowCredentialsEnvName = "CORS_ALLOW_CREDENTIALS"
+	// CORSMaxAgeEnvName sets how many seconds the preflight responses are cached by the browser.
+	CORSMaxAgeEnvName = "CORS_MAX_AGE"
+
+	defaultCORSMethods = "GET,HEAD,POST,PUT,PATCH,DELETE"
+)
+
+type corsConfig struct {
+	origins          map[string]bool
+	anyOrigin        bool
+	methods          string
+	headers          string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// newCORSConfigFromEnv returns the CORS configuration of the env, or nil if CORS is not enabled.
+func newCORSConfigFromEnv() *corsConfig {
+	origins := splitList(os.Getenv(CORSAllowOriginsEnvName))
+	if len(origins) == 0 {
+		return nil
+	}
+
+	c := &corsConfig{
+		origins:          map[string]bool{},
+		methods:          strings.Join(splitList(strings.ToUpper(os.Getenv(CORSAllowMethodsEnvName))), ", "),
+		headers:          strings.Join(splitList(os.Getenv(CORSAllowHeadersEnvName)), ", "),
+		exposeHeaders:    strings.Join(splitList(os.Getenv(CORSExposeHeadersEnvName)), ", "),
+		allowCredentials: boolFromEnv(CORSAllowCredentialsEnvName),
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			c.anyOrigin = true
+		}
+		c.origins[origin] = true
+	}
+	if value := os.Getenv(CORSMaxAgeEnvName); value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			klog.Warningf("invalid %s: %s, the preflight responses are not cached", CORSMaxAgeEnvName, value)
+		} else {
+			c.maxAge = value
+		}
+	}
+	return c
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handleCORS sets the CORS headers of the requests from the allowed origins and answers the preflight requests
+// without invoking the function. The requests from the other origins are served without the CORS headers
+// so that the browser blocks them, and their preflight requests are rejected with 403.
+func (r *Runtime) handleCORS(ctx ofctx.RuntimeContext, h http.Handler) http.Handler {
+	c := r.cors
+	if c == nil {
+		return h
+	}
+	methods := c.methods
+	if methods == "" {
+		if len(ctx.GetHttpMethods()) > 0 {
+			methods = strings.Join(ctx.GetHttpMethods(), ", ")
+		} else {
+			methods = strings.Join(splitList(defaultCORSMethods), ", ")
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, req)
+			return
+		}
+		preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+		if !c.anyOrigin && !c.origins[origin] {
+			if preflight {
+				writeHTTPError(ctx, w, req, http.StatusForbidden, "", "origin not allowed")
+				return
+			}
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		// The wildcard cannot be used for the requests with credentials
+		if c.anyOrigin && !c.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if c.exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposeHeaders)
+			}
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+		if !containsMethod(methods, method) {
+			writeHTTPError(ctx, w, req, http.StatusForbidden, "", "method not allowed")
+			return
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		headers := c.headers
+		if headers == "" {
+			headers = req.Header.Get("Access-Control-Request-Headers")
+		}
+		if headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		if c.maxAge != "" {
+			w.Header().Set("Access-Control-Max-Age", c.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func containsMethod(methods, method string) bool {
+	for _, m := range splitList(methods) {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,26 @@
 package knative
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"k8s.io/klog/v2"
 
 	ofctx "github.com/tpiperatgod/offf-go/context"
@@ -22,12 +34,43 @@ const (
 	errorStatus          = "error"
 	successStatus        = "success"
 	defaultPattern       = "/"
+	metricsPath          = "/metrics"
+	TLSCertFileEnvName   = "TLS_CERT_FILE"
+	TLSKeyFileEnvName    = "TLS_KEY_FILE"
+	ClientCAFileEnvName  = "CLIENT_CA_FILE"
+	RequestIDHeader      = "X-Request-ID"
+	MaxInflightEnvName   = "MAX_INFLIGHT"
+	ReadTimeoutEnvName   = "READ_TIMEOUT"
+	WriteTimeoutEnvName  = "WRITE_TIMEOUT"
+	IdleTimeoutEnvName   = "IDLE_TIMEOUT"
+	EnableH2CEnvName     = "ENABLE_H2C"
+	defaultReadTimeout   = time.Minute
+	defaultWriteTimeout  = 5 * time.Minute
+	defaultIdleTimeout   = 2 * time.Minute
+	streamChunkSize      = 32 << 10
 )
 
+var metricsOnce sync.Once
+
 type Runtime struct {
-	port    string
-	handler *http.ServeMux
-	pattern string
+	port        string
+	handler     *http.ServeMux
+	pattern     string
+	tlsCertFile string
+	tlsKeyFile  string
+	clientCA    string
+	// inflight limits the concurrent requests, nil if unlimited
+	inflight     chan struct{}
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	h2c          bool
+	tracker      *runtime.InflightTracker
+	routes       *routeTable
+	recorder     *runtime.Recorder
+	dedup        *runtime.DedupWindow
+	bodyLogger   *bodyLogger
+	cors         *corsConfig
 }
 
 func NewKnativeRuntime(port string, pattern string) *Runtime {
@@ -35,47 +78,320 @@ func NewKnativeRuntime(port string, pattern string) *Runtime {
 		pattern = defaultPattern
 	}
 	return &Runtime{
-		port:    port,
-		handler: http.DefaultServeMux,
-		pattern: pattern,
+		port:         port,
+		handler:      http.DefaultServeMux,
+		pattern:      pattern,
+		tlsCertFile:  os.Getenv(TLSCertFileEnvName),
+		tlsKeyFile:   os.Getenv(TLSKeyFileEnvName),
+		clientCA:     os.Getenv(ClientCAFileEnvName),
+		inflight:     newInflightLimiter(os.Getenv(MaxInflightEnvName)),
+		readTimeout:  durationFromEnv(ReadTimeoutEnvName, defaultReadTimeout),
+		writeTimeout: durationFromEnv(WriteTimeoutEnvName, defaultWriteTimeout),
+		idleTimeout:  durationFromEnv(IdleTimeoutEnvName, defaultIdleTimeout),
+		h2c:          boolFromEnv(EnableH2CEnvName),
+		tracker:      &runtime.InflightTracker{},
+		routes:       newRouteTable(),
+		recorder:     runtime.NewRecorder(),
+		dedup:        runtime.NewDedupWindowFromEnv(),
+		bodyLogger:   newBodyLoggerFromEnv(),
+		cors:         newCORSConfigFromEnv(),
 	}
 }
 
+// boolFromEnv parses the boolean of the env, false if it is unset or invalid.
+func boolFromEnv(name string) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Warningf("invalid %s: %s, using false", name, value)
+		return false
+	}
+	return b
+}
+
+// durationFromEnv parses the duration of the env, 0 disables the timeout, the default is used if it is unset or invalid.
+func durationFromEnv(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		klog.Warningf("invalid %s: %s, using the default %s", name, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// newInflightLimiter creates the semaphore of the max concurrent requests, nil if the limit is unset or invalid.
+func newInflightLimiter(limit string) chan struct{} {
+	if limit == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		klog.Warningf("invalid %s: %s, the concurrent requests are unlimited", MaxInflightEnvName, limit)
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
 func (r *Runtime) Start(ctx context.Context) error {
-	klog.Infof("Knative Function serving http: listening on port %s", r.port)
-	klog.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", r.port), r.handler))
+	useTLS, err := r.tlsEnabled()
+	if err != nil {
+		klog.Errorf("failed to enable tls: %v", err)
+		return err
+	}
+
+	r.serveMetrics()
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%s", r.port),
+		Handler:      r.handler,
+		ReadTimeout:  r.readTimeout,
+		WriteTimeout: r.writeTimeout,
+		IdleTimeout:  r.idleTimeout,
+	}
+
+	if r.clientCA != "" {
+		if !useTLS {
+			err := fmt.Errorf("%s requires tls to be enabled", ClientCAFileEnvName)
+			klog.Errorf("failed to enable client certificate verification: %v", err)
+			return err
+		}
+		pool, err := loadCertPool(r.clientCA)
+		if err != nil {
+			klog.Errorf("failed to enable client certificate verification: %v", err)
+			return err
+		}
+		// Client certificates are verified by the handler rather than during the handshake,
+		// so that the requests without a trusted certificate are rejected with 401.
+		srv.TLSConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+		srv.Handler = verifyClientCert(pool, r.handler)
+	}
+
+	if r.h2c {
+		if useTLS {
+			klog.Warningf("%s is ignored since tls is enabled, http/2 is negotiated over tls", EnableH2CEnvName)
+		} else {
+			// serve the cleartext http/2 requests besides the http/1 requests
+			srv.Handler = h2c.NewHandler(srv.Handler, &http2.Server{IdleTimeout: r.idleTimeout})
+		}
+	}
+
+	// Stop serving once the context is done, the in-flight requests are waited for within the grace period
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+
+		grace := runtime.GracePeriod()
+		c, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		// the tracker drains alongside the shutdown, so that the requests still coming through
+		// the open connections are rejected while the in-flight ones complete
+		drained := make(chan error, 1)
+		go func() {
+			drained <- r.tracker.Drain(c)
+		}()
+		err := srv.Shutdown(c)
+		if drainErr := <-drained; err == nil {
+			err = drainErr
+		}
+		if err != nil {
+			klog.Warningf("forcing the stop of http server after the grace period %s: %v", grace, err)
+			if err := srv.Close(); err != nil {
+				klog.Errorf("failed to stop http server: %v", err)
+			}
+		}
+	}()
+
+	if useTLS {
+		klog.Infof("Knative Function serving https: listening on port %s", r.port)
+		err = srv.ListenAndServeTLS(r.tlsCertFile, r.tlsKeyFile)
+	} else {
+		klog.Infof("Knative Function serving http: listening on port %s", r.port)
+		err = srv.ListenAndServe()
+	}
+	if ctx.Err() != nil {
+		<-stopped
+		return nil
+	}
+	klog.Fatal(err)
 	return nil
 }
 
+// serveMetrics serves the application metrics on /metrics unless a function is served on it,
+// the handler is registered once since the runtimes share the default mux.
+func (r *Runtime) serveMetrics() {
+	if r.routes.has(metricsPath) {
+		klog.Warningf("the application metrics are not served since the function is served on %s", metricsPath)
+		return
+	}
+	metricsOnce.Do(func() {
+		r.handler.Handle(metricsPath, ofctx.DefaultMetrics)
+	})
+}
+
+// tlsEnabled reports whether both the certificate and the key files are set, and checks that they exist.
+func (r *Runtime) tlsEnabled() (bool, error) {
+	if r.tlsCertFile == "" || r.tlsKeyFile == "" {
+		if r.tlsCertFile != "" || r.tlsKeyFile != "" {
+			klog.Warningf("both %s and %s are required to enable tls, serving plaintext http", TLSCertFileEnvName, TLSKeyFileEnvName)
+		}
+		return false, nil
+	}
+
+	for _, file := range []string{r.tlsCertFile, r.tlsKeyFile} {
+		if _, err := os.Stat(file); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 func (r *Runtime) RegisterOpenFunction(
 	ctx ofctx.RuntimeContext,
 	prePlugins []plugin.Plugin,
 	postPlugins []plugin.Plugin,
 	fn func(ofctx.Context, []byte) (ofctx.Out, error),
+) error {
+	return r.registerOpenFunction(ctx, prePlugins, postPlugins, fn)
+}
+
+func (r *Runtime) RegisterStreamFunction(
+	ctx ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn func(ofctx.Context, io.Reader) (ofctx.Out, error),
+) error {
+	return r.registerOpenFunction(ctx, prePlugins, postPlugins, fn)
+}
+
+// registerOpenFunction registers the OpenFunction functions, either taking the request body as bytes or as a reader.
+func (r *Runtime) registerOpenFunction(
+	ctx ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn interface{},
 ) error {
 	// Initialize dapr client if it is nil
-	ctx.InitDaprClientIfNil()
+	if err := runtime.InitDaprClientWithBackoff(ctx); err != nil {
+		klog.Errorf("failed to register function: %v", err)
+		return err
+	}
 
 	// Register the synchronous function (based on Knaitve runtime)
-	r.handler.HandleFunc(r.pattern, func(w http.ResponseWriter, r *http.Request) {
+	return r.handle(ctx, validateHttpPayload(ctx, func(w http.ResponseWriter, r *http.Request) {
+		c, cancel := requestContext(ctx, r)
+		defer cancel()
 		rm := runtime.NewRuntimeManager(ctx, prePlugins, postPlugins)
-		rm.FuncContext.SetSyncRequest(w, r)
-		defer RecoverPanicHTTP(w, "Function panic")
+		rm.FuncContext.SetNativeContext(c)
+		rm.FuncContext.SetSyncRequest(w, r.WithContext(c))
+		defer recoverPanic(ctx, w, r, "Function panic")
 		rm.FunctionRunWrapperWithHooks(fn)
 
-		switch rm.FuncOut.GetCode() {
-		case ofctx.Success:
-			w.Header().Set(functionStatusHeader, successStatus)
-			return
-		case ofctx.InternalError:
-			w.Header().Set(functionStatusHeader, errorStatus)
-			w.WriteHeader(rm.FuncOut.GetCode())
-			return
-		default:
+		writeFunctionOut(ctx, w, r, rm.FuncOut, rm.FuncContext.GetError())
+	}))
+}
+
+// requestContext returns the native context of the invocation of the http request,
+// it is cancelled once the request is cancelled or the base context of the function is done.
+func requestContext(ctx ofctx.RuntimeContext, r *http.Request) (context.Context, context.CancelFunc) {
+	c, cancel := context.WithCancel(r.Context())
+	base := ctx.GetBaseContext()
+	if base.Done() == nil {
+		return c, cancel
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-base.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	return c, func() {
+		close(stop)
+		cancel()
+	}
+}
+
+// writeFunctionOut maps the output of an OpenFunction handler to the http response,
+// the structured result is encoded with the codec negotiated from the Accept header.
+func writeFunctionOut(ctx ofctx.RuntimeContext, w http.ResponseWriter, r *http.Request, out ofctx.Out, err error) {
+	data := out.GetData()
+	reader := out.GetReader()
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if result := out.GetResult(); result != nil && len(data) == 0 && reader == nil {
+		codec := ofctx.NegotiateCodec(r.Header.Get("Accept"))
+		encoded, err := codec.Marshal(result)
+		if err != nil {
+			klog.Errorf("failed to encode function result: %v", err)
+			writeHTTPError(ctx, w, r, http.StatusInternalServerError, errorStatus, err.Error())
 			return
 		}
-	})
-	return nil
+		w.Header().Set("Content-Type", codec.ContentType())
+		data = encoded
+	}
+
+	// the metadata of the output is written as the response headers
+	for k, v := range out.GetMetadata() {
+		w.Header().Set(k, v)
+	}
+
+	switch ofctx.GetStatusClass(out.GetCode()) {
+	case ofctx.StatusUnset:
+	case ofctx.StatusOK:
+		w.Header().Set(functionStatusHeader, successStatus)
+	default:
+		w.Header().Set(functionStatusHeader, errorStatus)
+		if len(data) == 0 && reader == nil && err != nil {
+			data = []byte(err.Error())
+		}
+	}
+
+	if out.GetCode() != 0 {
+		w.WriteHeader(out.GetCode())
+	}
+	if len(data) > 0 {
+		if _, err := w.Write(data); err != nil {
+			klog.Errorf("failed to write function output: %v", err)
+		}
+	} else if reader != nil {
+		if err := streamOutput(w, reader); err != nil {
+			klog.Errorf("failed to stream function output: %v", err)
+		}
+	}
+}
+
+// streamOutput copies the output to the response without buffering it, each chunk is flushed to the client as it is read.
+func streamOutput(w http.ResponseWriter, reader io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
 }
 
 func (r *Runtime) RegisterHTTPFunction(
@@ -84,13 +400,20 @@ func (r *Runtime) RegisterHTTPFunction(
 	postPlugins []plugin.Plugin,
 	fn func(http.ResponseWriter, *http.Request),
 ) error {
-	r.handler.HandleFunc(r.pattern, func(w http.ResponseWriter, r *http.Request) {
+	return r.handle(ctx, cacheResponse(ctx, validateHttpPayload(ctx, func(w http.ResponseWriter, r *http.Request) {
+		c, cancel := requestContext(ctx, r)
+		defer cancel()
 		rm := runtime.NewRuntimeManager(ctx, prePlugins, postPlugins)
-		rm.FuncContext.SetSyncRequest(w, r)
-		defer RecoverPanicHTTP(w, "Function panic")
+		rm.FuncContext.SetNativeContext(c)
+		rm.FuncContext.SetSyncRequest(w, r.WithContext(c))
+		defer recoverPanic(ctx, w, r, "Function panic")
 		rm.FunctionRunWrapperWithHooks(fn)
-	})
-	return nil
+
+		// the function has not run if a plugin aborted it or the invocation was cancelled
+		if err := rm.FuncContext.GetError(); err != nil {
+			writeHTTPError(ctx, w, r, rm.FuncOut.GetCode(), errorStatus, err.Error())
+		}
+	})))
 }
 
 func (r *Runtime) RegisterCloudEventFunction(
@@ -106,21 +429,285 @@ func (r *Runtime) RegisterCloudEventFunction(
 		return err
 	}
 
+	// The HTTP protocol binding detects the content mode from the Content-Type header:
+	// `application/cloudevents+json` requests are decoded in structured mode,
+	// any other requests are decoded in binary mode with the attributes carried by the `Ce-` headers.
 	handleFn, err := cloudevents.NewHTTPReceiveHandler(ctx, p, func(ctx context.Context, ce cloudevents.Event) error {
+		if r.dedup.Seen("", ce.ID()) {
+			klog.V(4).Infof("dropped the duplicate cloudevent %s", ce.ID())
+			return nil
+		}
+		rm := runtime.NewRuntimeManager(funcContext, prePlugins, postPlugins)
+		rm.FuncContext.SetNativeContext(ctx)
+		rm.FuncContext.SetEvent("", &ce)
+		rm.FunctionRunWrapperWithHooks(fn)
+		if err := rm.FuncContext.GetError(); err != nil {
+			r.dedup.Forget("", ce.ID())
+			return err
+		}
+		return nil
+	})
+
+	if err != nil {
+		klog.Errorf("failed to create handler: %v\n", err)
+		return err
+	}
+	return r.handle(funcContext, handleFn)
+}
+
+func (r *Runtime) RegisterCloudEventResponseFunction(
+	ctx context.Context,
+	funcContext ofctx.RuntimeContext,
+	prePlugins []plugin.Plugin,
+	postPlugins []plugin.Plugin,
+	fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error),
+) error {
+	p, err := cloudevents.NewHTTP()
+	if err != nil {
+		klog.Errorf("failed to create protocol: %v\n", err)
+		return err
+	}
+
+	handleFn, err := cloudevents.NewHTTPReceiveHandler(ctx, p, func(ctx context.Context, ce cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
+		if r.dedup.Seen("", ce.ID()) {
+			klog.V(4).Infof("dropped the duplicate cloudevent %s", ce.ID())
+			return nil, nil
+		}
 		rm := runtime.NewRuntimeManager(funcContext, prePlugins, postPlugins)
+		rm.FuncContext.SetNativeContext(ctx)
 		rm.FuncContext.SetEvent("", &ce)
 		rm.FunctionRunWrapperWithHooks(fn)
-		return rm.FuncContext.GetError()
+		if err := rm.FuncContext.GetError(); err != nil {
+			r.dedup.Forget("", ce.ID())
+			return rm.FuncContext.GetCloudEventResponse(), err
+		}
+		return rm.FuncContext.GetCloudEventResponse(), nil
 	})
 
 	if err != nil {
 		klog.Errorf("failed to create handler: %v\n", err)
 		return err
 	}
-	r.handler.Handle(r.pattern, handleFn)
+	return r.handle(funcContext, handleFn)
+}
+
+// handle registers the handler on the pattern, rejecting the requests whose method is not allowed.
+// Patterns with parameterized segments such as `/orders/{id}` capture the parameters into the request.
+// It fails if the pattern collides with a pattern already registered.
+func (r *Runtime) handle(ctx ofctx.RuntimeContext, h http.Handler) error {
+	methods := ctx.GetHttpMethods()
+	if len(methods) > 0 {
+		next := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			for _, m := range methods {
+				if req.Method == m {
+					next.ServeHTTP(w, req)
+					return
+				}
+			}
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			writeHTTPError(ctx, w, req, http.StatusMethodNotAllowed, "", http.StatusText(http.StatusMethodNotAllowed))
+		})
+	}
+	h = r.logBodies(h)
+	h = r.recordRequest(h)
+	h = r.limitInflight(ctx, h)
+	h = r.trackInflight(ctx, h)
+	h = r.handleCORS(ctx, h)
+	h = withRequestID(h)
+
+	rt := newRoute(r.pattern)
+	if !rt.params {
+		if err := r.routes.add(r.pattern); err != nil {
+			klog.Errorf("failed to register function: %v", err)
+			return err
+		}
+		r.handler.Handle(r.pattern, h)
+		return nil
+	}
+
+	// the patterns sharing the prefix are dispatched by the handler registered with the first of them
+	dispatcher, err := r.routes.addRoute(r.pattern, rt, h)
+	if err != nil {
+		klog.Errorf("failed to register function: %v", err)
+		return err
+	}
+	if dispatcher != nil {
+		r.handler.Handle(rt.prefix(), dispatcher)
+	}
 	return nil
 }
 
+// trackInflight tracks the requests so that they finish on shutdown, the new requests are rejected with 503 while draining.
+func (r *Runtime) trackInflight(ctx ofctx.RuntimeContext, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.tracker.Begin() {
+			// the client is told to reconnect, to another instance
+			w.Header().Set("Connection", "close")
+			writeHTTPError(ctx, w, req, http.StatusServiceUnavailable, "", runtime.ErrDraining.Error())
+			return
+		}
+		defer r.tracker.End()
+		h.ServeHTTP(w, req)
+	})
+}
+
+// recordRequest records the requests to be replayed, the body is buffered to be read by the function as well.
+func (r *Runtime) recordRequest(h http.Handler) http.Handler {
+	if r.recorder == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			klog.Errorf("failed to read the body of the recorded request: %v", err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		headers := make(map[string]string, len(req.Header))
+		for key := range req.Header {
+			headers[key] = req.Header.Get(key)
+		}
+		r.recorder.Record(&runtime.RecordedEvent{
+			Kind:        runtime.RecordedHTTP,
+			Method:      req.Method,
+			URL:         req.URL.RequestURI(),
+			ContentType: req.Header.Get("Content-Type"),
+			Metadata:    headers,
+			Data:        body,
+		})
+		h.ServeHTTP(w, req)
+	})
+}
+
+// limitInflight rejects the requests with 503 while the max concurrent requests are in flight.
+func (r *Runtime) limitInflight(ctx ofctx.RuntimeContext, h http.Handler) http.Handler {
+	if r.inflight == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case r.inflight <- struct{}{}:
+			defer func() {
+				<-r.inflight
+			}()
+			h.ServeHTTP(w, req)
+		default:
+			writeHTTPError(ctx, w, req, http.StatusServiceUnavailable, "", "too many requests in flight")
+		}
+	})
+}
+
+// withRequestID sets the request id from the X-Request-ID header, or a generated one, on the request,
+// and echoes it in the response header.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, ofctx.WithRequestID(r, id))
+	})
+}
+
+// validateHttpPayload rejects the requests whose body does not match the http JSON schema with 400.
+func validateHttpPayload(ctx ofctx.RuntimeContext, fn http.HandlerFunc) http.Handler {
+	// Leave the body unread when there is nothing to validate
+	if !ctx.HasHttpSchema() {
+		return fn
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeHTTPError(ctx, w, r, http.StatusBadRequest, "", err.Error())
+				return
+			}
+			if err := ctx.ValidateHttpPayload(body); err != nil {
+				writeHTTPError(ctx, w, r, http.StatusBadRequest, "", err.Error())
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		fn(w, r)
+	})
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// verifyClientCert rejects the requests without a client certificate signed by the pool with 401.
+func verifyClientCert(pool *x509.CertPool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range r.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         pool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			klog.Warningf("rejected client certificate: %v", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Replay serves the requests recorded to the directory in the order they were received,
+// the status of each response is logged.
+func (r *Runtime) Replay(dir string) error {
+	events, err := runtime.ReadRecordedEvents(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.Kind != runtime.RecordedHTTP {
+			klog.Warningf("skipped the recorded %s event not served by the knative runtime", e.Kind)
+			continue
+		}
+		req := httptest.NewRequest(e.Method, e.URL, bytes.NewReader(e.Data))
+		for key, value := range e.Metadata {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		r.handler.ServeHTTP(w, req)
+		klog.Infof("replayed the recorded request %s %s: %d", e.Method, e.URL, w.Code)
+	}
+	return nil
+}
+
+// Pattern returns the pattern the function is served on.
+func (r *Runtime) Pattern() string {
+	return r.pattern
+}
+
+// WithPattern returns the runtime registering the functions on the pattern, so that the process serves
+// several patterns, each routed to the function registered on it. The runtimes share the server of r,
+// which is started by r.
+func (r *Runtime) WithPattern(pattern string) *Runtime {
+	rt := *r
+	rt.pattern = pattern
+	return &rt
+}
+
 func (r *Runtime) Name() ofctx.Runtime {
 	return ofctx.Knative
 }
@@ -135,6 +722,30 @@ func RecoverPanicHTTP(w http.ResponseWriter, msg string) {
 	}
 }
 
+// recoverPanic recovers the function panic, logs the stack and responds with the formatter of the context.
+func recoverPanic(ctx ofctx.RuntimeContext, w http.ResponseWriter, req *http.Request, msg string) {
+	if r := recover(); r != nil {
+		msg = fmt.Sprintf("%s: %v", msg, r)
+		fmt.Fprintf(os.Stderr, "%s\n\n%s\n", msg, debug.Stack())
+		writeHTTPError(ctx, w, req, http.StatusInternalServerError, crashStatus, msg)
+	}
+}
+
+// writeHTTPError writes the error generated by the runtime with the error response formatter of the context,
+// the function status header is set unless the status is empty.
+func writeHTTPError(ctx ofctx.RuntimeContext, w http.ResponseWriter, r *http.Request, statusCode int, status, msg string) {
+	contentType, body := ctx.GetErrorResponseFormatter()(statusCode, msg, ofctx.RequestID(r))
+	if status != "" {
+		w.Header().Set(functionStatusHeader, status)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		klog.Errorf("failed to write error response: %v", err)
+	}
+}
+
 func writeHTTPErrorResponse(w http.ResponseWriter, statusCode int, status, msg string) {
 	// Ensure logs end with a newline otherwise they are grouped incorrectly in SD.
 	if !strings.HasSuffix(msg, "\n") {
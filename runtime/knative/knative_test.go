@@ -0,0 +1,37 @@
+package knative
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+	"github.com/tpiperatgod/offf-go/runtime"
+)
+
+func TestTrackInflightDraining(t *testing.T) {
+	r := &Runtime{tracker: &runtime.InflightTracker{}}
+	h := r.trackInflight(&ofctx.FunctionContext{}, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("done"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d before draining, want %d", rec.Code, http.StatusOK)
+	}
+
+	if err := r.tracker.Drain(context.Background()); err != nil {
+		t.Fatalf("failed to drain: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d while draining, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get("Connection"); got != "close" {
+		t.Fatalf("got Connection header %q while draining, want close", got)
+	}
+}
@@ -0,0 +1,224 @@
+package knative
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+// route matches request paths against a pattern such as `/orders/{id}`
+// and captures the values of the parameterized segments.
+// The pattern ending with the catch-all segment `*`, such as `/proxy/*`, matches any path under its prefix
+// and captures the rest of the path as the CatchAllPathParam parameter.
+type route struct {
+	segments []string
+	params   bool
+	catchAll bool
+}
+
+func newRoute(pattern string) *route {
+	rt := &route{
+		segments: strings.Split(strings.Trim(pattern, "/"), "/"),
+	}
+	if last := len(rt.segments) - 1; rt.segments[last] == ofctx.CatchAllPathParam {
+		rt.segments = rt.segments[:last]
+		rt.catchAll = true
+		rt.params = true
+	}
+	for _, seg := range rt.segments {
+		if isParam(seg) {
+			rt.params = true
+		}
+	}
+	return rt
+}
+
+// prefix returns the pattern to register with http.ServeMux,
+// which is the static part of the pattern before the first parameter.
+func (rt *route) prefix() string {
+	var static []string
+	for _, seg := range rt.segments {
+		if isParam(seg) {
+			break
+		}
+		static = append(static, seg)
+	}
+	if len(static) == 0 {
+		return "/"
+	}
+	return "/" + strings.Join(static, "/") + "/"
+}
+
+// match reports whether the path matches the route and returns the captured parameters.
+func (rt *route) match(path string) (map[string]string, bool) {
+	var segments []string
+	params := map[string]string{}
+	if rt.catchAll {
+		// the rest of the path is kept as is, including its trailing slash
+		segments = strings.SplitN(strings.TrimPrefix(path, "/"), "/", len(rt.segments)+1)
+		if len(segments) < len(rt.segments) {
+			return nil, false
+		}
+		if len(segments) > len(rt.segments) {
+			params[ofctx.CatchAllPathParam] = segments[len(rt.segments)]
+			segments = segments[:len(rt.segments)]
+		} else {
+			params[ofctx.CatchAllPathParam] = ""
+		}
+	} else {
+		segments = strings.Split(strings.Trim(path, "/"), "/")
+		if len(segments) != len(rt.segments) {
+			return nil, false
+		}
+	}
+
+	for i, seg := range rt.segments {
+		if isParam(seg) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+		} else if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func isParam(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// ambiguous reports whether the routes match the same paths, which is when their segments are the same
+// except for the names of their parameters.
+func (rt *route) ambiguous(other *route) bool {
+	if rt.catchAll != other.catchAll || len(rt.segments) != len(other.segments) {
+		return false
+	}
+	for i, seg := range rt.segments {
+		if isParam(seg) != isParam(other.segments[i]) || !isParam(seg) && seg != other.segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// before reports whether the route is tried before the other one sharing its prefix, the more specific route first:
+// the routes without a catch-all segment, then the routes with a static segment where the other has a parameter,
+// then the longer routes.
+func (rt *route) before(other *route) bool {
+	if rt.catchAll != other.catchAll {
+		return !rt.catchAll
+	}
+	for i := 0; i < len(rt.segments) && i < len(other.segments); i++ {
+		if p, q := isParam(rt.segments[i]), isParam(other.segments[i]); p != q {
+			return !p
+		}
+	}
+	return len(rt.segments) > len(other.segments)
+}
+
+// routeEntry is the handler of a parameterized pattern.
+type routeEntry struct {
+	pattern string
+	route   *route
+	handler http.Handler
+}
+
+// routeTable records the patterns registered on the mux to detect their collisions.
+// The parameterized patterns sharing a static prefix are registered on the prefix through a single dispatcher,
+// which serves the request with the first of their routes matching its path.
+type routeTable struct {
+	mu       sync.RWMutex
+	patterns map[string]string
+	prefixes map[string][]*routeEntry
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		patterns: map[string]string{},
+		prefixes: map[string][]*routeEntry{},
+	}
+}
+
+// add records the static pattern registered on the mux, it fails if the pattern is already registered.
+func (t *routeTable) add(pattern string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if existing, ok := t.patterns[pattern]; ok {
+		return fmt.Errorf("a function is already registered on the pattern %s", existing)
+	}
+	if entries, ok := t.prefixes[pattern]; ok {
+		return fmt.Errorf("the pattern %s collides with the pattern %s", pattern, entries[0].pattern)
+	}
+	t.patterns[pattern] = pattern
+	return nil
+}
+
+// addRoute records the handler of the parameterized pattern on its prefix, it fails if the pattern is ambiguous
+// with a pattern already registered. The dispatcher of the prefix is returned when it is to be registered on the mux,
+// that is for the first pattern of the prefix.
+func (t *routeTable) addRoute(pattern string, rt *route, h http.Handler) (http.Handler, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prefix := rt.prefix()
+	if existing, ok := t.patterns[prefix]; ok {
+		return nil, fmt.Errorf("the pattern %s collides with the pattern %s", pattern, existing)
+	}
+	entries, registered := t.prefixes[prefix]
+	for _, e := range entries {
+		if e.pattern == pattern {
+			return nil, fmt.Errorf("a function is already registered on the pattern %s", pattern)
+		}
+		if e.route.ambiguous(rt) {
+			return nil, fmt.Errorf("the pattern %s collides with the pattern %s", pattern, e.pattern)
+		}
+	}
+
+	// the entries are kept sorted from the most specific route
+	entry := &routeEntry{pattern: pattern, route: rt, handler: h}
+	i := sort.Search(len(entries), func(i int) bool {
+		return rt.before(entries[i].route)
+	})
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	t.prefixes[prefix] = entries
+
+	if registered {
+		return nil, nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.dispatch(prefix, w, req)
+	}), nil
+}
+
+// dispatch serves the request with the first route of the prefix matching its path, with the parameters captured.
+func (t *routeTable) dispatch(prefix string, w http.ResponseWriter, req *http.Request) {
+	t.mu.RLock()
+	entries := t.prefixes[prefix]
+	t.mu.RUnlock()
+
+	for _, e := range entries {
+		if params, ok := e.route.match(req.URL.Path); ok {
+			e.handler.ServeHTTP(w, ofctx.WithPathParams(req, params))
+			return
+		}
+	}
+	http.NotFound(w, req)
+}
+
+// has reports whether a function is registered on the static pattern.
+func (t *routeTable) has(pattern string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	_, ok := t.patterns[pattern]
+	return ok
+}
@@ -0,0 +1,180 @@
+package knative
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	ofctx "github.com/tpiperatgod/offf-go/context"
+)
+
+func TestRouteMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		path    string
+		params  map[string]string
+	}{
+		{"/orders/{id}", "/orders/42", map[string]string{"id": "42"}},
+		{"/orders/{id}", "/orders/42/", map[string]string{"id": "42"}},
+		{"/orders/{id}", "/orders/", nil},
+		{"/orders/{id}", "/orders/42/items", nil},
+		{"/orders/{id}/items/{item}", "/orders/42/items/7", map[string]string{"id": "42", "item": "7"}},
+		{"/orders/{id}/items/{item}", "/orders/42/lines/7", nil},
+		{"/proxy/*", "/proxy", map[string]string{ofctx.CatchAllPathParam: ""}},
+		{"/proxy/*", "/proxy/", map[string]string{ofctx.CatchAllPathParam: ""}},
+		{"/proxy/*", "/proxy/api/v1/", map[string]string{ofctx.CatchAllPathParam: "api/v1/"}},
+		{"/orders/{id}/*", "/orders/42/items/7", map[string]string{"id": "42", ofctx.CatchAllPathParam: "items/7"}},
+		{"/*", "/static/app.js", map[string]string{ofctx.CatchAllPathParam: "static/app.js"}},
+	} {
+		params, ok := newRoute(tc.pattern).match(tc.path)
+		if ok != (tc.params != nil) {
+			t.Fatalf("got match %v for %s on %s, want %v", ok, tc.path, tc.pattern, tc.params != nil)
+		}
+		if len(params) != len(tc.params) {
+			t.Fatalf("got params %v for %s on %s, want %v", params, tc.path, tc.pattern, tc.params)
+		}
+		for k, v := range tc.params {
+			if params[k] != v {
+				t.Fatalf("got params %v for %s on %s, want %v", params, tc.path, tc.pattern, tc.params)
+			}
+		}
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"/orders/{id}":               "/orders/",
+		"/store/orders/{id}/items":   "/store/orders/",
+		"/store/orders/{id}/*":       "/store/orders/",
+		"/{tenant}/orders":           "/",
+		"/*":                         "/",
+		"/proxy/*":                   "/proxy/",
+		"/orders/{id}/items/{item}/": "/orders/",
+	} {
+		if got := newRoute(pattern).prefix(); got != want {
+			t.Fatalf("got prefix %s for %s, want %s", got, pattern, want)
+		}
+	}
+}
+
+func TestRouteTableCollisions(t *testing.T) {
+	table := newRouteTable()
+	add := func(pattern string) error {
+		rt := newRoute(pattern)
+		if !rt.params {
+			return table.add(pattern)
+		}
+		_, err := table.addRoute(pattern, rt, http.NotFoundHandler())
+		return err
+	}
+
+	for _, pattern := range []string{
+		"/shop/cart",
+		"/store/orders/{id}",
+		"/store/orders/{id}/items",
+		"/store/orders/{id}/items/{item}",
+		"/store/orders/{id}/*",
+	} {
+		if err := add(pattern); err != nil {
+			t.Fatalf("failed to add pattern %s: %v", pattern, err)
+		}
+	}
+	if !table.has("/shop/cart") || table.has("/store/orders/") {
+		t.Fatal("got the wrong static patterns registered")
+	}
+
+	// only the patterns matching the same paths are rejected
+	for _, pattern := range []string{
+		"/shop/cart",
+		"/store/orders/{id}/items",
+		"/store/orders/{order}/items/{sku}",
+		"/store/orders/{order}/*",
+		"/store/orders/",
+	} {
+		if err := add(pattern); err == nil {
+			t.Fatalf("added pattern %s colliding with a registered pattern", pattern)
+		}
+	}
+	if err := add("/store/orders/{id}/notes"); err != nil {
+		t.Fatalf("failed to add pattern /store/orders/{id}/notes: %v", err)
+	}
+
+	// the static pattern collides with the prefix of the parameterized patterns
+	table = newRouteTable()
+	if err := add("/*"); err != nil {
+		t.Fatalf("failed to add pattern /*: %v", err)
+	}
+	if err := add("/"); err == nil {
+		t.Fatal("added pattern / colliding with the catch-all pattern")
+	}
+}
+
+func TestRouteTableDispatch(t *testing.T) {
+	table := newRouteTable()
+	reply := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := ofctx.PathParams(r)
+			names := make([]string, 0, len(params))
+			for k := range params {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			out := []string{name}
+			for _, k := range names {
+				out = append(out, k+"="+params[k])
+			}
+			w.Write([]byte(strings.Join(out, " ")))
+		})
+	}
+
+	// the dispatcher is only returned for the first pattern of the prefix
+	var dispatcher http.Handler
+	for i, p := range []struct {
+		pattern string
+		name    string
+	}{
+		{"/store/orders/{id}/*", "order-files"},
+		{"/store/orders/{id}", "order"},
+		{"/store/orders/{id}/items/{item}", "item"},
+		{"/store/orders/{id}/items", "items"},
+	} {
+		h, err := table.addRoute(p.pattern, newRoute(p.pattern), reply(p.name))
+		if err != nil {
+			t.Fatalf("failed to add pattern %s: %v", p.pattern, err)
+		}
+		if i == 0 {
+			dispatcher = h
+		} else if h != nil {
+			t.Fatalf("got another dispatcher for pattern %s", p.pattern)
+		}
+	}
+	if dispatcher == nil {
+		t.Fatal("got no dispatcher for the first pattern")
+	}
+
+	// the most specific route matching the path serves the request
+	for path, want := range map[string]string{
+		"/store/orders/42":               "order id=42",
+		"/store/orders/42/items":         "items id=42",
+		"/store/orders/42/items/7":       "item id=42 item=7",
+		"/store/orders/42/invoice.pdf":   "order-files *=invoice.pdf id=42",
+		"/store/orders/42/items/7/notes": "order-files *=items/7/notes id=42",
+	} {
+		rec := httptest.NewRecorder()
+		dispatcher.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d for %s, want %d", rec.Code, path, http.StatusOK)
+		}
+		if got := rec.Body.String(); got != want {
+			t.Fatalf("got %q for %s, want %q", got, path, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	dispatcher.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/store/orders/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unmatched path, want %d", rec.Code, http.StatusNotFound)
+	}
+}
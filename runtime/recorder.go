@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// RecordEventsDirEnvName sets the directory the incoming events are recorded to, nothing is recorded when unset.
+	RecordEventsDirEnvName = "RECORD_EVENTS_DIR"
+
+	RecordedHTTP    = "http"
+	RecordedBinding = "binding"
+	RecordedTopic   = "topic"
+	RecordedService = "service"
+)
+
+// RecordedEvent is an incoming event recorded to be replayed through the test runtime.
+type RecordedEvent struct {
+	// Kind is one of http, binding, topic and service.
+	Kind string `json:"kind"`
+	// Name is the binding component, the pubsub component or the service method.
+	Name  string `json:"name,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	// Method and URL are the http request method and url.
+	Method      string `json:"method,omitempty"`
+	URL         string `json:"url,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Metadata holds the binding metadata, the topic event attributes or the http headers.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+// Recorder writes the incoming events to a directory, one JSON file per event.
+// A nil Recorder records nothing.
+type Recorder struct {
+	dir string
+	seq uint64
+}
+
+// NewRecorder returns the recorder of the directory set by RECORD_EVENTS_DIR, or nil if it is unset.
+func NewRecorder() *Recorder {
+	dir := os.Getenv(RecordEventsDirEnvName)
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		klog.Errorf("failed to create the directory of the recorded events %s: %v", dir, err)
+		return nil
+	}
+	klog.Infof("recording the incoming events to %s", dir)
+	return &Recorder{dir: dir}
+}
+
+// Record writes the event, the failures are logged rather than failing the invocation.
+func (r *Recorder) Record(e *RecordedEvent) {
+	if r == nil {
+		return
+	}
+	e.Time = time.Now()
+	data, err := json.Marshal(e)
+	if err != nil {
+		klog.Errorf("failed to marshal the recorded %s event: %v", e.Kind, err)
+		return
+	}
+	// The timestamp and the sequence keep the files in the order they are received
+	name := fmt.Sprintf("%020d-%06d-%s.json", e.Time.UnixNano(), atomic.AddUint64(&r.seq, 1), e.Kind)
+	if err := ioutil.WriteFile(filepath.Join(r.dir, name), data, 0644); err != nil {
+		klog.Errorf("failed to record the %s event: %v", e.Kind, err)
+	}
+}
+
+// ReadRecordedEvents reads the events recorded to the directory in the order they were received.
+func ReadRecordedEvents(dir string) ([]*RecordedEvent, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	events := make([]*RecordedEvent, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		e := &RecordedEvent{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, fmt.Errorf("invalid recorded event %s: %w", name, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -1,9 +1,14 @@
 package runtime
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"k8s.io/klog/v2"
@@ -26,6 +31,12 @@ type Interface interface {
 		postPlugins []plugin.Plugin,
 		fn func(ofctx.Context, []byte) (ofctx.Out, error),
 	) error
+	RegisterStreamFunction(
+		ctx ofctx.RuntimeContext,
+		prePlugins []plugin.Plugin,
+		postPlugins []plugin.Plugin,
+		fn func(ofctx.Context, io.Reader) (ofctx.Out, error),
+	) error
 	RegisterCloudEventFunction(
 		ctx context.Context,
 		funcContex ofctx.RuntimeContext,
@@ -33,10 +44,25 @@ type Interface interface {
 		postPlugins []plugin.Plugin,
 		fn func(context.Context, cloudevents.Event) error,
 	) error
+	RegisterCloudEventResponseFunction(
+		ctx context.Context,
+		funcContex ofctx.RuntimeContext,
+		prePlugins []plugin.Plugin,
+		postPlugins []plugin.Plugin,
+		fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error),
+	) error
+	// Replay delivers the events recorded to the directory by RECORD_EVENTS_DIR.
+	Replay(dir string) error
 	Name() ofctx.Runtime
 	GetHandler() interface{}
 }
 
+const (
+	daprClientInitialBackoff = 500 * time.Millisecond
+	daprClientMaxBackoff     = 5 * time.Second
+	daprClientInitTimeout    = 60 * time.Second
+)
+
 type RuntimeManager struct {
 	FuncContext ofctx.RuntimeContext
 	FuncOut     ofctx.Out
@@ -46,7 +72,8 @@ type RuntimeManager struct {
 }
 
 func NewRuntimeManager(funcContext ofctx.RuntimeContext, prePlugin []plugin.Plugin, postPlugin []plugin.Plugin) *RuntimeManager {
-	ctx := funcContext
+	// the values stored by the plugins and the function are scoped to the invocation
+	ctx := ofctx.NewInvocationContext(funcContext)
 	rm := &RuntimeManager{
 		FuncContext: ctx,
 		prePlugins:  prePlugin,
@@ -61,14 +88,36 @@ func NewRuntimeManager(funcContext ofctx.RuntimeContext, prePlugin []plugin.Plug
 	return rm
 }
 
+// InitDaprClientWithBackoff initializes the dapr client of the context,
+// retrying with an exponential backoff while the dapr sidecar is not ready.
+func InitDaprClientWithBackoff(ctx ofctx.RuntimeContext) error {
+	var err error
+	backoff := daprClientInitialBackoff
+	deadline := time.Now().Add(daprClientInitTimeout)
+
+	for {
+		if err = ctx.InitDaprClientIfNil(); err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("failed to init dapr client within %s: %v", daprClientInitTimeout, err)
+		}
+		klog.Warningf("failed to init dapr client, retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > daprClientMaxBackoff {
+			backoff = daprClientMaxBackoff
+		}
+	}
+}
+
 func (rm *RuntimeManager) init() {
-	rm.FuncContext.SetNativeContext(context.Background())
+	rm.FuncContext.SetNativeContext(rm.FuncContext.GetBaseContext())
 	rm.pluginState = map[string]plugin.Plugin{}
 
 	var newPrePlugins []plugin.Plugin
 	for _, plg := range rm.prePlugins {
 		if existPlg, ok := rm.pluginState[plg.Name()]; !ok {
-			p := plg.Init()
+			p := rm.initPlugin(plg)
 			rm.pluginState[plg.Name()] = p
 			newPrePlugins = append(newPrePlugins, p)
 		} else {
@@ -80,7 +129,7 @@ func (rm *RuntimeManager) init() {
 	var newPostPlugins []plugin.Plugin
 	for _, plg := range rm.postPlugins {
 		if existPlg, ok := rm.pluginState[plg.Name()]; !ok {
-			p := plg.Init()
+			p := rm.initPlugin(plg)
 			rm.pluginState[plg.Name()] = p
 			newPostPlugins = append(newPostPlugins, p)
 		} else {
@@ -90,26 +139,124 @@ func (rm *RuntimeManager) init() {
 	rm.postPlugins = newPostPlugins
 }
 
-func (rm *RuntimeManager) ProcessPreHooks() {
+// initPlugin initializes the plugin and passes its configuration if the plugin is configurable.
+func (rm *RuntimeManager) initPlugin(plg plugin.Plugin) plugin.Plugin {
+	p := plg.Init()
+	if c, ok := p.(plugin.Configurable); ok {
+		if config := rm.FuncContext.GetPluginConfig(plg.Name()); config != nil {
+			if err := c.Configure(config); err != nil {
+				klog.Warningf("failed to configure plugin %s: %v", plg.Name(), err)
+			}
+		}
+	}
+	return p
+}
+
+// ProcessPreHooks runs the pre-hooks, and returns the error of the hook aborting the function if any.
+// The remaining hooks are skipped once the invocation is cancelled.
+func (rm *RuntimeManager) ProcessPreHooks() error {
 	for _, plg := range rm.prePlugins {
-		if err := plg.ExecPreHook(rm.FuncContext, rm.pluginState); err != nil {
+		if err := rm.execHook(plg.Name(), ofctx.PhasePre, plg.ExecPreHook); err != nil {
+			if errors.Is(err, plugin.ErrAbort) {
+				klog.Infof("plugin %s aborted the function in pre phase: %s", plg.Name(), err.Error())
+				return err
+			}
 			klog.Warningf("plugin %s failed in pre phase: %s", plg.Name(), err.Error())
 		}
+		if err := rm.FuncContext.GetNativeContext().Err(); err != nil {
+			klog.Warningf("function cancelled in pre phase after plugin %s: %v", plg.Name(), err)
+			return fmt.Errorf("function cancelled in pre phase: %w", err)
+		}
 	}
+	return nil
 }
 
+// ProcessPostHooks runs the post-hooks, the remaining hooks are skipped once the invocation is cancelled.
 func (rm *RuntimeManager) ProcessPostHooks() {
 	for _, plg := range rm.postPlugins {
-		if err := plg.ExecPostHook(rm.FuncContext, rm.pluginState); err != nil {
+		if err := rm.FuncContext.GetNativeContext().Err(); err != nil {
+			klog.Warningf("function cancelled in post phase before plugin %s: %v", plg.Name(), err)
+			return
+		}
+		if err := rm.execHook(plg.Name(), ofctx.PhasePost, plg.ExecPostHook); err != nil {
 			klog.Warningf("plugin %s failed in post phase: %s", plg.Name(), err.Error())
 		}
 	}
 }
 
+// execHook runs the hook of the plugin in the phase and records its duration.
+func (rm *RuntimeManager) execHook(name string, phase string, hook func(ofctx.RuntimeContext, map[string]plugin.Plugin) error) error {
+	start := time.Now()
+	err := rm.runHook(name, hook)
+	d := time.Since(start)
+	rm.FuncContext.RecordPluginTiming(name, phase, d)
+	klog.V(4).Infof("plugin %s took %s in %s phase", name, d, phase)
+	return err
+}
+
+// runHook runs the hook of the plugin and abandons it once it exceeds the hook timeout of the plugin
+// or the invocation is cancelled. The hook gets the context bounded by its deadline through GetHookContext.
+// An abandoned hook is not stopped, it keeps running in its goroutine until it returns by itself,
+// the hooks are therefore expected to return once their context is done without changing the invocation any more.
+func (rm *RuntimeManager) runHook(name string, hook func(ofctx.RuntimeContext, map[string]plugin.Plugin) error) error {
+	ctx := rm.FuncContext.GetNativeContext()
+	timeout := rm.FuncContext.GetPluginHookTimeout(name)
+	if timeout <= 0 && ctx.Done() == nil {
+		// the invocation cannot be cancelled
+		return hook(rm.FuncContext, rm.pluginState)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	rm.FuncContext.SetHookContext(ctx)
+	defer rm.FuncContext.SetHookContext(nil)
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- hook(rm.FuncContext, rm.pluginState)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		go func() {
+			<-done
+			klog.Warningf("abandoned hook of plugin %s returned after %s", name, time.Since(start))
+		}()
+		if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("hook abandoned after %s: %v", timeout, ctx.Err())
+		}
+		return fmt.Errorf("hook abandoned: %v", ctx.Err())
+	}
+}
+
 func (rm *RuntimeManager) FunctionRunWrapperWithHooks(fn interface{}) {
-	functionContext := rm.FuncContext.GetContext()
+	functionContext, ok := rm.FuncContext.(ofctx.Context)
+	if !ok {
+		functionContext = rm.FuncContext.GetContext()
+	}
+	rm.FuncContext.WithError(nil)
+	rm.FuncContext.ResetPluginTimings()
+	rm.FuncContext.ResetValues()
 
-	rm.ProcessPreHooks()
+	if err := rm.ProcessPreHooks(); err != nil {
+		// skip the function, and respond with the code of the abort error
+		code := ofctx.InternalError
+		var abortErr *plugin.AbortError
+		if errors.As(err, &abortErr) {
+			code = abortErr.Code
+		}
+		rm.FuncOut = ofctx.NewFunctionOut().WithCode(code)
+		rm.FuncContext.WithOut(rm.FuncOut.GetOut())
+		rm.FuncContext.WithError(err)
+		rm.ProcessPostHooks()
+		return
+	}
 
 	if function, ok := fn.(func(http.ResponseWriter, *http.Request)); ok {
 
@@ -123,7 +270,7 @@ func (rm *RuntimeManager) FunctionRunWrapperWithHooks(fn interface{}) {
 		rm.FuncContext.WithOut(rm.FuncOut.WithCode(rww.Status()))
 
 	} else if function, ok := fn.(func(ofctx.Context, []byte) (ofctx.Out, error)); ok {
-		if rm.FuncContext.GetBindingEvent() != nil || rm.FuncContext.GetTopicEvent() != nil {
+		if rm.FuncContext.GetBindingEvent() != nil || rm.FuncContext.GetTopicEvent() != nil || rm.FuncContext.GetInvocationEvent() != nil {
 
 			// get the user data from inner event
 			userData := rm.FuncContext.GetInnerEvent().GetUserData()
@@ -131,24 +278,54 @@ func (rm *RuntimeManager) FunctionRunWrapperWithHooks(fn interface{}) {
 			// pass user data to user function
 			out, err := function(functionContext, userData)
 
+			rm.FuncOut = out
 			rm.FuncContext.WithOut(out.GetOut())
 			rm.FuncContext.WithError(err)
 
 		} else if rm.FuncContext.GetSyncRequest().Request != nil {
 
-			body, _ := ioutil.ReadAll(rm.FuncContext.GetSyncRequest().Request.Body)
+			r := rm.FuncContext.GetSyncRequest().Request
+			body, _ := ioutil.ReadAll(r.Body)
+			// restore the body so that the function is still able to parse the form
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
 			out, err := function(functionContext, body)
+			rm.FuncOut = out
 			rm.FuncContext.WithOut(out.GetOut())
 			rm.FuncContext.WithError(err)
 
 		}
+	} else if function, ok := fn.(func(ofctx.Context, io.Reader) (ofctx.Out, error)); ok {
+		var in io.Reader
+		if rm.FuncContext.GetBindingEvent() != nil || rm.FuncContext.GetTopicEvent() != nil || rm.FuncContext.GetInvocationEvent() != nil {
+			// the events are delivered in full, read the user data from memory
+			in = bytes.NewReader(rm.FuncContext.GetInnerEvent().GetUserData())
+		} else if rm.FuncContext.GetSyncRequest().Request != nil {
+			in = rm.FuncContext.GetSyncRequest().Request.Body
+		}
+		if in != nil {
+			out, err := function(functionContext, in)
+			rm.FuncOut = out
+			rm.FuncContext.WithOut(out.GetOut())
+			rm.FuncContext.WithError(err)
+		}
 	} else if function, ok := fn.(func(context.Context, cloudevents.Event) error); ok {
 		ce := cloudevents.Event{}
 		if rm.FuncContext.GetCloudEvent() != nil {
 			ce = *rm.FuncContext.GetCloudEvent()
 		}
 		rm.FuncContext.WithError(function(rm.FuncContext.GetNativeContext(), ce))
+	} else if function, ok := fn.(func(context.Context, cloudevents.Event) (*cloudevents.Event, error)); ok {
+		ce := cloudevents.Event{}
+		if rm.FuncContext.GetCloudEvent() != nil {
+			ce = *rm.FuncContext.GetCloudEvent()
+		}
+		resp, err := function(rm.FuncContext.GetNativeContext(), ce)
+		rm.FuncContext.WithCloudEventResponse(resp)
+		rm.FuncContext.WithError(err)
 	}
 
+	// flush the messages buffered during the function execution
+	rm.FuncContext.FlushBufferedSenders()
+
 	rm.ProcessPostHooks()
 }